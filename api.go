@@ -1,18 +1,30 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"errors"
+	"fmt"
+	"math/big"
 	"time"
 )
 
 type Employee struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Designation string    `json:"designation"`
-	Salary      float64   `json:"salary"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          int    `json:"id" validate:"readonly"`
+	Name        string `json:"name" validate:"required,max=100"`
+	Designation string `json:"designation" validate:"required,max=100,designation"`
+	Salary      Money  `json:"salary" validate:"min=0,max=1000000000"`
+	// Currency is the ISO 4217 code of Salary
+	Currency string `json:"currency" validate:"required,currency"`
+	// DepartmentID is the department the employee belongs to, if any
+	DepartmentID *int `json:"departmentId"`
+	// ManagerID is the employee this one reports to; nil at the top of the organisation
+	ManagerID *int      `json:"managerId"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// ReportingSalary is Salary converted into the reporting currency a listing asked for
+	ReportingSalary *Money `json:"reportingSalary,omitempty" validate:"readonly"`
+	// Version is bumped by every update and sent as the ETag rather than in the body
+	Version int `json:"-"`
 }
 
 var ErrTimeoutCreatingEmployee = errors.New("timeout occurred while creating employee")
@@ -20,138 +32,689 @@ var ErrTimeoutReadingEmployee = errors.New("timeout occurred while reading emplo
 var ErrTimeoutUpdatingEmployee = errors.New("timeout occurred while updating employee")
 var ErrTimeoutDeletingEmployee = errors.New("timeout occurred while deleting employee")
 
-func CreateEmployeeAPI(db *sql.DB, emp *Employee) (*Employee, error) {
-	// Channel to receive errors from the store operation
-	errChan := make(chan error, 1)
-
-	// Asynchronously call the CreateEmployeeStore function
-	go func() {
-		err := CreateEmployeeStore(db, emp)
-		errChan <- err
-	}()
-
-	// Wait for either a timeout or an error from the store operation
-	select {
-	case <-time.After(5 * time.Second): // Timeout after 5 seconds
-		return nil, ErrTimeoutCreatingEmployee
-	case err := <-errChan:
-		if err != nil {
-			return nil, err
-		}
+// apiTimeouts bounds each store operation; main replaces it with the configured values
+var apiTimeouts = DefaultTimeouts()
+
+// timeoutError replaces the error of an operation whose deadline expired with timeoutErr.
+// Cancellation by the client is passed through unchanged.
+func timeoutError(ctx context.Context, err, timeoutErr error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return timeoutErr
+	}
+	return err
+}
+
+// newEmployee returns the Employee a create or replace request is decoded into, holding the
+// defaults of the members it may omit
+func newEmployee() *Employee {
+	return &Employee{Currency: defaultCurrency}
+}
+
+func CreateEmployeeAPI(ctx context.Context, store EmployeeStore, emp *Employee) (*Employee, error) {
+	// IDs are always allocated by the store, so a caller-supplied one is dropped
+	emp.ID = 0
+	if emp.Currency == "" {
+		emp.Currency = defaultCurrency
+	}
+	if errs := ValidateStruct(emp, nil, nil); len(errs) > 0 {
+		return nil, NewValidationError(errs)
+	}
+
+	// Bound the store operation by the configured deadline
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.Create)
+	defer cancel()
+
+	err := store.CreateEmployee(ctx, emp)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutCreatingEmployee)
 	}
 
 	// If we reach this point, it means employee creation was successful
 	return emp, nil
 }
 
-func ReadEmployeeAPI(db *sql.DB, id int, emp *Employee) (*Employee, error) {
-	// Channel to receive errors from the store operation
-	errChan := make(chan error, 1)
-	empChan := make(chan *Employee, 1)
+func ReadEmployeeAPI(ctx context.Context, store EmployeeStore, id int) (*Employee, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.Read)
+	defer cancel()
 
-	// Asynchronously call the ReadEmployeeStore function
-	go func() {
-		emp, err := ReadEmployeeStore(db, id)
-		if err != nil {
-			errChan <- err
-			return
+	emp, err := store.ReadEmployee(ctx, id)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutReadingEmployee)
+	}
+	return emp, nil
+}
+
+// ReadEmployeeListAPI returns one page of employees with the cursors of the pages around it,
+// and the number of employees matching the filter as total asks
+func ReadEmployeeListAPI(ctx context.Context, store EmployeeStore, q EmployeeListQuery, total TotalMode) (*EmployeePage, error) {
+	if q.Limit < 1 {
+		return nil, NewInvalidQueryError([]FieldError{{Field: "limit", Message: "must be at least 1"}})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.List)
+	defer cancel()
+
+	// Read one row more than asked to learn whether there is another page beyond this one
+	probe := q
+	probe.Limit++
+	employees, err := store.ReadEmployeeList(ctx, probe)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutReadingEmployee)
+	}
+
+	backward := q.Cursor != nil && q.Cursor.Backward
+	fromEnd := q.Cursor != nil && q.Cursor.FromEnd
+	more := len(employees) > q.Limit
+	if more && backward {
+		employees = employees[1:]
+	} else if more {
+		employees = employees[:q.Limit]
+	}
+
+	page := &EmployeePage{Items: employees, Limit: q.Limit}
+	if page.Items == nil {
+		page.Items = []Employee{}
+	}
+
+	// The side the cursor came from always has rows; the far side has them if the probe found one
+	if n := len(page.Items); n > 0 {
+		if (more && !backward) || (backward && !fromEnd) {
+			page.NextCursor = newEmployeeCursor(q.Sort, &page.Items[n-1], false).Encode()
 		}
-		empChan <- emp
-	}()
+		if (more && backward) || (!backward && q.Cursor != nil) {
+			page.PrevCursor = newEmployeeCursor(q.Sort, &page.Items[0], true).Encode()
+		}
+	}
 
-	// Wait for either a timeout or an error from the store operation
-	select {
-	case <-time.After(5 * time.Second): // Timeout after 5 seconds
-		return nil, ErrTimeoutReadingEmployee
-	case err := <-errChan:
+	if total != TotalNone {
+		count, estimated, err := store.CountEmployees(ctx, q.Filter, total == TotalEstimate)
 		if err != nil {
-			return nil, err
+			return nil, timeoutError(ctx, err, ErrTimeoutReadingEmployee)
 		}
-	case emp := <-empChan:
-		return emp, nil
+		page.Total = &count
+		page.TotalEstimated = estimated
 	}
 
-	// If we reach this point, it means no result was received before the timeout
-	return nil, errors.New("no result received before timeout")
+	if !q.Filter.AsOf.IsZero() {
+		page.AsOf = q.Filter.AsOf.Format(time.DateOnly)
+	}
+	if q.Reporting != nil {
+		converter := newCurrencyConverter(store, *q.Reporting)
+		for i := range page.Items {
+			emp := &page.Items[i]
+			converted, err := converter.convert(ctx, emp.Salary, emp.Currency)
+			if err != nil {
+				return nil, timeoutError(ctx, err, ErrTimeoutReadingEmployee)
+			}
+			emp.ReportingSalary = &converted
+		}
+		page.ReportingCurrency = q.Reporting.Currency
+		page.AsOf = q.Reporting.AsOf.Format(time.DateOnly)
+	}
+	return page, nil
+}
+
+// CurrencyTotal sums up the salaries in one currency
+type CurrencyTotal struct {
+	Currency string `json:"currency"`
+	Count    int    `json:"count"`
+	Total    Money  `json:"total"`
+	Min      Money  `json:"min"`
+	Max      Money  `json:"max"`
+	// Rate and ReportingTotal show how Total was converted, when a reporting currency was asked for
+	Rate           string `json:"rate,omitempty"`
+	ReportingTotal *Money `json:"reportingTotal,omitempty"`
+}
+
+// SalarySummary aggregates the salaries of the employees matching a filter, per currency
+// and, given a reporting currency, over all of them
+type SalarySummary struct {
+	Count             int             `json:"count"`
+	ReportingCurrency string          `json:"reportingCurrency,omitempty"`
+	AsOf              string          `json:"asOf,omitempty"`
+	Total             *Money          `json:"total,omitempty"`
+	Average           *Money          `json:"average,omitempty"`
+	Min               *Money          `json:"min,omitempty"`
+	Max               *Money          `json:"max,omitempty"`
+	Currencies        []CurrencyTotal `json:"currencies"`
 }
 
-func ReadEmployeeListAPI(db *sql.DB, limit, offset int) ([]Employee, error) {
-	// Channel to receive errors from the store operation
-	errChan := make(chan error, 1)
-	empChan := make(chan []Employee, 1)
+// SalarySummaryAPI totals the salaries of the employees matching f. With a reporting
+// currency each currency's total, minimum and maximum is converted once and combined.
+func SalarySummaryAPI(ctx context.Context, store EmployeeStore, f EmployeeFilter, reporting *ReportingCurrency) (*SalarySummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.List)
+	defer cancel()
+
+	totals, err := store.SalaryTotals(ctx, f)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutReadingEmployee)
+	}
+
+	summary := &SalarySummary{Currencies: totals}
+	if summary.Currencies == nil {
+		summary.Currencies = []CurrencyTotal{}
+	}
+	for _, total := range totals {
+		summary.Count += total.Count
+	}
+	if reporting == nil {
+		return summary, nil
+	}
 
-	// Asynchronously call the ReadEmployeeListStore function
-	go func() {
-		emp, err := ReadEmployeeListStore(db, limit, offset)
+	summary.ReportingCurrency = reporting.Currency
+	summary.AsOf = reporting.AsOf.Format(time.DateOnly)
+	converter := newCurrencyConverter(store, *reporting)
+	converted := make([]Money, 0, len(summary.Currencies))
+	var lowest, highest *Money
+	for i := range summary.Currencies {
+		total := &summary.Currencies[i]
+		rate, err := converter.rate(ctx, total.Currency)
 		if err != nil {
-			errChan <- err
-			return
+			return nil, timeoutError(ctx, err, ErrTimeoutReadingEmployee)
+		}
+
+		// Conversion keeps the order of amounts, so the extremes stay extremes
+		var figures [3]Money
+		for j, amount := range []Money{total.Total, total.Min, total.Max} {
+			if figures[j], err = amount.Mul(rate); err != nil {
+				return nil, err
+			}
 		}
-		empChan <- emp
-	}()
+		converted = append(converted, figures[0])
+		if lowest == nil || figures[1].Cmp(*lowest) < 0 {
+			lowest = &figures[1]
+		}
+		if highest == nil || figures[2].Cmp(*highest) > 0 {
+			highest = &figures[2]
+		}
+		total.Rate = formatRate(rate)
+		total.ReportingTotal = &figures[0]
+	}
 
-	// Wait for either a timeout or an error from the store operation
-	select {
-	case <-time.After(5 * time.Second): // Timeout after 5 seconds
-		return nil, ErrTimeoutReadingEmployee
-	case err := <-errChan:
+	sum, err := SumMoney(converted...)
+	if err != nil {
+		return nil, err
+	}
+	summary.Total = &sum
+	summary.Min, summary.Max = lowest, highest
+	if summary.Count > 0 {
+		average, err := sum.Mul(big.NewRat(1, int64(summary.Count)))
 		if err != nil {
 			return nil, err
 		}
-	case emp := <-empChan:
-		return emp, nil
+		summary.Average = &average
+	}
+	return summary, nil
+}
+
+// UpdateEmployeeAPI replaces every updatable field of the employee with those of emp.
+// A non-zero version makes the update conditional on the employee still being at it.
+// Designation and salary changes take effect on effective, today when it is zero.
+func UpdateEmployeeAPI(ctx context.Context, store EmployeeStore, id int, emp *Employee, version int, effective time.Time) (*Employee, error) {
+	emp.ID = 0
+	if emp.Currency == "" {
+		emp.Currency = defaultCurrency
+	}
+	if errs := ValidateStruct(emp, nil, nil); len(errs) > 0 {
+		return nil, NewValidationError(errs)
+	}
+
+	return updateEmployee(ctx, store, id, emp, replaceEmployeeFields, version, effective)
+}
+
+// PatchEmployeeAPI writes only the named fields of emp, leaving the rest of the employee as stored
+func PatchEmployeeAPI(ctx context.Context, store EmployeeStore, id int, emp *Employee, fields []string, version int, effective time.Time) (*Employee, error) {
+	if errs := ValidateStruct(emp, fields, nil); len(errs) > 0 {
+		return nil, NewValidationError(errs)
+	}
+
+	// Server-managed fields in the patch are ignored, as they are on a full replacement
+	return updateEmployee(ctx, store, id, emp, updatableEmployeeFields(fields), version, effective)
+}
+
+// JSONPatchEmployeeAPI applies a JSON patch to the stored employee and saves the result
+// through the same path as a full replacement
+func JSONPatchEmployeeAPI(ctx context.Context, store EmployeeStore, id int, ops []PatchOperation, version int, effective time.Time) (*Employee, error) {
+	current, err := ReadEmployeeAPI(ctx, store, id)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && current.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	emp, err := patchedEmployee(current, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	// The patch was applied to the version just read, so only write over that version
+	return UpdateEmployeeAPI(ctx, store, id, emp, current.Version, effective)
+}
+
+func updateEmployee(ctx context.Context, store EmployeeStore, id int, emp *Employee, fields []string, version int, effective time.Time) (*Employee, error) {
+	if effective.IsZero() {
+		effective = today()
 	}
 
-	// If we reach this point, it means no result was received before the timeout
-	return nil, errors.New("no result received before timeout")
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.Update)
+	defer cancel()
+
+	emp, err := store.UpdateEmployee(ctx, id, emp, fields, version, effective)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutUpdatingEmployee)
+	}
+	return emp, nil
+}
+
+func DeleteEmployeeAPI(ctx context.Context, store EmployeeStore, id int, version int) error {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.Delete)
+	defer cancel()
+
+	err := store.DeleteEmployee(ctx, id, version)
+	return timeoutError(ctx, err, ErrTimeoutDeletingEmployee)
+}
+
+func CreateDepartmentAPI(ctx context.Context, store EmployeeStore, dept *Department) (*Department, error) {
+	// IDs are always allocated by the store, so a caller-supplied one is dropped
+	dept.ID = 0
+	if errs := ValidateStruct(dept, nil, nil); len(errs) > 0 {
+		return nil, NewValidationError(errs)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.Create)
+	defer cancel()
+
+	if err := store.CreateDepartment(ctx, dept); err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutDepartment)
+	}
+	return dept, nil
+}
+
+func ReadDepartmentAPI(ctx context.Context, store EmployeeStore, id int) (*Department, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.Read)
+	defer cancel()
+
+	dept, err := store.ReadDepartment(ctx, id)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutDepartment)
+	}
+	return dept, nil
+}
+
+// ReadDepartmentListAPI returns every department, ordered by ID
+func ReadDepartmentListAPI(ctx context.Context, store EmployeeStore) ([]Department, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.List)
+	defer cancel()
+
+	depts, err := store.ReadDepartmentList(ctx)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutDepartment)
+	}
+	if depts == nil {
+		depts = []Department{}
+	}
+	return depts, nil
+}
+
+func UpdateDepartmentAPI(ctx context.Context, store EmployeeStore, id int, dept *Department) (*Department, error) {
+	dept.ID = 0
+	if errs := ValidateStruct(dept, nil, nil); len(errs) > 0 {
+		return nil, NewValidationError(errs)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.Update)
+	defer cancel()
+
+	updated, err := store.UpdateDepartment(ctx, id, dept)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutDepartment)
+	}
+	return updated, nil
 }
 
-func UpdateEmployeeAPI(db *sql.DB, id int, emp *Employee) (*Employee, error) {
-	// Channel to receive errors from the store operation
-	errChan := make(chan error, 1)
-	empChan := make(chan *Employee, 1)
+// DeleteDepartmentAPI removes a department that no employee belongs to any more
+func DeleteDepartmentAPI(ctx context.Context, store EmployeeStore, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.Delete)
+	defer cancel()
 
-	// Asynchronously call the ReadEmployeeStore function
-	go func() {
-		emp, err := UpdateEmployeeStore(db, id, emp)
+	err := store.DeleteDepartment(ctx, id)
+	return timeoutError(ctx, err, ErrTimeoutDepartment)
+}
+
+// ReadSubordinatesAPI returns the employees below id, up to maxDepth levels down
+func ReadSubordinatesAPI(ctx context.Context, store EmployeeStore, id int, maxDepth int) (*OrgEntryList, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.Read)
+	defer cancel()
+
+	// An unknown employee is a 404 rather than an empty list
+	if _, err := store.ReadEmployee(ctx, id); err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutHierarchy)
+	}
+
+	// Walk one level further to learn whether anyone is left beyond maxDepth
+	entries, err := store.ReadSubordinates(ctx, id, maxDepth+1)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutHierarchy)
+	}
+	list := &OrgEntryList{Items: []OrgEntry{}, MaxDepth: maxDepth}
+	for _, entry := range entries {
+		if entry.Depth > maxDepth {
+			list.Truncated = true
+			break
+		}
+		list.Items = append(list.Items, entry)
+	}
+	return list, nil
+}
+
+// ReadManagerChainAPI returns the managers above id, up to maxDepth levels up. Unless the
+// list is truncated its last item is the top of the organisation.
+func ReadManagerChainAPI(ctx context.Context, store EmployeeStore, id int, maxDepth int) (*OrgEntryList, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.Read)
+	defer cancel()
+
+	if _, err := store.ReadEmployee(ctx, id); err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutHierarchy)
+	}
+
+	entries, err := store.ReadManagerChain(ctx, id, maxDepth+1)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutHierarchy)
+	}
+	list := &OrgEntryList{Items: []OrgEntry{}, MaxDepth: maxDepth}
+	if len(entries) > maxDepth {
+		entries = entries[:maxDepth]
+		list.Truncated = true
+	}
+	list.Items = append(list.Items, entries...)
+	return list, nil
+}
+
+// OrgChartAPI builds the org chart opts asks for
+func OrgChartAPI(ctx context.Context, store EmployeeStore, opts OrgChartOptions) (*OrgChart, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.List)
+	defer cancel()
+
+	roots, err := orgChartRoots(ctx, store, opts.RootID)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutHierarchy)
+	}
+
+	departments := map[int]string{}
+	if opts.Department {
+		depts, err := store.ReadDepartmentList(ctx)
 		if err != nil {
-			errChan <- err
-			return
+			return nil, timeoutError(ctx, err, ErrTimeoutHierarchy)
+		}
+		for _, dept := range depts {
+			departments[dept.ID] = dept.Name
+		}
+	}
+	node := func(emp *Employee) *OrgChartNode {
+		n := &OrgChartNode{ID: emp.ID, Name: emp.Name, Reports: []*OrgChartNode{}}
+		if opts.Designation {
+			n.Designation = emp.Designation
+		}
+		if opts.Department && emp.DepartmentID != nil {
+			n.Department = departments[*emp.DepartmentID]
 		}
-		empChan <- emp
-	}()
+		return n
+	}
+
+	chart := &OrgChart{Roots: []*OrgChartNode{}, MaxDepth: opts.MaxDepth}
+	for i := range roots {
+		root := node(&roots[i])
+		chart.Roots = append(chart.Roots, root)
+
+		// Entries come level by level, so every manager is placed before its reports.
+		// The extra level only shows which employees have reports left out.
+		entries, err := store.ReadSubordinates(ctx, root.ID, opts.MaxDepth+1)
+		if err != nil {
+			return nil, timeoutError(ctx, err, ErrTimeoutHierarchy)
+		}
+		nodes := map[int]*OrgChartNode{root.ID: root}
+		for j := range entries {
+			entry := &entries[j]
+			manager := nodes[*entry.ManagerID]
+			if entry.Depth > opts.MaxDepth {
+				manager.Truncated = true
+				continue
+			}
+			nodes[entry.ID] = node(&entry.Employee)
+			manager.Reports = append(manager.Reports, nodes[entry.ID])
+		}
+	}
+	return chart, nil
+}
 
-	// Wait for either a timeout or an error from the store operation
-	select {
-	case <-time.After(5 * time.Second): // Timeout after 5 seconds
-		return nil, ErrTimeoutUpdatingEmployee
-	case err := <-errChan:
+// orgChartRoots reads the employee rootID, or every employee without a manager when it is nil
+func orgChartRoots(ctx context.Context, store EmployeeStore, rootID *int) ([]Employee, error) {
+	if rootID != nil {
+		emp, err := store.ReadEmployee(ctx, *rootID)
 		if err != nil {
 			return nil, err
 		}
-	case emp := <-empChan:
-		return emp, nil
+		return []Employee{*emp}, nil
+	}
+
+	var roots []Employee
+	q := EmployeeListQuery{Filter: EmployeeFilter{NoManager: true}, Limit: listLimits.MaxLimit}
+	for {
+		page, err := store.ReadEmployeeList(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, page...)
+		if len(page) < q.Limit {
+			return roots, nil
+		}
+		q.Cursor = newEmployeeCursor(q.Sort, &page[len(page)-1], false)
+	}
+}
+
+// PutExchangeRateAPI records rate, replacing any rate of its pair on the same date
+func PutExchangeRateAPI(ctx context.Context, store EmployeeStore, rate *ExchangeRate) (*ExchangeRate, error) {
+	if rate.From == rate.To {
+		return nil, NewValidationError([]FieldError{{Field: "to", Message: "must differ from the from currency"}})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.Update)
+	defer cancel()
+
+	if err := store.PutExchangeRate(ctx, rate); err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutExchangeRate)
+	}
+	return rate, nil
+}
+
+// ReadExchangeRatesAPI lists the recorded rates matching f by pair and effective date
+func ReadExchangeRatesAPI(ctx context.Context, store EmployeeStore, f ExchangeRateFilter) ([]ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.List)
+	defer cancel()
+
+	rates, err := store.ReadExchangeRates(ctx, f)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutExchangeRate)
+	}
+	if rates == nil {
+		rates = []ExchangeRate{}
+	}
+	return rates, nil
+}
+
+// ReadEffectiveExchangeRateAPI returns the rate of the pair in effect on date
+func ReadEffectiveExchangeRateAPI(ctx context.Context, store EmployeeStore, from, to string, date time.Time) (*ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.Read)
+	defer cancel()
+
+	rate, err := store.ReadEffectiveExchangeRate(ctx, from, to, date)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutExchangeRate)
+	}
+	return rate, nil
+}
+
+// DeleteExchangeRateAPI removes the rate the pair took effect with on date
+func DeleteExchangeRateAPI(ctx context.Context, store EmployeeStore, from, to string, date time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.Delete)
+	defer cancel()
+
+	err := store.DeleteExchangeRate(ctx, from, to, date)
+	return timeoutError(ctx, err, ErrTimeoutExchangeRate)
+}
+
+// ReadEmployeeHistoryAPI returns the timeline of the employee's designation and salary
+func ReadEmployeeHistoryAPI(ctx context.Context, store EmployeeStore, id int) (*EmployeeHistory, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.Read)
+	defer cancel()
+
+	// Every employee has a history from the day it was created, and keeps it once deleted
+	entries, err := store.ReadHistory(ctx, id)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutHistory)
+	}
+	if len(entries) == 0 {
+		return nil, ErrEmployeeNotFound
+	}
+
+	history := &EmployeeHistory{Items: []HistoryItem{}}
+	now := today()
+	for i, entry := range entries {
+		position, _ := positionAt(entries[:i+1], entry.EffectiveFrom)
+		item := HistoryItem{
+			EffectiveFrom: entry.EffectiveFrom.Format(time.DateOnly),
+			Designation:   position.Designation,
+			Salary:        position.Salary,
+			Currency:      position.Currency,
+			Changed:       []string{},
+			RecordedAt:    entry.RecordedAt,
+			Scheduled:     entry.EffectiveFrom.After(now),
+		}
+		if entry.Designation != nil {
+			item.Changed = append(item.Changed, "designation")
+		}
+		if entry.Salary != nil {
+			item.Changed = append(item.Changed, "salary", "currency")
+		}
+		history.Items = append(history.Items, item)
 	}
+	return history, nil
+}
+
+// ReadEmployeeAsOfAPI returns the employee as it was on asOf, rebuilt from its snapshots and
+// history; see employeeAsOf
+func ReadEmployeeAsOfAPI(ctx context.Context, store EmployeeStore, id int, asOf time.Time) (*Employee, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.Read)
+	defer cancel()
 
-	// If we reach this point, it means no result was received before the timeout
-	return nil, errors.New("no result received before timeout")
+	snapshots, err := store.ReadSnapshots(ctx, id)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutReadingEmployee)
+	}
+	entries, err := store.ReadHistory(ctx, id)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutReadingEmployee)
+	}
+	past, err := employeeAsOf(snapshots, entries, asOf)
+	if err != nil {
+		return nil, err
+	}
+	return &past, nil
 }
 
-func DeleteEmployeeAPI(db *sql.DB, id int) error {
-	// Channel to receive errors from the store operation
-	errChan := make(chan error, 1)
+// ReadAuditLogAPI returns one page of the audit log
+func ReadAuditLogAPI(ctx context.Context, store EmployeeStore, q AuditQuery) (*AuditPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiTimeouts.List)
+	defer cancel()
 
-	// Asynchronously call the ReadEmployeeStore function
-	go func() {
-		err := DeleteEmployeeStore(db, id)
-		errChan <- err
-	}()
+	// Read one entry more than asked to learn whether there is another page
+	probe := q
+	probe.Limit++
+	entries, err := store.ReadAuditLog(ctx, probe)
+	if err != nil {
+		return nil, timeoutError(ctx, err, ErrTimeoutAudit)
+	}
+
+	page := &AuditPage{Items: []AuditEntry{}, Limit: q.Limit}
+	if len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+		page.NextAfterID = entries[q.Limit-1].ID
+	}
+	page.Items = append(page.Items, entries...)
+	return page, nil
+}
+
+// auditVerifyBatch is how many entries VerifyAuditLogAPI reads at a time
+var auditVerifyBatch = 1000
+
+// VerifyAuditLogAPI walks the audit log after the entry afterID and checks that every entry
+// follows the one before it and still matches its hash. The last hash can be kept elsewhere
+// to later prove that no entries were cut off the end either.
+//
+// Every batch gets the list timeout, and the walk stops once apiTimeouts.AuditVerify has
+// passed, returning NextAfterID so that a large log is verified over several calls.
+func VerifyAuditLogAPI(ctx context.Context, store EmployeeStore, afterID int64) (*AuditVerification, error) {
+	deadline := time.Now().Add(apiTimeouts.AuditVerify)
+	readBatch := func(q AuditQuery) ([]AuditEntry, error) {
+		ctx, cancel := context.WithTimeout(ctx, apiTimeouts.List)
+		defer cancel()
+		entries, err := store.ReadAuditLog(ctx, q)
+		if err != nil {
+			return nil, timeoutError(ctx, err, ErrTimeoutAudit)
+		}
+		return entries, nil
+	}
 
-	// Wait for either a timeout or an error from the store operation
-	select {
-	case <-time.After(5 * time.Second): // Timeout after 5 seconds
-		return ErrTimeoutDeletingEmployee
-	case err := <-errChan:
-		return err
+	result := &AuditVerification{Verified: true, LastHash: genesisAuditHash}
+
+	// A resumed walk links on to the entry it stopped at
+	if afterID > 0 {
+		entries, err := readBatch(AuditQuery{AfterID: afterID - 1, Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			return nil, NewInvalidQueryError([]FieldError{{Field: "afterId", Message: "must be an audit entry ID"}})
+		}
+		if entries[0].ID != afterID {
+			result.Verified, result.BrokenAt = false, afterID
+			result.Problem = fmt.Sprintf("entry %d is missing", afterID)
+			return result, nil
+		}
+		result.LastHash = entries[0].Hash
+	}
+
+	q := AuditQuery{AfterID: afterID, Limit: auditVerifyBatch}
+	for {
+		entries, err := readBatch(q)
+		if err != nil {
+			return nil, err
+		}
+		for i := range entries {
+			entry := &entries[i]
+			switch {
+			case entry.ID != q.AfterID+1:
+				result.Problem = fmt.Sprintf("entry %d follows entry %d", entry.ID, q.AfterID)
+			case entry.PrevHash != result.LastHash:
+				result.Problem = "previous hash does not match the entry before"
+			case entry.digest() != entry.Hash:
+				result.Problem = "hash does not match the entry"
+			}
+			if result.Problem != "" {
+				result.Verified, result.BrokenAt = false, entry.ID
+				return result, nil
+			}
+			result.Entries++
+			result.LastHash = entry.Hash
+			q.AfterID = entry.ID
+		}
+		if len(entries) < q.Limit {
+			return result, nil
+		}
+		if !time.Now().Before(deadline) {
+			result.NextAfterID = q.AfterID
+			return result, nil
+		}
 	}
 }
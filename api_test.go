@@ -1,67 +1,32 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"reflect"
 	"testing"
 	"time"
-
-	_ "github.com/lib/pq"
 )
 
-// Helper function to initialize a test database
-func initTestDB(t *testing.T) *sql.DB {
-	const (
-		host     = "localhost"
-		port     = 5432
-		user     = "postgres"
-		password = "9101996"
-		dbname   = "testDB" // Use a different database for testing
-	)
-
-	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
-
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		t.Fatalf("Error connecting to the test database: %v", err)
-	}
-
-	return db
+// Helper function to initialize an in-memory test store
+func initTestStore(t *testing.T) *MemoryEmployeeStore {
+	t.Helper()
+	return NewMemoryEmployeeStore()
 }
 
-// CreateTableEmployee creates the employee table
-func CreateTableEmployee(db *sql.DB) error {
-	createTableSQL := `
-        CREATE TABLE IF NOT EXISTS employee (
-            ID SERIAL PRIMARY KEY,
-            Name VARCHAR(100) NOT NULL,
-            Designation VARCHAR(100) NOT NULL,
-            Salary FLOAT8 NOT NULL,
-            CreatedAt TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-            UpdatedAt TIMESTAMPTZ NOT NULL DEFAULT NOW()
-        );
-    `
-
-	// Execute the SQL statement to create the table
-	_, err := db.Exec(createTableSQL)
+// mustMoney parses a literal amount for test tables
+func mustMoney(s string) Money {
+	m, err := ParseMoney(s)
 	if err != nil {
-		return fmt.Errorf("Unable to create employee table: %v", err)
+		panic(err)
 	}
-
-	return nil
+	return m
 }
 
-// InsertTableEmployee inserts the employee table
-func InsertTableEmployee(db *sql.DB, employees []Employee) error {
-	insertSQL := `
-        INSERT INTO employee (Name, Designation, Salary, CreatedAt, UpdatedAt)
-        VALUES ($1, $2, $3, NOW(), NOW())
-        RETURNING ID;
-    `
-
-	for i, emp := range employees {
-		err := db.QueryRow(insertSQL, emp.Name, emp.Designation, emp.Salary).Scan(&employees[i].ID)
+// InsertTableEmployee inserts the employees into the store
+func InsertTableEmployee(store EmployeeStore, employees []Employee) error {
+	for i := range employees {
+		err := store.CreateEmployee(context.Background(), &employees[i])
 		if err != nil {
 			return fmt.Errorf("Unable to insert employee: %v", err)
 		}
@@ -69,33 +34,18 @@ func InsertTableEmployee(db *sql.DB, employees []Employee) error {
 	return nil
 }
 
-// DeleteTableEmployee deletes the employee table from the provided database
-func DeleteTableEmployee(db *sql.DB) error {
-	// SQL statement to delete the employee table
-	deleteTableSQL := `DROP TABLE IF EXISTS employee;`
-
-	// Execute the SQL statement to delete the table
-	_, err := db.Exec(deleteTableSQL)
-	if err != nil {
-		return fmt.Errorf("Unable to delete employee table: %v", err)
-	}
-
-	return nil
+// DeleteTableEmployee removes every employee from the provided store
+func DeleteTableEmployee(store *MemoryEmployeeStore) {
+	store.reset()
 }
 
 func TestCreateEmployeeAPI(t *testing.T) {
 
-	// Set up a test database connection
-	db := initTestDB(t)
-	defer db.Close()
-
-	err := CreateTableEmployee(db)
-	if err != nil {
-		t.Fatalf("Unable to create employee table: %v", err)
-	}
+	// Set up an in-memory test store
+	store := initTestStore(t)
 	type args struct {
-		db  *sql.DB
-		emp *Employee
+		store EmployeeStore
+		emp   *Employee
 	}
 	tests := []struct {
 		name    string
@@ -106,12 +56,12 @@ func TestCreateEmployeeAPI(t *testing.T) {
 		{
 			name: "Successfull Creation of employee",
 			args: args{
-				db: db,
+				store: store,
 				emp: &Employee{
 					ID:          1,
 					Name:        "John Doe",
 					Designation: "Engineer",
-					Salary:      50000,
+					Salary:      mustMoney("50000"),
 					CreatedAt:   time.Now(),
 					UpdatedAt:   time.Now(),
 				},
@@ -120,37 +70,47 @@ func TestCreateEmployeeAPI(t *testing.T) {
 				ID:          1,
 				Name:        "John Doe",
 				Designation: "Engineer",
-				Salary:      50000,
+				Salary:      mustMoney("50000"),
+				Currency:    "USD",
 				CreatedAt:   time.Now(),
 				UpdatedAt:   time.Now(),
+				Version:     1,
 			},
 			wantErr: false,
 		},
 		{
-			name: "Error Creating Duplicate Employee",
+			name: "Creating employee with a taken ID allocates a new one",
 			args: args{
-				db: db,
+				store: store,
 				emp: &Employee{
 					ID:          1,
 					Name:        "John Doe",
 					Designation: "Engineer",
-					Salary:      50000,
+					Salary:      mustMoney("50000"),
+					Currency:    "EUR",
 					CreatedAt:   time.Now(),
 					UpdatedAt:   time.Now(),
 				},
 			},
-			want:    nil,
-			wantErr: true,
+			want: &Employee{
+				ID:          2,
+				Name:        "John Doe",
+				Designation: "Engineer",
+				Salary:      mustMoney("50000"),
+				Currency:    "EUR",
+				Version:     1,
+			},
+			wantErr: false,
 		},
 		{
 			name: "Error Creating Employe without name",
 			args: args{
-				db: db,
+				store: store,
 				emp: &Employee{
 					ID:          1,
 					Name:        "",
 					Designation: "Engineer",
-					Salary:      50000,
+					Salary:      mustMoney("50000"),
 					CreatedAt:   time.Now(),
 					UpdatedAt:   time.Now(),
 				},
@@ -162,12 +122,12 @@ func TestCreateEmployeeAPI(t *testing.T) {
 		{
 			name: "Error Creating Employee without designation",
 			args: args{
-				db: db,
+				store: store,
 				emp: &Employee{
 					ID:          1,
 					Name:        "Kiran",
 					Designation: "",
-					Salary:      50000,
+					Salary:      mustMoney("50000"),
 					CreatedAt:   time.Now(),
 					UpdatedAt:   time.Now(),
 				},
@@ -179,12 +139,12 @@ func TestCreateEmployeeAPI(t *testing.T) {
 		{
 			name: "Error Creating Employee without createdAt time",
 			args: args{
-				db: db,
+				store: store,
 				emp: &Employee{
 					ID:          1,
 					Name:        "Kiran",
 					Designation: "",
-					Salary:      12340,
+					Salary:      mustMoney("12340"),
 					UpdatedAt:   time.Now(),
 				},
 			},
@@ -195,14 +155,13 @@ func TestCreateEmployeeAPI(t *testing.T) {
 	// Inside the for loop of the TestCreateEmployeeAPI function
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := CreateEmployeeAPI(tt.args.db, tt.args.emp)
+			got, err := CreateEmployeeAPI(context.Background(), tt.args.store, tt.args.emp)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CreateEmployeeAPI() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
 			if tt.want != nil {
-				tt.want.ID = got.ID
 				tt.want.CreatedAt = got.CreatedAt
 				tt.want.UpdatedAt = got.UpdatedAt
 			}
@@ -211,37 +170,29 @@ func TestCreateEmployeeAPI(t *testing.T) {
 				t.Errorf("CreateEmployeeAPI() = %v, want %v", got, tt.want)
 			}
 		})
-
-		// Clean up the test database after each test case
-		DeleteTableEmployee(db)
 	}
 
+	// Clean up the test store once every case has run so IDs keep increasing
+	DeleteTableEmployee(store)
 }
 
 func TestReadEmployeeAPI(t *testing.T) {
-	// Set up a test database connection
-	db := initTestDB(t)
-	defer db.Close()
-
-	err := CreateTableEmployee(db)
-	if err != nil {
-		t.Fatalf("Unable to create employee table: %v", err)
-	}
+	// Set up an in-memory test store
+	store := initTestStore(t)
 
 	// Prepare test data
 	employees := []Employee{
-		{Name: "Dan", Designation: "Software Developer", Salary: 23456.00},
+		{Name: "Dan", Designation: "Software Developer", Salary: mustMoney("23456.00")},
 	}
 
-	err = InsertTableEmployee(db, employees)
+	err := InsertTableEmployee(store, employees)
 	if err != nil {
 		t.Fatalf("Unable to insert employee table: %v", err)
 	}
 
 	type args struct {
-		db  *sql.DB
-		id  int
-		emp *Employee
+		store EmployeeStore
+		id    int
 	}
 	tests := []struct {
 		name    string
@@ -252,26 +203,25 @@ func TestReadEmployeeAPI(t *testing.T) {
 		{
 			name: "Successfully read employee",
 			args: args{
-				db:  db,
-				id:  employees[0].ID,
-				emp: &Employee{},
+				store: store,
+				id:    employees[0].ID,
 			},
 			want: &Employee{
 				ID:          1,
 				Name:        "Dan",
 				Designation: "Software Developer",
-				Salary:      23456.00,
+				Salary:      mustMoney("23456.00"),
 				CreatedAt:   time.Now(),
 				UpdatedAt:   time.Now(),
+				Version:     1,
 			},
 			wantErr: false,
 		},
 		{
 			name: "Read employee with invalid id",
 			args: args{
-				db:  db,
-				id:  2,
-				emp: &Employee{},
+				store: store,
+				id:    2,
 			},
 			want:    nil,
 			wantErr: true,
@@ -279,8 +229,7 @@ func TestReadEmployeeAPI(t *testing.T) {
 		{
 			name: "Read employee with no id",
 			args: args{
-				db:  db,
-				emp: &Employee{},
+				store: store,
 			},
 			want:    nil,
 			wantErr: true,
@@ -289,8 +238,8 @@ func TestReadEmployeeAPI(t *testing.T) {
 		{
 			name: "Read employee with no response",
 			args: args{
-				db: db,
-				id: 1,
+				store: store,
+				id:    1,
 			},
 			want:    nil,
 			wantErr: true,
@@ -298,7 +247,7 @@ func TestReadEmployeeAPI(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := ReadEmployeeAPI(tt.args.db, tt.args.id, tt.args.emp)
+			got, err := ReadEmployeeAPI(context.Background(), tt.args.store, tt.args.id)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ReadEmployeeAPI() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -311,38 +260,32 @@ func TestReadEmployeeAPI(t *testing.T) {
 				t.Errorf("ReadEmployeeAPI() = %v, want %v", got, tt.want)
 			}
 		})
-		// Clean up the test database after each test case
-		DeleteTableEmployee(db)
+		// Clean up the test store after each test case
+		DeleteTableEmployee(store)
 	}
 
 }
 
 func TestReadEmployeeListAPI(t *testing.T) {
 
-	// Set up a test database connection
-	db := initTestDB(t)
-	defer db.Close()
-
-	err := CreateTableEmployee(db)
-	if err != nil {
-		t.Fatalf("Unable to create employee table: %v", err)
-	}
+	// Set up an in-memory test store
+	store := initTestStore(t)
 
 	// Prepare test data
 	employees := []Employee{
-		{Name: "Sen", Designation: "Account Manager", Salary: 44566.00},
-		{Name: "Dan", Designation: "Software Developer", Salary: 23456.00},
+		{Name: "Sen", Designation: "Account Manager", Salary: mustMoney("44566.00")},
+		{Name: "Dan", Designation: "Software Developer", Salary: mustMoney("23456.00")},
 	}
 
-	err = InsertTableEmployee(db, employees)
+	err := InsertTableEmployee(store, employees)
 	if err != nil {
 		t.Fatalf("Unable to insert employee table: %v", err)
 	}
 
 	type args struct {
-		db     *sql.DB
+		store  EmployeeStore
 		limit  int
-		offset int
+		cursor *EmployeeCursor
 	}
 	tests := []struct {
 		name    string
@@ -354,56 +297,56 @@ func TestReadEmployeeListAPI(t *testing.T) {
 		{
 			name: "Successfully read employee list",
 			args: args{
-				db:     db,
-				limit:  2,
-				offset: 0,
+				store: store,
+				limit: 2,
 			},
 			want: []Employee{
 				Employee{
 					ID:          employees[0].ID,
 					Name:        "Sen",
 					Designation: "Account Manager",
-					Salary:      44566.00,
+					Salary:      mustMoney("44566.00"),
 					CreatedAt:   time.Now(),
 					UpdatedAt:   time.Now(),
+					Version:     1,
 				},
 				Employee{
 					ID:          employees[1].ID,
 					Name:        "Dan",
 					Designation: "Software Developer",
-					Salary:      23456.00,
+					Salary:      mustMoney("23456.00"),
 					CreatedAt:   time.Now(),
 					UpdatedAt:   time.Now(),
+					Version:     1,
 				},
 			},
 			wantErr: false,
 		},
 		{
-			name: "Read employee list with invalid offset value",
+			name: "Read employee list past the last page",
 			args: args{
-				db:     db,
+				store:  store,
 				limit:  2,
-				offset: 2,
+				cursor: &EmployeeCursor{Last: Employee{ID: employees[1].ID}},
 			},
-			want:    nil,
-			wantErr: true,
+			want:    []Employee{},
+			wantErr: false,
 		},
 		{
 			name: "Read employee list with invalid limit value",
 			args: args{
-				db:     db,
+				store:  store,
 				limit:  0,
-				offset: 2,
+				cursor: &EmployeeCursor{Last: Employee{ID: employees[0].ID}},
 			},
 			want:    nil,
 			wantErr: true,
 		},
 		{
-			name: "Read employee list with invalid limit and offset value",
+			name: "Read employee list with invalid limit and no cursor",
 			args: args{
-				db:     db,
-				limit:  0,
-				offset: 0,
+				store: store,
+				limit: 0,
 			},
 			want:    nil,
 			wantErr: true,
@@ -411,11 +354,15 @@ func TestReadEmployeeListAPI(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := ReadEmployeeListAPI(tt.args.db, tt.args.limit, tt.args.offset)
+			page, err := ReadEmployeeListAPI(context.Background(), tt.args.store, EmployeeListQuery{Limit: tt.args.limit, Cursor: tt.args.cursor}, TotalNone)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ReadEmployeeListAPI() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			var got []Employee
+			if page != nil {
+				got = page.Items
+			}
 			for i := range tt.want {
 				tt.want[i].ID = got[i].ID
 				tt.want[i].CreatedAt = got[i].CreatedAt
@@ -425,37 +372,31 @@ func TestReadEmployeeListAPI(t *testing.T) {
 				t.Errorf("ReadEmployeeListAPI() = %v, want %v", got, tt.want)
 			}
 		})
-		// Clean up the test database after each test case
+		// Clean up the test store after each test case
 	}
-	DeleteTableEmployee(db)
+	DeleteTableEmployee(store)
 
 }
 
 func TestUpdateEmployeeAPI(t *testing.T) {
 
-	// Set up a test database connection
-	db := initTestDB(t)
-	defer db.Close()
-
-	err := CreateTableEmployee(db)
-	if err != nil {
-		t.Fatalf("Unable to create employee table: %v", err)
-	}
+	// Set up an in-memory test store
+	store := initTestStore(t)
 
 	// Prepare test data
 	employees := []Employee{
-		{Name: "Dan", Designation: "Software Developer", Salary: 23456.00},
+		{Name: "Dan", Designation: "Software Developer", Salary: mustMoney("23456.00")},
 	}
 
-	err = InsertTableEmployee(db, employees)
+	err := InsertTableEmployee(store, employees)
 	if err != nil {
 		t.Fatalf("Unable to insert employee table: %v", err)
 	}
 
 	type args struct {
-		db  *sql.DB
-		id  int
-		emp *Employee
+		store EmployeeStore
+		id    int
+		emp   *Employee
 	}
 	tests := []struct {
 		name    string
@@ -466,13 +407,13 @@ func TestUpdateEmployeeAPI(t *testing.T) {
 		{
 			name: "Successfully update employee",
 			args: args{
-				db: db,
-				id: employees[0].ID,
+				store: store,
+				id:    employees[0].ID,
 				emp: &Employee{
 					ID:          employees[0].ID,
 					Name:        "Dan",
 					Designation: "Software Developer",
-					Salary:      11111.00,
+					Salary:      mustMoney("11111.00"),
 					CreatedAt:   time.Now(),
 					UpdatedAt:   time.Now(),
 				},
@@ -481,22 +422,24 @@ func TestUpdateEmployeeAPI(t *testing.T) {
 				ID:          employees[0].ID,
 				Name:        "Dan",
 				Designation: "Software Developer",
-				Salary:      11111.00,
+				Salary:      mustMoney("11111.00"),
+				Currency:    "USD",
 				CreatedAt:   time.Now(),
 				UpdatedAt:   time.Now(),
+				Version:     2,
 			},
 			wantErr: false,
 		},
 		{
 			name: "Update employee with invalid id",
 			args: args{
-				db: db,
-				id: 2,
+				store: store,
+				id:    2,
 				emp: &Employee{
 					ID:          employees[0].ID,
 					Name:        "Dan",
 					Designation: "Software Developer",
-					Salary:      11111.00,
+					Salary:      mustMoney("11111.00"),
 					CreatedAt:   time.Now(),
 					UpdatedAt:   time.Now(),
 				},
@@ -507,13 +450,13 @@ func TestUpdateEmployeeAPI(t *testing.T) {
 		{
 			name: "Update employee with invalid payload",
 			args: args{
-				db: db,
-				id: employees[0].ID,
+				store: store,
+				id:    employees[0].ID,
 				emp: &Employee{
 					ID:          2,
 					Name:        "Ben",
 					Designation: "Software Developer",
-					Salary:      23333.00,
+					Salary:      mustMoney("23333.00"),
 					CreatedAt:   time.Now(),
 					UpdatedAt:   time.Now(),
 				},
@@ -524,13 +467,13 @@ func TestUpdateEmployeeAPI(t *testing.T) {
 		{
 			name: "Update employee with invalid payload and id",
 			args: args{
-				db: db,
-				id: 3,
+				store: store,
+				id:    3,
 				emp: &Employee{
 					ID:          2,
 					Name:        "Ben",
 					Designation: "Software Developer",
-					Salary:      23333.00,
+					Salary:      mustMoney("23333.00"),
 					CreatedAt:   time.Now(),
 					UpdatedAt:   time.Now(),
 				},
@@ -541,7 +484,7 @@ func TestUpdateEmployeeAPI(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := UpdateEmployeeAPI(tt.args.db, tt.args.id, tt.args.emp)
+			got, err := UpdateEmployeeAPI(context.Background(), tt.args.store, tt.args.id, tt.args.emp, 0, time.Time{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("UpdateEmployeeAPI() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -554,33 +497,27 @@ func TestUpdateEmployeeAPI(t *testing.T) {
 				t.Errorf("UpdateEmployeeAPI() = %v, want %v", got, tt.want)
 			}
 		})
-		DeleteTableEmployee(db)
+		DeleteTableEmployee(store)
 
 	}
 }
 
 func TestDeleteEmployeeAPI(t *testing.T) {
-	// Set up a test database connection
-	db := initTestDB(t)
-	defer db.Close()
-
-	err := CreateTableEmployee(db)
-	if err != nil {
-		t.Fatalf("Unable to create employee table: %v", err)
-	}
+	// Set up an in-memory test store
+	store := initTestStore(t)
 
 	// Prepare test data
 	employees := []Employee{
-		{Name: "Dan", Designation: "Software Developer", Salary: 23456.00},
+		{Name: "Dan", Designation: "Software Developer", Salary: mustMoney("23456.00")},
 	}
 
-	err = InsertTableEmployee(db, employees)
+	err := InsertTableEmployee(store, employees)
 	if err != nil {
 		t.Fatalf("Unable to insert employee table: %v", err)
 	}
 	type args struct {
-		db *sql.DB
-		id int
+		store EmployeeStore
+		id    int
 	}
 	tests := []struct {
 		name    string
@@ -590,25 +527,65 @@ func TestDeleteEmployeeAPI(t *testing.T) {
 		{
 			name: "Successfully delete employee",
 			args: args{
-				db: db,
-				id: employees[0].ID,
+				store: store,
+				id:    employees[0].ID,
 			},
 			wantErr: false,
 		},
 		{
 			name: "Delete employee with invalid id",
 			args: args{
-				db: db,
-				id: 2,
+				store: store,
+				id:    2,
 			},
 			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := DeleteEmployeeAPI(tt.args.db, tt.args.id); (err != nil) != tt.wantErr {
+			if err := DeleteEmployeeAPI(context.Background(), tt.args.store, tt.args.id, 0); (err != nil) != tt.wantErr {
 				t.Errorf("DeleteEmployeeAPI() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+// blockingStore is an EmployeeStore whose reads only return once their context is done
+type blockingStore struct {
+	*MemoryEmployeeStore
+	cancelled chan error
+}
+
+func (s *blockingStore) ReadEmployee(ctx context.Context, id int) (*Employee, error) {
+	<-ctx.Done()
+	s.cancelled <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func TestReadEmployeeAPICancellation(t *testing.T) {
+	defer func(timeouts Timeouts) { apiTimeouts = timeouts }(apiTimeouts)
+	apiTimeouts.Read = 10 * time.Millisecond
+
+	store := &blockingStore{MemoryEmployeeStore: initTestStore(t), cancelled: make(chan error, 1)}
+
+	// The configured deadline cancels the store operation and is reported as a timeout
+	_, err := ReadEmployeeAPI(context.Background(), store, 1)
+	if err != ErrTimeoutReadingEmployee {
+		t.Errorf("ReadEmployeeAPI() error = %v, want %v", err, ErrTimeoutReadingEmployee)
+	}
+	if err := <-store.cancelled; err != context.DeadlineExceeded {
+		t.Errorf("store saw %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// A client disconnect cancels the store operation and is passed through
+	apiTimeouts.Read = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err = ReadEmployeeAPI(ctx, store, 1)
+	if err != context.Canceled {
+		t.Errorf("ReadEmployeeAPI() error = %v, want %v", err, context.Canceled)
+	}
+	if err := <-store.cancelled; err != context.Canceled {
+		t.Errorf("store saw %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// Audited resources
+const (
+	AuditEmployee     = "employee"
+	AuditDepartment   = "department"
+	AuditExchangeRate = "exchangeRate"
+)
+
+// ActorHeader names the user a request acts for. The service does no authentication of its
+// own, so it is trusted as set by the gateway in front of it.
+const ActorHeader = "X-Actor"
+
+// genesisAuditHash stands in for the hash before the first entry of the log
+var genesisAuditHash = strings.Repeat("0", 64)
+
+// auditSettings configures the audit log; main replaces it with the configured values
+var auditSettings AuditConfig
+
+var ErrTimeoutAudit = errors.New("timeout occurred while reading the audit log")
+
+// AuditEntry records one write: who made it, from where, and what it changed. Entries are
+// chained: each one's Hash covers its other members, PrevHash included, so changing or
+// removing an entry breaks the chain from there on.
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	RecordedAt time.Time `json:"recordedAt"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	Resource   string    `json:"resource"`
+	TargetID   string    `json:"targetId"`
+	// Changes maps every member the write changed to an AuditChange
+	Changes   json.RawMessage `json:"changes"`
+	RequestID string          `json:"requestId"`
+	ClientIP  string          `json:"clientIp"`
+	PrevHash  string          `json:"prevHash"`
+	Hash      string          `json:"hash"`
+}
+
+// AuditChange holds a member's JSON value before and after a write; null where it had none
+type AuditChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// digest returns the hex SHA-256 of the entry's JSON form without its Hash
+func (e *AuditEntry) digest() string {
+	unsealed := *e
+	unsealed.Hash = ""
+	encoded, _ := json.Marshal(&unsealed)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// chain appends the entry after prev, the last entry of the log or nil when it is empty,
+// giving it the next ID and sealing it with its hash
+func (e *AuditEntry) chain(prev *AuditEntry) {
+	e.ID, e.PrevHash = 1, genesisAuditHash
+	if prev != nil {
+		e.ID, e.PrevHash = prev.ID+1, prev.Hash
+	}
+	e.Hash = e.digest()
+}
+
+// AuditInfo identifies where a write came from
+type AuditInfo struct {
+	Actor     string
+	RequestID string
+	ClientIP  string
+}
+
+type auditInfoKey struct{}
+
+// schedulerActor is the actor of the writes the scheduled-change job makes
+const schedulerActor = "scheduler"
+
+// withSchedulerActor returns ctx for the writes of the scheduled-change job
+func withSchedulerActor(ctx context.Context) context.Context {
+	return context.WithValue(ctx, auditInfoKey{}, AuditInfo{Actor: schedulerActor})
+}
+
+// auditInfoFrom returns the AuditInfo AuditMiddleware gave the request; writes made outside
+// a request are the system's
+func auditInfoFrom(ctx context.Context) AuditInfo {
+	if info, ok := ctx.Value(auditInfoKey{}).(AuditInfo); ok {
+		return info
+	}
+	return AuditInfo{Actor: "system"}
+}
+
+// AuditMiddleware records who is making the request, for the audit entries of its writes.
+// It runs after RequestIDMiddleware.
+func AuditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Actors follow the rules of request IDs
+		actor := r.Header.Get(ActorHeader)
+		if !validRequestID(actor) {
+			actor = "anonymous"
+		}
+		info := AuditInfo{Actor: actor, RequestID: RequestIDFromContext(r.Context()), ClientIP: clientIP(r)}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), auditInfoKey{}, info)))
+	})
+}
+
+// clientIP returns the address the request came from: the first X-Forwarded-For hop when
+// the proxy setting it is trusted, the peer address otherwise
+func clientIP(r *http.Request) string {
+	if auditSettings.TrustForwardedFor {
+		if first, _, _ := strings.Cut(r.Header.Get("X-Forwarded-For"), ","); strings.TrimSpace(first) != "" {
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// newAuditEntry returns the unchained entry of a write to the resource targetID that turned
+// before into after; nil stands for the resource not existing
+func newAuditEntry(ctx context.Context, resource, action, targetID string, before, after any) (*AuditEntry, error) {
+	changes, err := auditChanges(before, after)
+	if err != nil {
+		return nil, err
+	}
+	info := auditInfoFrom(ctx)
+	return &AuditEntry{
+		// Databases keep microseconds, and the hash has to survive the round trip
+		RecordedAt: time.Now().UTC().Truncate(time.Microsecond),
+		Actor:      info.Actor,
+		Action:     action,
+		Resource:   resource,
+		TargetID:   targetID,
+		Changes:    changes,
+		RequestID:  info.RequestID,
+		ClientIP:   info.ClientIP,
+	}, nil
+}
+
+// auditChanges compares the JSON forms of before and after member by member
+func auditChanges(before, after any) (json.RawMessage, error) {
+	members := func(v any) (map[string]json.RawMessage, error) {
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var m map[string]json.RawMessage
+		err = json.Unmarshal(encoded, &m)
+		return m, err
+	}
+	old, err := members(before)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := members(after)
+	if err != nil {
+		return nil, err
+	}
+
+	null := json.RawMessage("null")
+	changes := map[string]AuditChange{}
+	for name, value := range updated {
+		previous, ok := old[name]
+		if !ok {
+			previous = null
+		}
+		if string(previous) != string(value) {
+			changes[name] = AuditChange{Before: previous, After: value}
+		}
+	}
+	for name, value := range old {
+		if _, ok := updated[name]; !ok {
+			changes[name] = AuditChange{Before: value, After: null}
+		}
+	}
+	return json.Marshal(changes)
+}
+
+// AuditFilter narrows the audit log; zero-valued fields do not filter
+type AuditFilter struct {
+	Actor     string
+	Action    string
+	Resource  string
+	TargetID  string
+	RequestID string
+	// The time range is inclusive
+	From time.Time
+	To   time.Time
+}
+
+// Matches reports whether entry passes every filter that is set
+func (f AuditFilter) Matches(entry *AuditEntry) bool {
+	switch {
+	case f.Actor != "" && entry.Actor != f.Actor,
+		f.Action != "" && entry.Action != f.Action,
+		f.Resource != "" && entry.Resource != f.Resource,
+		f.TargetID != "" && entry.TargetID != f.TargetID,
+		f.RequestID != "" && entry.RequestID != f.RequestID,
+		!f.From.IsZero() && entry.RecordedAt.Before(f.From),
+		!f.To.IsZero() && entry.RecordedAt.After(f.To):
+		return false
+	}
+	return true
+}
+
+// AuditQuery selects a page of the audit log, in ID order
+type AuditQuery struct {
+	Filter AuditFilter
+	// AfterID starts the page after the entry with that ID
+	AfterID int64
+	Limit   int
+}
+
+// AuditPage is one page of the audit log. NextAfterID fetches the next page and is omitted
+// on the last one.
+type AuditPage struct {
+	Items       []AuditEntry `json:"items"`
+	Limit       int          `json:"limit"`
+	NextAfterID int64        `json:"nextAfterId,omitempty"`
+}
+
+// parseAuditQuery reads the parameters of an audit log listing:
+//
+//	actor=alice             entries of one actor
+//	action=update           create, update or delete
+//	resource=employee       employee, department or exchangeRate
+//	targetId=7              entries about one resource, e.g. 7 or USD/EUR/2026-01-01
+//	requestId=...           entries of one request
+//	from, to                inclusive RFC 3339 time range
+//	afterId=120             entries after that ID; nextAfterId of the previous page
+//	limit=10                page size, 1 to listLimits.MaxLimit
+//
+// Every invalid parameter is reported at once.
+func parseAuditQuery(values url.Values) (AuditQuery, error) {
+	var errs []FieldError
+	q := AuditQuery{
+		Filter: AuditFilter{
+			Actor:     values.Get("actor"),
+			Action:    values.Get("action"),
+			Resource:  values.Get("resource"),
+			TargetID:  values.Get("targetId"),
+			RequestID: values.Get("requestId"),
+		},
+		Limit: listLimits.DefaultLimit,
+	}
+
+	if q.Filter.Action != "" && !slices.Contains([]string{AuditCreate, AuditUpdate, AuditDelete}, q.Filter.Action) {
+		errs = append(errs, FieldError{Field: "action", Message: "must be create, update or delete"})
+	}
+	if q.Filter.Resource != "" && !slices.Contains([]string{AuditEmployee, AuditDepartment, AuditExchangeRate}, q.Filter.Resource) {
+		errs = append(errs, FieldError{Field: "resource", Message: "must be employee, department or exchangeRate"})
+	}
+
+	timestamp := func(param string) time.Time {
+		v := values.Get(param)
+		if v == "" {
+			return time.Time{}
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			errs = append(errs, FieldError{Field: param, Message: "must be an RFC 3339 timestamp"})
+			return time.Time{}
+		}
+		return t.UTC()
+	}
+	q.Filter.From = timestamp("from")
+	q.Filter.To = timestamp("to")
+
+	if v := values.Get("afterId"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 {
+			errs = append(errs, FieldError{Field: "afterId", Message: "must be an audit entry ID"})
+		}
+		q.AfterID = id
+	}
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > listLimits.MaxLimit {
+			errs = append(errs, FieldError{Field: "limit", Message: fmt.Sprintf("must be a number from 1 to %d", listLimits.MaxLimit)})
+		}
+		q.Limit = limit
+	}
+
+	if len(errs) > 0 {
+		return AuditQuery{}, NewInvalidQueryError(errs)
+	}
+	return q, nil
+}
+
+// AuditVerification is the outcome of checking the audit log's hash chain
+type AuditVerification struct {
+	Verified bool `json:"verified"`
+	// Entries counts the entries checked, up to the first broken one
+	Entries  int64  `json:"entries"`
+	LastHash string `json:"lastHash"`
+	// BrokenAt and Problem describe the first entry that does not fit the chain
+	BrokenAt int64  `json:"brokenAt,omitempty"`
+	Problem  string `json:"problem,omitempty"`
+	// NextAfterID resumes a walk that ran out of time; it is omitted once the end was reached
+	NextAfterID int64 `json:"nextAfterId,omitempty"`
+}
+
+// AdminMiddleware lets requests carrying the configured admin bearer token through
+func AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auditSettings.AdminToken == "" {
+			writeProblem(w, r, &APIError{Status: http.StatusForbidden, Code: CodeForbidden, Title: "Forbidden", Detail: "Admin endpoints are disabled; set audit.adminToken"})
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(auditSettings.AdminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeProblem(w, r, &APIError{Status: http.StatusUnauthorized, Code: CodeUnauthorized, Title: "Unauthorized", Detail: "A valid admin bearer token is required"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
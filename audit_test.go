@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditChanges(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after any
+		want          string
+	}{
+		{"create", nil, map[string]any{"name": "Dan"}, `{"name":{"before":null,"after":"Dan"}}`},
+		{"delete", map[string]any{"name": "Dan"}, nil, `{"name":{"before":"Dan","after":null}}`},
+		{"unchanged members are left out", map[string]any{"name": "Dan", "salary": "100.00"}, map[string]any{"name": "Dan", "salary": "110.00"},
+			`{"salary":{"before":"100.00","after":"110.00"}}`},
+		{"no changes", map[string]any{"name": "Dan"}, map[string]any{"name": "Dan"}, `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := auditChanges(tt.before, tt.after)
+			if err != nil || string(got) != tt.want {
+				t.Errorf("auditChanges() = %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditLogHandlers(t *testing.T) {
+	defer func(settings AuditConfig) { auditSettings = settings }(auditSettings)
+	auditSettings = AuditConfig{AdminToken: "s3cret"}
+
+	stores := map[string]func(t *testing.T) EmployeeStore{
+		"memory": func(t *testing.T) EmployeeStore { return initTestStore(t) },
+		"sqlite": func(t *testing.T) EmployeeStore { return initTestSQLiteStore(t) },
+	}
+	for storeName, newStore := range stores {
+		t.Run(storeName, func(t *testing.T) {
+			store := newStore(t)
+			cr := initTestRouter(t, store)
+			do := func(method, path, actor, body string, wantStatus int) *httptest.ResponseRecorder {
+				t.Helper()
+				req := httptest.NewRequest(method, path, strings.NewReader(body))
+				req.RemoteAddr = "10.0.0.7:5000"
+				req.Header.Set(ActorHeader, actor)
+				req.Header.Set(RequestIDHeader, fmt.Sprintf("req-%s-%s", method, actor))
+				if strings.HasPrefix(path, "/admin/") {
+					req.Header.Set("Authorization", "Bearer s3cret")
+				}
+				rec := serveTestRequest(cr, req)
+				if rec.Code != wantStatus {
+					t.Fatalf("%s %s status = %d, want %d: %s", method, path, rec.Code, wantStatus, rec.Body)
+				}
+				return rec
+			}
+			readLog := func(query string) AuditPage {
+				t.Helper()
+				var page AuditPage
+				if err := json.NewDecoder(do("GET", "/admin/audit?"+query, "", "", http.StatusOK).Body).Decode(&page); err != nil {
+					t.Fatal(err)
+				}
+				return page
+			}
+
+			do("POST", "/employees", "alice", `{"name": "Dan", "designation": "Developer", "salary": 100}`, http.StatusCreated)
+			do("PUT", "/employees/1", "bob", `{"name": "Dan", "designation": "Developer", "salary": 110}`, http.StatusOK)
+			do("POST", "/departments", "alice", `{"name": "Sales"}`, http.StatusCreated)
+			do("PUT", "/exchange-rates/USD/EUR/2026-01-01", "alice", `{"rate": "0.9"}`, http.StatusOK)
+			do("PUT", "/exchange-rates/USD/EUR/2026-01-01", "", `{"rate": "0.95"}`, http.StatusOK)
+			do("DELETE", "/employees/1", "bob", "", http.StatusOK)
+			// Failed writes leave no entry
+			do("DELETE", "/employees/1", "bob", "", http.StatusNotFound)
+
+			var got []string
+			for _, entry := range readLog("").Items {
+				got = append(got, fmt.Sprintf("%d %s %s %s/%s %s %s", entry.ID, entry.Actor, entry.Action, entry.Resource, entry.TargetID, entry.RequestID, entry.ClientIP))
+			}
+			want := []string{
+				"1 alice create employee/1 req-POST-alice 10.0.0.7",
+				"2 bob update employee/1 req-PUT-bob 10.0.0.7",
+				"3 alice create department/1 req-POST-alice 10.0.0.7",
+				"4 alice create exchangeRate/USD/EUR/2026-01-01 req-PUT-alice 10.0.0.7",
+				"5 anonymous update exchangeRate/USD/EUR/2026-01-01 req-PUT- 10.0.0.7",
+				"6 bob delete employee/1 req-DELETE-bob 10.0.0.7",
+			}
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("audit log =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+
+			var changes map[string]AuditChange
+			if err := json.Unmarshal(readLog("action=update&resource=employee").Items[0].Changes, &changes); err != nil {
+				t.Fatal(err)
+			}
+			if salary := changes["salary"]; string(salary.Before) != "100.00" || string(salary.After) != "110.00" {
+				t.Errorf("salary change = %s -> %s, want 100.00 -> 110.00", salary.Before, salary.After)
+			}
+			if _, ok := changes["name"]; ok {
+				t.Errorf("changes = %v, want the unchanged name left out", changes)
+			}
+
+			if page := readLog("actor=bob&targetId=1"); len(page.Items) != 2 || page.Items[0].ID != 2 || page.Items[1].ID != 6 {
+				t.Errorf("entries of bob = %+v, want 2 and 6", page.Items)
+			}
+			page := readLog("limit=4")
+			if len(page.Items) != 4 || page.NextAfterID != 4 {
+				t.Errorf("first page = %d entries, nextAfterId %d, want 4 and 4", len(page.Items), page.NextAfterID)
+			}
+			if page := readLog("limit=4&afterId=4"); len(page.Items) != 2 || page.Items[0].ID != 5 || page.NextAfterID != 0 {
+				t.Errorf("last page = %+v, nextAfterId %d, want entries 5 and 6", page.Items, page.NextAfterID)
+			}
+
+			var verification AuditVerification
+			if err := json.NewDecoder(do("GET", "/admin/audit/verify", "", "", http.StatusOK).Body).Decode(&verification); err != nil {
+				t.Fatal(err)
+			}
+			if last := readLog("afterId=5").Items[0]; !verification.Verified || verification.Entries != 6 || verification.LastHash != last.Hash {
+				t.Errorf("verification = %+v, want 6 verified entries", verification)
+			}
+
+			if err := json.NewDecoder(do("GET", "/admin/audit/verify?afterId=3", "", "", http.StatusOK).Body).Decode(&verification); err != nil {
+				t.Fatal(err)
+			}
+			if !verification.Verified || verification.Entries != 3 {
+				t.Errorf("verification after 3 = %+v, want 3 verified entries", verification)
+			}
+			do("GET", "/admin/audit/verify?afterId=-1", "", "", http.StatusBadRequest)
+
+			rec := do("GET", "/admin/audit?action=rename&from=yesterday", "", "", http.StatusBadRequest)
+			if problem := decodeProblem(t, rec); problem.Code != CodeInvalidQuery || len(problem.Errors) != 2 {
+				t.Errorf("problem = %+v, want 2 invalid parameters", problem)
+			}
+		})
+	}
+}
+
+func TestScheduledChangesAreAudited(t *testing.T) {
+	stores := map[string]func(t *testing.T) EmployeeStore{
+		"memory": func(t *testing.T) EmployeeStore { return initTestStore(t) },
+		"sqlite": func(t *testing.T) EmployeeStore { return initTestSQLiteStore(t) },
+	}
+	for storeName, newStore := range stores {
+		t.Run(storeName, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			emp := &Employee{Name: "Dan", Designation: "Developer", Salary: mustMoney("100"), Currency: "USD"}
+			if err := store.CreateEmployee(ctx, emp); err != nil {
+				t.Fatalf("CreateEmployee() error = %v", err)
+			}
+			nextMonth := today().AddDate(0, 1, 0)
+			promotion := &Employee{Designation: "Lead"}
+			if _, err := store.UpdateEmployee(ctx, emp.ID, promotion, []string{"designation"}, 0, nextMonth); err != nil {
+				t.Fatalf("UpdateEmployee() error = %v", err)
+			}
+			if applied, err := store.ApplyScheduledChanges(ctx, today(), nextMonth); err != nil || applied != 1 {
+				t.Fatalf("ApplyScheduledChanges() = %d, %v, want 1", applied, err)
+			}
+
+			page, err := ReadAuditLogAPI(ctx, store, AuditQuery{Filter: AuditFilter{Actor: schedulerActor}, Limit: 10})
+			if err != nil || len(page.Items) != 1 {
+				t.Fatalf("scheduler entries = %+v, %v, want 1", page, err)
+			}
+			entry := page.Items[0]
+			var changes map[string]AuditChange
+			if err := json.Unmarshal(entry.Changes, &changes); err != nil {
+				t.Fatal(err)
+			}
+			if entry.Action != AuditUpdate || entry.TargetID != "1" || string(changes["designation"].After) != `"Lead"` || string(changes["designation"].Before) != `"Developer"` {
+				t.Errorf("scheduler entry = %+v, changes %v", entry, changes)
+			}
+			if result, err := VerifyAuditLogAPI(ctx, store, 0); err != nil || !result.Verified || result.Entries != 3 {
+				t.Errorf("VerifyAuditLogAPI() = %+v, %v, want 3 verified entries", result, err)
+			}
+		})
+	}
+}
+
+func TestAdminMiddleware(t *testing.T) {
+	defer func(settings AuditConfig) { auditSettings = settings }(auditSettings)
+	cr := initTestRouter(t, initTestStore(t))
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		wantStatus    int
+	}{
+		{"disabled without a token", "", "Bearer anything", http.StatusForbidden},
+		{"missing token", "s3cret", "", http.StatusUnauthorized},
+		{"wrong token", "s3cret", "Bearer guess", http.StatusUnauthorized},
+		{"wrong scheme", "s3cret", "Basic s3cret", http.StatusUnauthorized},
+		{"valid token", "s3cret", "Bearer s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditSettings.AdminToken = tt.token
+			req := httptest.NewRequest("GET", "/admin/audit", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := serveTestRequest(cr, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("401 response has no WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	defer func(settings AuditConfig) { auditSettings = settings }(auditSettings)
+
+	tests := []struct {
+		name         string
+		trust        bool
+		forwardedFor string
+		want         string
+	}{
+		{"peer address", false, "", "192.0.2.1"},
+		{"untrusted forwarded for", false, "203.0.113.9", "192.0.2.1"},
+		{"trusted forwarded for", true, "203.0.113.9, 198.51.100.2", "203.0.113.9"},
+		{"trusted but absent", true, "", "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditSettings.TrustForwardedFor = tt.trust
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if got := clientIP(req); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteAuditLogIsTamperEvident(t *testing.T) {
+	ctx := context.Background()
+	store := initTestSQLiteStore(t)
+	for _, name := range []string{"Sales", "Support", "Marketing"} {
+		if err := store.CreateDepartment(ctx, &Department{Name: name}); err != nil {
+			t.Fatalf("CreateDepartment() error = %v", err)
+		}
+	}
+	if result, err := VerifyAuditLogAPI(ctx, store, 0); err != nil || !result.Verified || result.Entries != 3 {
+		t.Fatalf("VerifyAuditLogAPI() = %+v, %v, want 3 verified entries", result, err)
+	}
+
+	// The table only takes inserts
+	if _, err := store.DB.Exec("UPDATE audit_log SET Actor = 'mallory' WHERE ID = 2"); err == nil {
+		t.Errorf("updating an audit entry succeeded")
+	}
+	if _, err := store.DB.Exec("DELETE FROM audit_log WHERE ID = 2"); err == nil {
+		t.Errorf("deleting an audit entry succeeded")
+	}
+
+	// Edits that get around the triggers still show up
+	tests := []struct {
+		name         string
+		tamper       string
+		wantBrokenAt int64
+		wantProblem  string
+	}{
+		{"edited entry", "UPDATE audit_log SET Actor = 'mallory' WHERE ID = 2", 2, "hash does not match the entry"},
+		{"removed entry", "DELETE FROM audit_log WHERE ID = 2", 3, "entry 3 follows entry 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := initTestSQLiteStore(t)
+			for _, name := range []string{"Sales", "Support", "Marketing"} {
+				if err := store.CreateDepartment(ctx, &Department{Name: name}); err != nil {
+					t.Fatalf("CreateDepartment() error = %v", err)
+				}
+			}
+			for _, stmt := range []string{"DROP TRIGGER audit_log_no_update", "DROP TRIGGER audit_log_no_delete", tt.tamper} {
+				if _, err := store.DB.Exec(stmt); err != nil {
+					t.Fatalf("%s: %v", stmt, err)
+				}
+			}
+			result, err := VerifyAuditLogAPI(ctx, store, 0)
+			if err != nil || result.Verified || result.BrokenAt != tt.wantBrokenAt || result.Problem != tt.wantProblem {
+				t.Errorf("VerifyAuditLogAPI() = %+v, %v, want %q at %d", result, err, tt.wantProblem, tt.wantBrokenAt)
+			}
+		})
+	}
+}
+
+func TestVerifyAuditLogResumes(t *testing.T) {
+	defer func(timeouts Timeouts, batch int) { apiTimeouts, auditVerifyBatch = timeouts, batch }(apiTimeouts, auditVerifyBatch)
+	ctx := context.Background()
+	store := initTestSQLiteStore(t)
+	for _, name := range []string{"Sales", "Support", "Marketing"} {
+		if err := store.CreateDepartment(ctx, &Department{Name: name}); err != nil {
+			t.Fatalf("CreateDepartment() error = %v", err)
+		}
+	}
+	whole, err := VerifyAuditLogAPI(ctx, store, 0)
+	if err != nil || !whole.Verified || whole.NextAfterID != 0 {
+		t.Fatalf("VerifyAuditLogAPI() = %+v, %v, want the whole log verified", whole, err)
+	}
+
+	// Out of time after every batch, the walk goes on where the last call stopped
+	apiTimeouts.AuditVerify, auditVerifyBatch = time.Nanosecond, 1
+	var calls, entries int64
+	result := &AuditVerification{}
+	for afterID := int64(0); calls == 0 || afterID != 0; afterID = result.NextAfterID {
+		if result, err = VerifyAuditLogAPI(ctx, store, afterID); err != nil || !result.Verified {
+			t.Fatalf("VerifyAuditLogAPI(%d) = %+v, %v, want verified", afterID, result, err)
+		}
+		calls++
+		entries += result.Entries
+	}
+	// A full last batch cannot tell the end of the log apart, so one more call finds nothing
+	if calls != 4 || entries != 3 || result.LastHash != whole.LastHash {
+		t.Errorf("resumed walk = %d calls, %d entries, last hash %s, want 4, 3 and %s", calls, entries, result.LastHash, whole.LastHash)
+	}
+
+	if _, err := VerifyAuditLogAPI(ctx, store, 9); !errors.As(err, new(*APIError)) {
+		t.Errorf("VerifyAuditLogAPI(9) error = %v, want an invalid afterId", err)
+	}
+	for _, stmt := range []string{"DROP TRIGGER audit_log_no_delete", "DELETE FROM audit_log WHERE ID = 2"} {
+		if _, err := store.DB.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	if result, err := VerifyAuditLogAPI(ctx, store, 2); err != nil || result.Verified || result.BrokenAt != 2 {
+		t.Errorf("VerifyAuditLogAPI(2) = %+v, %v, want entry 2 missing", result, err)
+	}
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting of the service. It is built by layering, in increasing
+// priority: defaults, a YAML or JSON config file, EMP_* environment variables and
+// command-line flags.
+type Config struct {
+	Store       string            `yaml:"store"`
+	ListenAddr  string            `yaml:"listenAddr"`
+	AutoMigrate bool              `yaml:"autoMigrate"`
+	Postgres    PostgresConfig    `yaml:"postgres"`
+	SQLite      SQLiteConfig      `yaml:"sqlite"`
+	Pool        PoolConfig        `yaml:"pool"`
+	Timeouts    Timeouts          `yaml:"timeouts"`
+	Server      ServerConfig      `yaml:"server"`
+	Validation  ValidationConfig  `yaml:"validation"`
+	Concurrency ConcurrencyConfig `yaml:"concurrency"`
+	List        ListConfig        `yaml:"list"`
+	Money       MoneyConfig       `yaml:"money"`
+	Org         OrgConfig         `yaml:"org"`
+	Audit       AuditConfig       `yaml:"audit"`
+}
+
+// PostgresConfig holds the PostgreSQL connection settings
+type PostgresConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	DBName   string `yaml:"dbname"`
+	SSLMode  string `yaml:"sslmode"`
+}
+
+// SQLiteConfig holds the SQLite database settings
+type SQLiteConfig struct {
+	Path string `yaml:"path"`
+}
+
+// PoolConfig holds the database/sql connection pool settings
+type PoolConfig struct {
+	MaxOpenConns    int           `yaml:"maxOpenConns"`
+	MaxIdleConns    int           `yaml:"maxIdleConns"`
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime"`
+}
+
+// Timeouts holds the per-operation deadlines applied by the API layer
+type Timeouts struct {
+	Create time.Duration `yaml:"create"`
+	Read   time.Duration `yaml:"read"`
+	List   time.Duration `yaml:"list"`
+	Update time.Duration `yaml:"update"`
+	Delete time.Duration `yaml:"delete"`
+	// AuditVerify bounds one /admin/audit/verify call, which then returns where to resume
+	AuditVerify time.Duration `yaml:"auditVerify"`
+}
+
+// ServerConfig holds the HTTP server timeouts and the shutdown delay and grace period
+type ServerConfig struct {
+	ReadTimeout  time.Duration `yaml:"readTimeout"`
+	WriteTimeout time.Duration `yaml:"writeTimeout"`
+	IdleTimeout  time.Duration `yaml:"idleTimeout"`
+	// ShutdownDelay keeps serving with /readyz failing, so load balancers stop sending
+	// requests before the listener closes
+	ShutdownDelay time.Duration `yaml:"shutdownDelay"`
+	ShutdownGrace time.Duration `yaml:"shutdownGrace"`
+}
+
+// ValidationConfig holds the configurable request validation rules
+type ValidationConfig struct {
+	// Designations lists the allowed employee designations; empty allows any
+	Designations []string `yaml:"designations"`
+}
+
+// ConcurrencyConfig holds the optimistic concurrency settings
+type ConcurrencyConfig struct {
+	// RequireIfMatch rejects PUT, PATCH and DELETE requests that carry no If-Match header
+	RequireIfMatch bool `yaml:"requireIfMatch"`
+}
+
+// ListConfig holds the page sizes of list endpoints
+type ListConfig struct {
+	DefaultLimit int `yaml:"defaultLimit"`
+	// MaxLimit is the largest page a client may ask for
+	MaxLimit int `yaml:"maxLimit"`
+}
+
+// OrgConfig holds the limits of reporting line queries
+type OrgConfig struct {
+	// MaxDepth is the most levels a chain or subtree query may walk
+	MaxDepth int `yaml:"maxDepth"`
+}
+
+// DefaultOrgConfig returns the limits used when none are configured
+func DefaultOrgConfig() OrgConfig {
+	return OrgConfig{MaxDepth: 50}
+}
+
+// AuditConfig holds the settings of the audit log
+type AuditConfig struct {
+	// AdminToken is the bearer token of the admin endpoints; empty disables them
+	AdminToken string `yaml:"adminToken"`
+	// TrustForwardedFor takes the client IP from X-Forwarded-For, for servers behind a proxy
+	TrustForwardedFor bool `yaml:"trustForwardedFor"`
+}
+
+// MoneyConfig holds how money amounts are written
+type MoneyConfig struct {
+	// JSONFormat is one of number, string or minor; see the MoneyJSON constants
+	JSONFormat string `yaml:"jsonFormat"`
+	// DefaultCurrency is the currency of salaries sent without one
+	DefaultCurrency string `yaml:"defaultCurrency"`
+}
+
+// DefaultListConfig returns the page sizes used when none are configured
+func DefaultListConfig() ListConfig {
+	return ListConfig{DefaultLimit: 10, MaxLimit: 100}
+}
+
+const defaultTimeout = 5 * time.Second
+
+// DefaultTimeouts returns the timeouts used when none are configured
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Create:      defaultTimeout,
+		Read:        defaultTimeout,
+		List:        defaultTimeout,
+		Update:      defaultTimeout,
+		Delete:      defaultTimeout,
+		AuditVerify: defaultTimeout,
+	}
+}
+
+// DefaultConfig returns the configuration used when nothing else is provided
+func DefaultConfig() *Config {
+	return &Config{
+		Store:       "postgres",
+		ListenAddr:  ":8080",
+		AutoMigrate: true,
+		Postgres: PostgresConfig{
+			Host:    "localhost",
+			Port:    5432,
+			User:    "postgres",
+			DBName:  "organisation",
+			SSLMode: "disable",
+		},
+		SQLite: SQLiteConfig{Path: "employee.db"},
+		Pool: PoolConfig{
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Timeouts: DefaultTimeouts(),
+		List:     DefaultListConfig(),
+		Money:    MoneyConfig{JSONFormat: MoneyJSONNumber, DefaultCurrency: "USD"},
+		Org:      DefaultOrgConfig(),
+		Server: ServerConfig{
+			ReadTimeout:   10 * time.Second,
+			WriteTimeout:  15 * time.Second,
+			IdleTimeout:   60 * time.Second,
+			ShutdownDelay: 5 * time.Second,
+			ShutdownGrace: 20 * time.Second,
+		},
+	}
+}
+
+// DSN returns the lib/pq connection string for the PostgreSQL settings
+func (c PostgresConfig) DSN() string {
+	// Quote values so passwords with spaces or quotes survive
+	quote := func(v string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quote(c.Host), c.Port, quote(c.User), quote(c.Password), quote(c.DBName), quote(c.SSLMode))
+}
+
+// setting is a single option that can be set from the environment and the command line
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	bool  bool
+	set   func(c *Config, v string) error
+}
+
+func stringSetting(field func(c *Config) *string) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+func intSetting(field func(c *Config) *int) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func boolSetting(field func(c *Config) *bool) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+// listSetting parses a comma-separated list
+func listSetting(field func(c *Config) *[]string) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		var list []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(c) = list
+		return nil
+	}
+}
+
+func durationSetting(field func(c *Config) *time.Duration) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+var settings = []setting{
+	{flag: "store", env: "EMP_STORE", usage: "employee store backend: postgres, sqlite or memory",
+		set: stringSetting(func(c *Config) *string { return &c.Store })},
+	{flag: "listen", env: "EMP_LISTEN_ADDR", usage: "HTTP listen address",
+		set: stringSetting(func(c *Config) *string { return &c.ListenAddr })},
+	{flag: "auto-migrate", env: "EMP_AUTO_MIGRATE", usage: "apply pending schema migrations at startup", bool: true,
+		set: boolSetting(func(c *Config) *bool { return &c.AutoMigrate })},
+	{flag: "db-host", env: "EMP_DB_HOST", usage: "PostgreSQL host",
+		set: stringSetting(func(c *Config) *string { return &c.Postgres.Host })},
+	{flag: "db-port", env: "EMP_DB_PORT", usage: "PostgreSQL port",
+		set: intSetting(func(c *Config) *int { return &c.Postgres.Port })},
+	{flag: "db-user", env: "EMP_DB_USER", usage: "PostgreSQL user",
+		set: stringSetting(func(c *Config) *string { return &c.Postgres.User })},
+	{flag: "db-password", env: "EMP_DB_PASSWORD", usage: "PostgreSQL password (prefer the environment variable)",
+		set: stringSetting(func(c *Config) *string { return &c.Postgres.Password })},
+	{flag: "db-name", env: "EMP_DB_NAME", usage: "PostgreSQL database name",
+		set: stringSetting(func(c *Config) *string { return &c.Postgres.DBName })},
+	{flag: "db-sslmode", env: "EMP_DB_SSLMODE", usage: "PostgreSQL sslmode",
+		set: stringSetting(func(c *Config) *string { return &c.Postgres.SSLMode })},
+	{flag: "sqlite-path", env: "EMP_SQLITE_PATH", usage: "database file used by the sqlite store",
+		set: stringSetting(func(c *Config) *string { return &c.SQLite.Path })},
+	{flag: "db-max-open-conns", env: "EMP_DB_MAX_OPEN_CONNS", usage: "maximum open database connections (0 is unlimited)",
+		set: intSetting(func(c *Config) *int { return &c.Pool.MaxOpenConns })},
+	{flag: "db-max-idle-conns", env: "EMP_DB_MAX_IDLE_CONNS", usage: "maximum idle database connections",
+		set: intSetting(func(c *Config) *int { return &c.Pool.MaxIdleConns })},
+	{flag: "db-conn-max-lifetime", env: "EMP_DB_CONN_MAX_LIFETIME", usage: "maximum lifetime of a database connection",
+		set: durationSetting(func(c *Config) *time.Duration { return &c.Pool.ConnMaxLifetime })},
+	{flag: "timeout-create", env: "EMP_TIMEOUT_CREATE", usage: "timeout for creating an employee",
+		set: durationSetting(func(c *Config) *time.Duration { return &c.Timeouts.Create })},
+	{flag: "timeout-read", env: "EMP_TIMEOUT_READ", usage: "timeout for reading an employee",
+		set: durationSetting(func(c *Config) *time.Duration { return &c.Timeouts.Read })},
+	{flag: "timeout-list", env: "EMP_TIMEOUT_LIST", usage: "timeout for listing employees",
+		set: durationSetting(func(c *Config) *time.Duration { return &c.Timeouts.List })},
+	{flag: "timeout-update", env: "EMP_TIMEOUT_UPDATE", usage: "timeout for updating an employee",
+		set: durationSetting(func(c *Config) *time.Duration { return &c.Timeouts.Update })},
+	{flag: "timeout-delete", env: "EMP_TIMEOUT_DELETE", usage: "timeout for deleting an employee",
+		set: durationSetting(func(c *Config) *time.Duration { return &c.Timeouts.Delete })},
+	{flag: "timeout-audit-verify", env: "EMP_TIMEOUT_AUDIT_VERIFY", usage: "time one audit log verification call may take before it stops with a place to resume",
+		set: durationSetting(func(c *Config) *time.Duration { return &c.Timeouts.AuditVerify })},
+	{flag: "server-read-timeout", env: "EMP_SERVER_READ_TIMEOUT", usage: "maximum duration for reading a request",
+		set: durationSetting(func(c *Config) *time.Duration { return &c.Server.ReadTimeout })},
+	{flag: "server-write-timeout", env: "EMP_SERVER_WRITE_TIMEOUT", usage: "maximum duration for writing a response",
+		set: durationSetting(func(c *Config) *time.Duration { return &c.Server.WriteTimeout })},
+	{flag: "server-idle-timeout", env: "EMP_SERVER_IDLE_TIMEOUT", usage: "maximum time an idle keep-alive connection is kept",
+		set: durationSetting(func(c *Config) *time.Duration { return &c.Server.IdleTimeout })},
+	{flag: "shutdown-delay", env: "EMP_SHUTDOWN_DELAY", usage: "time readiness fails before the server stops accepting connections on shutdown",
+		set: durationSetting(func(c *Config) *time.Duration { return &c.Server.ShutdownDelay })},
+	{flag: "shutdown-grace", env: "EMP_SHUTDOWN_GRACE", usage: "time allowed for in-flight requests to finish on shutdown",
+		set: durationSetting(func(c *Config) *time.Duration { return &c.Server.ShutdownGrace })},
+	{flag: "allowed-designations", env: "EMP_ALLOWED_DESIGNATIONS", usage: "comma-separated list of allowed employee designations (empty allows any)",
+		set: listSetting(func(c *Config) *[]string { return &c.Validation.Designations })},
+	{flag: "require-if-match", env: "EMP_REQUIRE_IF_MATCH", usage: "reject employee writes without an If-Match header", bool: true,
+		set: boolSetting(func(c *Config) *bool { return &c.Concurrency.RequireIfMatch })},
+	{flag: "list-default-limit", env: "EMP_LIST_DEFAULT_LIMIT", usage: "page size of list endpoints when the client gives no limit",
+		set: intSetting(func(c *Config) *int { return &c.List.DefaultLimit })},
+	{flag: "list-max-limit", env: "EMP_LIST_MAX_LIMIT", usage: "largest page size a client may ask for",
+		set: intSetting(func(c *Config) *int { return &c.List.MaxLimit })},
+	{flag: "money-json-format", env: "EMP_MONEY_JSON_FORMAT", usage: "how salaries are written to JSON: number, string or minor",
+		set: stringSetting(func(c *Config) *string { return &c.Money.JSONFormat })},
+	{flag: "default-currency", env: "EMP_DEFAULT_CURRENCY", usage: "ISO 4217 currency of salaries sent without one",
+		set: stringSetting(func(c *Config) *string { return &c.Money.DefaultCurrency })},
+	{flag: "org-max-depth", env: "EMP_ORG_MAX_DEPTH", usage: "most levels a reporting chain or subtree query may walk",
+		set: intSetting(func(c *Config) *int { return &c.Org.MaxDepth })},
+	{flag: "audit-admin-token", env: "EMP_AUDIT_ADMIN_TOKEN", usage: "bearer token of the admin endpoints; empty disables them (prefer the environment variable)",
+		set: stringSetting(func(c *Config) *string { return &c.Audit.AdminToken })},
+	{flag: "audit-trust-forwarded-for", env: "EMP_AUDIT_TRUST_FORWARDED_FOR", usage: "record the client IP from X-Forwarded-For", bool: true,
+		set: boolSetting(func(c *Config) *bool { return &c.Audit.TrustForwardedFor })},
+}
+
+// LoadConfig builds the configuration from defaults, the config file, the environment and
+// the command-line args, and returns it with the remaining non-flag arguments
+func LoadConfig(args []string, getenv func(string) string) (*Config, []string, error) {
+	fs := flag.NewFlagSet("emp", flag.ContinueOnError)
+	configPath := fs.String("config", getenv("EMP_CONFIG"), "path to a YAML or JSON config file (env EMP_CONFIG)")
+
+	// Flags are only recorded while parsing and applied once the lower layers are in place
+	var overrides []func(c *Config) error
+	for _, s := range settings {
+		s := s
+		record := func(v string) error {
+			overrides = append(overrides, func(c *Config) error { return s.set(c, v) })
+			return nil
+		}
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		if s.bool {
+			fs.BoolFunc(s.flag, usage, func(v string) error { return record(v) })
+		} else {
+			fs.Func(s.flag, usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := DefaultConfig()
+
+	// Config file
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Environment variables
+	for _, s := range settings {
+		if v := getenv(s.env); v != "" {
+			if err := s.set(cfg, v); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	// Command-line flags
+	for _, override := range overrides {
+		if err := override(cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+// loadFile overlays the settings found in a YAML or JSON file; JSON is valid YAML
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("unable to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	switch c.Store {
+	case "postgres":
+		if c.Postgres.Host == "" {
+			errs = append(errs, errors.New("postgres host is required"))
+		}
+		if c.Postgres.Port < 1 || c.Postgres.Port > 65535 {
+			errs = append(errs, fmt.Errorf("postgres port %d is out of range", c.Postgres.Port))
+		}
+		if c.Postgres.User == "" {
+			errs = append(errs, errors.New("postgres user is required"))
+		}
+		if c.Postgres.DBName == "" {
+			errs = append(errs, errors.New("postgres dbname is required"))
+		}
+	case "sqlite":
+		if c.SQLite.Path == "" {
+			errs = append(errs, errors.New("sqlite path is required"))
+		}
+	case "memory":
+	default:
+		errs = append(errs, fmt.Errorf("unknown store backend %q", c.Store))
+	}
+
+	if _, _, err := net.SplitHostPort(c.ListenAddr); err != nil {
+		errs = append(errs, fmt.Errorf("invalid listen address %q: %w", c.ListenAddr, err))
+	}
+
+	if c.Pool.MaxOpenConns < 0 || c.Pool.MaxIdleConns < 0 || c.Pool.ConnMaxLifetime < 0 {
+		errs = append(errs, errors.New("pool settings must not be negative"))
+	}
+	if c.Pool.MaxOpenConns > 0 && c.Pool.MaxIdleConns > c.Pool.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("pool maxIdleConns %d exceeds maxOpenConns %d", c.Pool.MaxIdleConns, c.Pool.MaxOpenConns))
+	}
+
+	for _, timeout := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"create", c.Timeouts.Create},
+		{"read", c.Timeouts.Read},
+		{"list", c.Timeouts.List},
+		{"update", c.Timeouts.Update},
+		{"delete", c.Timeouts.Delete},
+		{"audit verify", c.Timeouts.AuditVerify},
+		{"server read", c.Server.ReadTimeout},
+		{"server write", c.Server.WriteTimeout},
+		{"server idle", c.Server.IdleTimeout},
+		{"shutdown grace", c.Server.ShutdownGrace},
+	} {
+		if timeout.d <= 0 {
+			errs = append(errs, fmt.Errorf("%s timeout must be positive", timeout.name))
+		}
+	}
+
+	if c.Server.ShutdownDelay < 0 {
+		errs = append(errs, errors.New("shutdown delay must not be negative"))
+	}
+
+	if c.List.MaxLimit < 1 {
+		errs = append(errs, errors.New("list maxLimit must be positive"))
+	}
+	if c.List.DefaultLimit < 1 || c.List.DefaultLimit > c.List.MaxLimit {
+		errs = append(errs, fmt.Errorf("list defaultLimit %d must be between 1 and maxLimit", c.List.DefaultLimit))
+	}
+
+	if c.Org.MaxDepth < 1 {
+		errs = append(errs, errors.New("org maxDepth must be positive"))
+	}
+
+	switch c.Money.JSONFormat {
+	case MoneyJSONNumber, MoneyJSONString, MoneyJSONMinor:
+	default:
+		errs = append(errs, fmt.Errorf("unknown money jsonFormat %q", c.Money.JSONFormat))
+	}
+	if !validCurrency(c.Money.DefaultCurrency) {
+		errs = append(errs, fmt.Errorf("money defaultCurrency %q is not an ISO 4217 currency code", c.Money.DefaultCurrency))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
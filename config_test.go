@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Helper function to build a getenv func from a map
+func testEnv(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+func TestLoadConfigLayering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(`
+store: sqlite
+listenAddr: ":9000"
+sqlite:
+  path: /var/lib/emp/employee.db
+timeouts:
+  read: 2s
+`), 0o600)
+	if err != nil {
+		t.Fatalf("Unable to write config file: %v", err)
+	}
+
+	env := map[string]string{
+		"EMP_CONFIG":         path,
+		"EMP_LISTEN_ADDR":    ":9001",
+		"EMP_TIMEOUT_UPDATE": "3s",
+		"EMP_SHUTDOWN_DELAY": "0s",
+	}
+	cfg, args, err := LoadConfig([]string{"-listen", ":9002", "-timeout-update=4s", "migrate", "status"}, testEnv(env))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	if cfg.Store != "sqlite" || cfg.SQLite.Path != "/var/lib/emp/employee.db" {
+		t.Errorf("config file settings were not applied: %+v", cfg)
+	}
+	if cfg.ListenAddr != ":9002" {
+		t.Errorf("ListenAddr = %q, want the flag value :9002", cfg.ListenAddr)
+	}
+	if cfg.Timeouts.Read != 2*time.Second || cfg.Timeouts.Update != 4*time.Second || cfg.Timeouts.Create != defaultTimeout {
+		t.Errorf("Timeouts = %+v, want read 2s from file, update 4s from flag, defaults elsewhere", cfg.Timeouts)
+	}
+	if cfg.Server.ShutdownDelay != 0 || cfg.Server.ShutdownGrace != 20*time.Second {
+		t.Errorf("Server = %+v, want no shutdown delay from the environment and the default grace", cfg.Server)
+	}
+	if strings.Join(args, " ") != "migrate status" {
+		t.Errorf("LoadConfig() args = %v, want [migrate status]", args)
+	}
+}
+
+func TestLoadConfigJSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"store": "memory", "pool": {"maxOpenConns": 4, "maxIdleConns": 2}}`), 0o600)
+	if err != nil {
+		t.Fatalf("Unable to write config file: %v", err)
+	}
+
+	cfg, _, err := LoadConfig([]string{"-config", path}, testEnv(nil))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.Store != "memory" || cfg.Pool.MaxOpenConns != 4 || cfg.Pool.MaxIdleConns != 2 {
+		t.Errorf("LoadConfig() = %+v", cfg)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("sotre: memory\n"), 0o600); err != nil {
+		t.Fatalf("Unable to write config file: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{name: "Unknown key in config file", args: []string{"-config", path}},
+		{name: "Unknown store backend", args: []string{"-store", "mongo"}},
+		{name: "Invalid listen address", env: map[string]string{"EMP_LISTEN_ADDR": "8080"}},
+		{name: "Invalid port", env: map[string]string{"EMP_DB_PORT": "http"}},
+		{name: "Negative timeout", args: []string{"-timeout-read", "-1s"}},
+		{name: "Negative shutdown delay", env: map[string]string{"EMP_SHUTDOWN_DELAY": "-1s"}},
+		{name: "Idle connections exceed open connections", args: []string{"-db-max-open-conns", "2", "-db-max-idle-conns", "3"}},
+		{name: "Default page size above the maximum", args: []string{"-list-default-limit", "50", "-list-max-limit", "20"}},
+		{name: "Unknown money JSON format", env: map[string]string{"EMP_MONEY_JSON_FORMAT": "float"}},
+		{name: "Unknown flag", args: []string{"-verbose"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := LoadConfig(tt.args, testEnv(tt.env)); err == nil {
+				t.Errorf("LoadConfig() did not return an error")
+			}
+		})
+	}
+}
+
+func TestPostgresConfigDSN(t *testing.T) {
+	cfg := DefaultConfig().Postgres
+	cfg.Password = `it's secret`
+
+	want := `host='localhost' port=5432 user='postgres' password='it\'s secret' dbname='organisation' sslmode='disable'`
+	if got := cfg.DSN(); got != want {
+		t.Errorf("DSN() = %s, want %s", got, want)
+	}
+}
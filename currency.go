@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultCurrency is the currency of salaries sent without one; main replaces it with the
+// configured currency
+var defaultCurrency = "USD"
+
+// validCurrency reports whether code looks like an ISO 4217 currency code, e.g. EUR
+func validCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// ErrExchangeRateNotFound is returned when no rate is recorded for a pair and date
+var ErrExchangeRateNotFound = errors.New("exchange rate not found")
+
+var ErrTimeoutExchangeRate = errors.New("timeout occurred while accessing exchange rates")
+
+// MissingExchangeRateError reports a salary that cannot be converted into the reporting
+// currency because neither its pair nor the opposite pair has a rate effective on the date
+type MissingExchangeRateError struct {
+	From string
+	To   string
+	AsOf time.Time
+}
+
+func (e *MissingExchangeRateError) Error() string {
+	return fmt.Sprintf("no exchange rate from %s to %s effective on %s", e.From, e.To, e.AsOf.Format(time.DateOnly))
+}
+
+// ExchangeRate says that one unit of From is worth Rate units of To from EffectiveDate
+// until the pair's next effective date
+type ExchangeRate struct {
+	From          string
+	To            string
+	EffectiveDate time.Time
+	Rate          *big.Rat
+}
+
+// exchangeRateJSON is the wire form of an ExchangeRate: the date is a plain date and the
+// rate an exact decimal string
+type exchangeRateJSON struct {
+	From          string `json:"from"`
+	To            string `json:"to"`
+	EffectiveDate string `json:"effectiveDate"`
+	Rate          string `json:"rate"`
+}
+
+func (r ExchangeRate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(exchangeRateJSON{
+		From:          r.From,
+		To:            r.To,
+		EffectiveDate: r.EffectiveDate.Format(time.DateOnly),
+		Rate:          formatRate(r.Rate),
+	})
+}
+
+// auditID identifies the rate in the audit log, as FROM/TO/date
+func (r *ExchangeRate) auditID() string {
+	return r.From + "/" + r.To + "/" + r.EffectiveDate.Format(time.DateOnly)
+}
+
+// maxRateDecimals is the scale of the exchange_rate.Rate column
+const maxRateDecimals = 10
+
+// parseRate reads a positive decimal exchange rate with at most maxRateDecimals decimals
+func parseRate(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, errors.New("must be a decimal number")
+	}
+	if r.Sign() <= 0 {
+		return nil, errors.New("must be positive")
+	}
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(maxRateDecimals), nil)))
+	if !scaled.IsInt() {
+		return nil, fmt.Errorf("must have at most %d decimal places", maxRateDecimals)
+	}
+	if r.Cmp(big.NewRat(1e10, 1)) >= 0 {
+		return nil, errMoneyRange
+	}
+	return r, nil
+}
+
+// formatRate writes a rate as the shortest exact decimal, e.g. "0.92"
+func formatRate(r *big.Rat) string {
+	s := r.FloatString(maxRateDecimals)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// ExchangeRateFilter selects the rates of a listing; empty fields match every currency
+type ExchangeRateFilter struct {
+	From string
+	To   string
+}
+
+// Matches reports whether rate passes every set condition of f
+func (f ExchangeRateFilter) Matches(rate *ExchangeRate) bool {
+	return (f.From == "" || rate.From == f.From) && (f.To == "" || rate.To == f.To)
+}
+
+// compareExchangeRates orders rates by pair, then by effective date
+func compareExchangeRates(a, b ExchangeRate) int {
+	if c := strings.Compare(a.From, b.From); c != 0 {
+		return c
+	}
+	if c := strings.Compare(a.To, b.To); c != 0 {
+		return c
+	}
+	return a.EffectiveDate.Compare(b.EffectiveDate)
+}
+
+// ReportingCurrency asks for salaries converted into Currency at the rates effective on AsOf
+type ReportingCurrency struct {
+	Currency string
+	AsOf     time.Time
+}
+
+// parseReportingCurrency reads the reportingCurrency=EUR parameter, appending any problem
+// to errs. Salaries are converted at the rates effective on asOf, today when it is zero.
+func parseReportingCurrency(values url.Values, asOf time.Time, errs *[]FieldError) *ReportingCurrency {
+	currency := strings.ToUpper(values.Get("reportingCurrency"))
+	if currency == "" {
+		return nil
+	}
+
+	reporting := &ReportingCurrency{Currency: currency, AsOf: asOf}
+	if asOf.IsZero() {
+		reporting.AsOf = today()
+	}
+	if !validCurrency(currency) {
+		*errs = append(*errs, FieldError{Field: "reportingCurrency", Message: "must be an ISO 4217 currency code"})
+	}
+	return reporting
+}
+
+// today is the current date in UTC, at midnight like the stored effective dates
+func today() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
+
+// currencyConverter converts amounts into one reporting currency, looking each pair's rate
+// up once
+type currencyConverter struct {
+	store     EmployeeStore
+	reporting ReportingCurrency
+	rates     map[string]*big.Rat
+}
+
+func newCurrencyConverter(store EmployeeStore, reporting ReportingCurrency) *currencyConverter {
+	return &currencyConverter{store: store, reporting: reporting, rates: make(map[string]*big.Rat)}
+}
+
+// rate returns the rate from currency into the reporting currency. A pair's own rate is
+// preferred; failing that, the opposite pair's rate is inverted.
+func (c *currencyConverter) rate(ctx context.Context, currency string) (*big.Rat, error) {
+	if currency == c.reporting.Currency {
+		return big.NewRat(1, 1), nil
+	}
+	if rate, ok := c.rates[currency]; ok {
+		return rate, nil
+	}
+
+	var rate *big.Rat
+	direct, err := c.store.ReadEffectiveExchangeRate(ctx, currency, c.reporting.Currency, c.reporting.AsOf)
+	switch {
+	case err == nil:
+		rate = direct.Rate
+	case errors.Is(err, ErrExchangeRateNotFound):
+		inverse, err := c.store.ReadEffectiveExchangeRate(ctx, c.reporting.Currency, currency, c.reporting.AsOf)
+		if errors.Is(err, ErrExchangeRateNotFound) {
+			return nil, &MissingExchangeRateError{From: currency, To: c.reporting.Currency, AsOf: c.reporting.AsOf}
+		}
+		if err != nil {
+			return nil, err
+		}
+		rate = new(big.Rat).Inv(inverse.Rate)
+	default:
+		return nil, err
+	}
+
+	c.rates[currency] = rate
+	return rate, nil
+}
+
+// convert returns amount, in currency, in the reporting currency
+func (c *currencyConverter) convert(ctx context.Context, amount Money, currency string) (Money, error) {
+	rate, err := c.rate(ctx, currency)
+	if err != nil {
+		return Money{}, err
+	}
+	return amount.Mul(rate)
+}
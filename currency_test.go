@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "0.92", want: "0.92"},
+		{in: "1.0000000000", want: "1"},
+		{in: "156.3", want: "156.3"},
+		{in: "0.0000000001", want: "0.0000000001"},
+		{in: "0.00000000001", wantErr: true},
+		{in: "0", wantErr: true},
+		{in: "-1.5", wantErr: true},
+		{in: "10000000000", wantErr: true},
+		{in: "lots", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseRate(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRate(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if err == nil && formatRate(got) != tt.want {
+				t.Errorf("formatRate(parseRate(%q)) = %q, want %q", tt.in, formatRate(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyMul(t *testing.T) {
+	tests := []struct {
+		amount string
+		rate   *big.Rat
+		want   string
+	}{
+		{"100.00", big.NewRat(92, 100), "92.00"},
+		{"0.05", big.NewRat(1, 2), "0.03"},
+		{"-0.05", big.NewRat(1, 2), "-0.03"},
+		{"0.05", big.NewRat(49, 100), "0.02"},
+		{"10.00", big.NewRat(1, 3), "3.33"},
+		{"20.00", big.NewRat(1, 3), "6.67"},
+	}
+	for _, tt := range tests {
+		got, err := mustMoney(tt.amount).Mul(tt.rate)
+		if err != nil || got != mustMoney(tt.want) {
+			t.Errorf("%s.Mul(%s) = %v, %v, want %s", tt.amount, tt.rate, got, err, tt.want)
+		}
+	}
+}
+
+// putTestRate records a rate through the API
+func putTestRate(t *testing.T, cr *CustomRouter, path, rate string) {
+	t.Helper()
+	rec := serveTestRequest(cr, httptest.NewRequest("PUT", "/exchange-rates/"+path, strings.NewReader(`{"rate": `+rate+`}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /exchange-rates/%s status = %d, want %d: %s", path, rec.Code, http.StatusOK, rec.Body)
+	}
+}
+
+func TestExchangeRateHandlers(t *testing.T) {
+	stores := map[string]func(t *testing.T) EmployeeStore{
+		"memory": func(t *testing.T) EmployeeStore { return initTestStore(t) },
+		"sqlite": func(t *testing.T) EmployeeStore { return initTestSQLiteStore(t) },
+	}
+	for storeName, newStore := range stores {
+		t.Run(storeName, func(t *testing.T) {
+			cr := initTestRouter(t, newStore(t))
+			putTestRate(t, cr, "USD/EUR/2026-01-01", `"0.90"`)
+			putTestRate(t, cr, "usd/eur/2026-03-01", `0.92`)
+			putTestRate(t, cr, "GBP/USD/2026-01-01", `"1.25"`)
+			// Replaces the rate of the same day
+			putTestRate(t, cr, "GBP/USD/2026-01-01", `"1.2734"`)
+
+			// The rate in effect on a date is the latest one that took effect by then
+			for date, want := range map[string]string{"2026-02-15": "0.9", "2026-03-01": "0.92", "2026-12-31": "0.92"} {
+				rec := serveTestRequest(cr, httptest.NewRequest("GET", "/exchange-rates/USD/EUR?date="+date, nil))
+				var rate exchangeRateJSON
+				if err := json.NewDecoder(rec.Body).Decode(&rate); err != nil || rec.Code != http.StatusOK || rate.Rate != want {
+					t.Errorf("GET /exchange-rates/USD/EUR?date=%s = %d %+v, want rate %s", date, rec.Code, rate, want)
+				}
+			}
+			rec := serveTestRequest(cr, httptest.NewRequest("GET", "/exchange-rates/USD/EUR?date=2025-12-31", nil))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("GET before the first rate status = %d, want %d", rec.Code, http.StatusNotFound)
+			}
+
+			// Listing, optionally by currency
+			rec = serveTestRequest(cr, httptest.NewRequest("GET", "/exchange-rates?from=usd", nil))
+			var list struct{ Items []exchangeRateJSON }
+			if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+				t.Fatalf("Unable to decode rates: %v", err)
+			}
+			want := []exchangeRateJSON{
+				{From: "USD", To: "EUR", EffectiveDate: "2026-01-01", Rate: "0.9"},
+				{From: "USD", To: "EUR", EffectiveDate: "2026-03-01", Rate: "0.92"},
+			}
+			if len(list.Items) != len(want) || list.Items[0] != want[0] || list.Items[1] != want[1] {
+				t.Errorf("GET /exchange-rates?from=usd = %+v, want %+v", list.Items, want)
+			}
+
+			// Delete
+			rec = serveTestRequest(cr, httptest.NewRequest("DELETE", "/exchange-rates/GBP/USD/2026-01-01", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("DELETE status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			rec = serveTestRequest(cr, httptest.NewRequest("DELETE", "/exchange-rates/GBP/USD/2026-01-01", nil))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("second DELETE status = %d, want %d", rec.Code, http.StatusNotFound)
+			}
+		})
+	}
+}
+
+func TestPutExchangeRateErrors(t *testing.T) {
+	cr := initTestRouter(t, initTestStore(t))
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{name: "Invalid currency", path: "US/EUR/2026-01-01", body: `{"rate": 1}`, wantStatus: http.StatusBadRequest},
+		{name: "Invalid date", path: "USD/EUR/2026-13-01", body: `{"rate": 1}`, wantStatus: http.StatusBadRequest},
+		{name: "Same currency", path: "USD/USD/2026-01-01", body: `{"rate": 1}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "Missing rate", path: "USD/EUR/2026-01-01", body: `{}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "Negative rate", path: "USD/EUR/2026-01-01", body: `{"rate": -1}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "Rate that is not a number", path: "USD/EUR/2026-01-01", body: `{"rate": "high"}`, wantStatus: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveTestRequest(cr, httptest.NewRequest("PUT", "/exchange-rates/"+tt.path, strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("PUT /exchange-rates/%s status = %d, want %d: %s", tt.path, rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestReportingCurrency(t *testing.T) {
+	stores := map[string]func(t *testing.T) EmployeeStore{
+		"memory": func(t *testing.T) EmployeeStore { return initTestStore(t) },
+		"sqlite": func(t *testing.T) EmployeeStore { return initTestSQLiteStore(t) },
+	}
+	for storeName, newStore := range stores {
+		t.Run(storeName, func(t *testing.T) {
+			store := newStore(t)
+			employees := []Employee{
+				{Name: "Sen", Designation: "Lead", Salary: mustMoney("1000.00"), Currency: "USD"},
+				{Name: "Dan", Designation: "Developer", Salary: mustMoney("2000.00"), Currency: "USD"},
+				{Name: "Ben", Designation: "Developer", Salary: mustMoney("1500.00"), Currency: "EUR"},
+				{Name: "Ann", Designation: "Developer", Salary: mustMoney("100.00"), Currency: "GBP"},
+			}
+			if err := InsertTableEmployee(store, employees); err != nil {
+				t.Fatalf("Unable to insert employees: %v", err)
+			}
+			// asOf also picks the employees as they were then, so date their histories back
+			// before the rates
+			hired := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+			for i := range employees {
+				if _, err := store.UpdateEmployee(context.Background(), i+1, &employees[i], []string{"salary"}, 0, hired); err != nil {
+					t.Fatalf("Unable to backdate employee %d: %v", i+1, err)
+				}
+			}
+			cr := initTestRouter(t, store)
+			putTestRate(t, cr, "USD/EUR/2026-01-01", `"0.90"`)
+			putTestRate(t, cr, "USD/EUR/2026-03-01", `"0.92"`)
+			// Only the opposite pair is recorded for GBP, so its rate is inverted
+			putTestRate(t, cr, "EUR/GBP/2026-01-01", `"0.8"`)
+
+			// Listing
+			rec := serveTestRequest(cr, httptest.NewRequest("GET", "/employees?reportingCurrency=eur&asOf=2026-02-01", nil))
+			var page EmployeePage
+			if err := json.NewDecoder(rec.Body).Decode(&page); err != nil || rec.Code != http.StatusOK {
+				t.Fatalf("GET /employees with a reporting currency = %d, %v", rec.Code, err)
+			}
+			if page.ReportingCurrency != "EUR" || page.AsOf != "2026-02-01" {
+				t.Errorf("page reporting = %q as of %q, want EUR as of 2026-02-01", page.ReportingCurrency, page.AsOf)
+			}
+			for i, want := range []string{"900.00", "1800.00", "1500.00", "125.00"} {
+				if got := page.Items[i].ReportingSalary; got == nil || *got != mustMoney(want) {
+					t.Errorf("items[%d].reportingSalary = %v, want %s", i, got, want)
+				}
+			}
+
+			// Summary over every currency
+			rec = serveTestRequest(cr, httptest.NewRequest("GET", "/employees/salary-summary?reportingCurrency=EUR&asOf=2026-03-01", nil))
+			var summary SalarySummary
+			if err := json.NewDecoder(rec.Body).Decode(&summary); err != nil || rec.Code != http.StatusOK {
+				t.Fatalf("GET /employees/salary-summary = %d, %v", rec.Code, err)
+			}
+			if summary.Count != 4 || *summary.Total != mustMoney("4385.00") || *summary.Average != mustMoney("1096.25") ||
+				*summary.Min != mustMoney("125.00") || *summary.Max != mustMoney("1840.00") {
+				t.Errorf("summary = count %d, total %v, average %v, min %v, max %v, want 4, 4385.00, 1096.25, 125.00, 1840.00",
+					summary.Count, summary.Total, summary.Average, summary.Min, summary.Max)
+			}
+			wantCurrencies := []struct {
+				currency string
+				count    int
+				total    string
+			}{{"EUR", 1, "1500.00"}, {"GBP", 1, "100.00"}, {"USD", 2, "3000.00"}}
+			if len(summary.Currencies) != len(wantCurrencies) {
+				t.Fatalf("summary currencies = %+v", summary.Currencies)
+			}
+			for i, want := range wantCurrencies {
+				got := summary.Currencies[i]
+				if got.Currency != want.currency || got.Count != want.count || got.Total != mustMoney(want.total) {
+					t.Errorf("currencies[%d] = %+v, want %s x%d totalling %s", i, got, want.currency, want.count, want.total)
+				}
+			}
+
+			// Filtered, without a reporting currency
+			rec = serveTestRequest(cr, httptest.NewRequest("GET", "/employees/salary-summary?currency=usd&designation=Developer", nil))
+			summary = SalarySummary{}
+			if err := json.NewDecoder(rec.Body).Decode(&summary); err != nil {
+				t.Fatalf("Unable to decode summary: %v", err)
+			}
+			if summary.Count != 1 || summary.Total != nil || len(summary.Currencies) != 1 || summary.Currencies[0].Total != mustMoney("2000.00") {
+				t.Errorf("filtered summary = %+v", summary)
+			}
+
+			// A currency without any rate cannot be converted
+			rec = serveTestRequest(cr, httptest.NewRequest("GET", "/employees?reportingCurrency=JPY", nil))
+			if rec.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("GET /employees?reportingCurrency=JPY status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+			}
+			if problem := decodeProblem(t, rec); problem.Code != CodeMissingExchangeRate {
+				t.Errorf("problem code = %q, want %q", problem.Code, CodeMissingExchangeRate)
+			}
+		})
+	}
+}
+
+func TestReportingCurrencyQueryErrors(t *testing.T) {
+	cr := initTestRouter(t, initTestStore(t))
+	for _, query := range []string{"reportingCurrency=euro", "reportingCurrency=EUR&asOf=March", "asOf=2026-01-01T00:00", "currency=1"} {
+		for _, path := range []string{"/employees", "/employees/salary-summary"} {
+			rec := serveTestRequest(cr, httptest.NewRequest("GET", path+"?"+query, nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("GET %s?%s status = %d, want %d", path, query, rec.Code, http.StatusBadRequest)
+			}
+		}
+	}
+}
+
+func TestCreateEmployeeDefaultCurrency(t *testing.T) {
+	store := initTestStore(t)
+	emp, err := CreateEmployeeAPI(context.Background(), store, &Employee{Name: "Dan", Designation: "Developer"})
+	if err != nil || emp.Currency != defaultCurrency {
+		t.Errorf("CreateEmployeeAPI() = %+v, %v, want currency %s", emp, err, defaultCurrency)
+	}
+}
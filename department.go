@@ -0,0 +1,25 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// Department groups employees; an employee belongs to at most one, by Employee.DepartmentID
+type Department struct {
+	ID        int       `json:"id" validate:"readonly"`
+	Name      string    `json:"name" validate:"required,max=100"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ErrDepartmentNotFound is returned when the department does not exist
+var ErrDepartmentNotFound = errors.New("department not found")
+
+// ErrDepartmentNotEmpty is returned when deleting a department that employees still belong to
+var ErrDepartmentNotEmpty = errors.New("department still has employees")
+
+// ErrUnknownDepartment is returned when an employee is assigned to a department that does not exist
+var ErrUnknownDepartment = errors.New("department does not exist")
+
+var ErrTimeoutDepartment = errors.New("timeout occurred while accessing departments")
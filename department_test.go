@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDepartmentHandlers(t *testing.T) {
+	stores := map[string]func(t *testing.T) EmployeeStore{
+		"memory": func(t *testing.T) EmployeeStore { return initTestStore(t) },
+		"sqlite": func(t *testing.T) EmployeeStore { return initTestSQLiteStore(t) },
+	}
+	for storeName, newStore := range stores {
+		t.Run(storeName, func(t *testing.T) {
+			cr := initTestRouter(t, newStore(t))
+			do := func(method, path, body string, wantStatus int) *httptest.ResponseRecorder {
+				t.Helper()
+				rec := serveTestRequest(cr, httptest.NewRequest(method, path, strings.NewReader(body)))
+				if rec.Code != wantStatus {
+					t.Fatalf("%s %s status = %d, want %d: %s", method, path, rec.Code, wantStatus, rec.Body)
+				}
+				return rec
+			}
+
+			// Create
+			rec := do("POST", "/departments", `{"name": "Sales"}`, http.StatusCreated)
+			if loc := rec.Header().Get("Location"); loc != "/departments/1" {
+				t.Errorf("Location = %q, want /departments/1", loc)
+			}
+			do("POST", "/departments", `{"name": "Engineering"}`, http.StatusCreated)
+			if problem := decodeProblem(t, do("POST", "/departments", `{"name": "Sales"}`, http.StatusConflict)); problem.Code != CodeConflict {
+				t.Errorf("duplicate name problem code = %q, want %q", problem.Code, CodeConflict)
+			}
+			do("POST", "/departments", `{"name": ""}`, http.StatusUnprocessableEntity)
+
+			// Read and list
+			var dept Department
+			if err := json.NewDecoder(do("GET", "/departments/2", "", http.StatusOK).Body).Decode(&dept); err != nil || dept.Name != "Engineering" {
+				t.Errorf("GET /departments/2 = %+v, %v", dept, err)
+			}
+			do("GET", "/departments/3", "", http.StatusNotFound)
+			do("GET", "/departments/x", "", http.StatusBadRequest)
+			var list struct{ Items []Department }
+			if err := json.NewDecoder(do("GET", "/departments", "", http.StatusOK).Body).Decode(&list); err != nil ||
+				len(list.Items) != 2 || list.Items[0].Name != "Sales" || list.Items[1].Name != "Engineering" {
+				t.Errorf("GET /departments = %+v, %v", list.Items, err)
+			}
+
+			// Update
+			if err := json.NewDecoder(do("PUT", "/departments/2", `{"name": "R&D"}`, http.StatusOK).Body).Decode(&dept); err != nil || dept.Name != "R&D" {
+				t.Errorf("PUT /departments/2 = %+v, %v", dept, err)
+			}
+			do("PUT", "/departments/2", `{"name": "Sales"}`, http.StatusConflict)
+			do("PUT", "/departments/9", `{"name": "Legal"}`, http.StatusNotFound)
+
+			// Assign employees, including to a department that does not exist
+			do("POST", "/employees", `{"name": "Dan", "designation": "Developer", "salary": 100, "departmentId": 2}`, http.StatusCreated)
+			do("POST", "/employees", `{"name": "Sen", "designation": "Lead", "salary": 100, "departmentId": 1}`, http.StatusCreated)
+			do("POST", "/employees", `{"name": "Ben", "designation": "Lead", "salary": 100}`, http.StatusCreated)
+			problem := decodeProblem(t, do("POST", "/employees", `{"name": "Ann", "designation": "Lead", "salary": 100, "departmentId": 9}`, http.StatusUnprocessableEntity))
+			if len(problem.Errors) != 1 || problem.Errors[0].Field != "departmentId" {
+				t.Errorf("unknown department problem = %+v", problem)
+			}
+			req := httptest.NewRequest("PATCH", "/employees/3", strings.NewReader(`{"departmentId": 9}`))
+			req.Header.Set("Content-Type", mediaTypeMergePatch)
+			if rec := serveTestRequest(cr, req); rec.Code != http.StatusUnprocessableEntity {
+				t.Errorf("PATCH to an unknown department status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+			}
+
+			// Filter by department
+			var page EmployeePage
+			if err := json.NewDecoder(do("GET", "/employees?department=2", "", http.StatusOK).Body).Decode(&page); err != nil ||
+				len(page.Items) != 1 || page.Items[0].Name != "Dan" {
+				t.Errorf("GET /employees?department=2 = %+v, %v", page.Items, err)
+			}
+			do("GET", "/employees?department=sales", "", http.StatusBadRequest)
+
+			// A department with employees cannot be deleted until they leave it
+			do("DELETE", "/departments/2", "", http.StatusConflict)
+			req = httptest.NewRequest("PATCH", "/employees/1", strings.NewReader(`{"departmentId": null}`))
+			req.Header.Set("Content-Type", mediaTypeMergePatch)
+			if rec := serveTestRequest(cr, req); rec.Code != http.StatusOK {
+				t.Fatalf("PATCH departmentId null status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+			}
+			do("DELETE", "/departments/2", "", http.StatusOK)
+			do("DELETE", "/departments/2", "", http.StatusNotFound)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Dialect describes the differences between the SQL databases supported by SQLEmployeeStore.
+// Queries are written once using PostgreSQL-style $1 placeholders and rebound per dialect.
+type Dialect interface {
+	// Name returns the database/sql driver name for the dialect
+	Name() string
+	// Rebind rewrites $1-style placeholders into the dialect's bind syntax
+	Rebind(query string) string
+	// CreateMigrationsTableSQL returns the DDL that creates the schema_migrations tracking table
+	CreateMigrationsTableSQL() string
+	// UniqueViolation reports whether err is a unique-constraint violation, and if so
+	// which column (lower case) and, when the driver reports it, which value conflicted
+	UniqueViolation(err error) (field, value string, ok bool)
+	// ForeignKeyViolation reports whether err is a foreign-key violation: a row referring to
+	// a missing row, or the removal of a row still referred to
+	ForeignKeyViolation(err error) bool
+	// HierarchyLockSQL returns a statement that serialises reporting line changes until the
+	// end of the transaction, or "" when writes are serialised anyway
+	HierarchyLockSQL() string
+	// MigrationLockSQL returns a statement that makes other migrators wait until the end of
+	// the transaction, or "" when the transaction already holds the write lock
+	MigrationLockSQL() string
+	// AuditLockSQL returns a statement that serialises audit log appends until the end of the
+	// transaction, or "" when writes are serialised anyway
+	AuditLockSQL() string
+	// ForUpdateSQL returns the clause that locks the rows a SELECT reads until the end of the
+	// transaction, or "" when writes are serialised anyway
+	ForUpdateSQL() string
+	// EstimateRowsSQL returns a query estimating the rows of the table named by $1 from
+	// planner statistics, or "" when the dialect keeps none
+	EstimateRowsSQL() string
+}
+
+type postgresDialect struct{}
+
+// PostgresDialect is the Dialect for PostgreSQL
+var PostgresDialect Dialect = postgresDialect{}
+
+func (postgresDialect) Name() string { return "postgres" }
+
+func (postgresDialect) Rebind(query string) string { return query }
+
+func (postgresDialect) CreateMigrationsTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		Version INTEGER PRIMARY KEY,
+		Name VARCHAR(255) NOT NULL,
+		Checksum CHAR(64) NOT NULL,
+		AppliedAt TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	`
+}
+
+// pqUniqueDetail matches the detail of a unique violation, e.g. "Key (name)=(Sales) already exists."
+var pqUniqueDetail = regexp.MustCompile(`^Key \((.+?)\)=\((.*)\) already exists`)
+
+func (postgresDialect) UniqueViolation(err error) (string, string, bool) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return "", "", false
+	}
+	if match := pqUniqueDetail.FindStringSubmatch(pqErr.Detail); match != nil {
+		return strings.ToLower(match[1]), match[2], true
+	}
+	return pqErr.Constraint, "", true
+}
+
+func (postgresDialect) ForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+func (postgresDialect) HierarchyLockSQL() string {
+	return "SELECT pg_advisory_xact_lock(hashtext('employee.ManagerID'))"
+}
+
+func (postgresDialect) MigrationLockSQL() string {
+	return "SELECT pg_advisory_xact_lock(hashtext('schema_migrations'))"
+}
+
+// AuditLockSQL still lets readers through while an append waits for the chain's last entry
+func (postgresDialect) AuditLockSQL() string { return "LOCK TABLE audit_log IN EXCLUSIVE MODE" }
+
+func (postgresDialect) ForUpdateSQL() string { return " FOR UPDATE" }
+
+func (postgresDialect) EstimateRowsSQL() string {
+	// reltuples is -1 (0 before PostgreSQL 14) until the table is first analysed
+	return "SELECT reltuples::BIGINT FROM pg_class WHERE oid = to_regclass($1)"
+}
+
+type sqliteDialect struct{}
+
+// SQLiteDialect is the Dialect for SQLite
+var SQLiteDialect Dialect = sqliteDialect{}
+
+var postgresPlaceholder = regexp.MustCompile(`\$(\d+)`)
+
+func (sqliteDialect) Name() string { return "sqlite3" }
+
+// Rebind turns $1 into ?1, which SQLite binds by the same ordinal
+func (sqliteDialect) Rebind(query string) string {
+	return postgresPlaceholder.ReplaceAllString(query, "?$1")
+}
+
+func (sqliteDialect) CreateMigrationsTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		Version INTEGER PRIMARY KEY,
+		Name VARCHAR(255) NOT NULL,
+		Checksum CHAR(64) NOT NULL,
+		AppliedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+}
+
+func (sqliteDialect) UniqueViolation(err error) (string, string, bool) {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) ||
+		(sqliteErr.ExtendedCode != sqlite3.ErrConstraintUnique && sqliteErr.ExtendedCode != sqlite3.ErrConstraintPrimaryKey) {
+		return "", "", false
+	}
+
+	// The message names the columns, e.g. "UNIQUE constraint failed: department.Name"
+	_, columns, _ := strings.Cut(sqliteErr.Error(), "failed: ")
+	var fields []string
+	for _, column := range strings.Split(columns, ", ") {
+		_, name, _ := strings.Cut(column, ".")
+		fields = append(fields, strings.ToLower(name))
+	}
+	return strings.Join(fields, ", "), "", true
+}
+
+func (sqliteDialect) ForeignKeyViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey
+}
+
+// HierarchyLockSQL returns "": the store keeps one SQLite connection, so transactions run one at a time
+func (sqliteDialect) HierarchyLockSQL() string { return "" }
+
+// MigrationLockSQL returns "": transactions open with BEGIN IMMEDIATE (_txlock=immediate), which
+// waits for other writers, other processes included, up front
+func (sqliteDialect) MigrationLockSQL() string { return "" }
+
+func (sqliteDialect) AuditLockSQL() string { return "" }
+
+func (sqliteDialect) ForUpdateSQL() string { return "" }
+
+func (sqliteDialect) EstimateRowsSQL() string { return "" }
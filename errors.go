@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// statusClientClosedRequest is the nginx convention for requests abandoned by the client
+const statusClientClosedRequest = 499
+
+// Stable error codes returned in the "code" member of problem responses
+const (
+	CodeMalformedRequest     = "malformed-request"
+	CodeInvalidID            = "invalid-id"
+	CodeInvalidQuery         = "invalid-query"
+	CodeNotFound             = "not-found"
+	CodeGone                 = "gone"
+	CodeValidation           = "validation-failed"
+	CodeConflict             = "conflict"
+	CodeUnsupportedMedia     = "unsupported-media-type"
+	CodeInvalidPatch         = "invalid-patch"
+	CodePatchTestFailed      = "patch-test-failed"
+	CodePreconditionFailed   = "precondition-failed"
+	CodePreconditionRequired = "precondition-required"
+	CodeMissingExchangeRate  = "missing-exchange-rate"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeTimeout              = "timeout"
+	CodeCancelled            = "request-cancelled"
+	CodeInternal             = "internal-error"
+)
+
+// FieldError describes a problem with a single field of a request body
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// APIError is an error that knows how it should be presented to API clients.
+// Err is the underlying cause; it is logged but never sent to the client.
+type APIError struct {
+	Status int
+	Code   string
+	Title  string
+	Detail string
+	Errors []FieldError
+	Err    error
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return e.Detail + ": " + e.Err.Error()
+	}
+	return e.Detail
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Problem is an RFC 7807 application/problem+json response body
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// NewBadRequestError reports a request that could not be parsed
+func NewBadRequestError(code, detail string, err error) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: code, Title: "Bad request", Detail: detail, Err: err}
+}
+
+// NewUnsupportedMediaTypeError reports a request body in a content type the endpoint does not accept
+func NewUnsupportedMediaTypeError(accepted ...string) *APIError {
+	return &APIError{
+		Status: http.StatusUnsupportedMediaType,
+		Code:   CodeUnsupportedMedia,
+		Title:  "Unsupported media type",
+		Detail: "Content-Type must be " + strings.Join(accepted, " or "),
+	}
+}
+
+// toAPIError maps store and API errors onto their client-facing representation
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+	var conflictErr *ConflictError
+	var missingRateErr *MissingExchangeRateError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.As(err, &conflictErr):
+		return &APIError{
+			Status: http.StatusConflict,
+			Code:   CodeConflict,
+			Title:  "Conflict",
+			Detail: conflictErr.Error(),
+			Errors: []FieldError{{Field: conflictErr.Field, Message: "already exists"}},
+			Err:    err,
+		}
+	case errors.Is(err, ErrVersionMismatch):
+		return &APIError{Status: http.StatusPreconditionFailed, Code: CodePreconditionFailed, Title: "Precondition failed", Detail: "The employee has been modified; fetch it again and retry with its current ETag", Err: err}
+	case errors.As(err, &missingRateErr):
+		return &APIError{Status: http.StatusUnprocessableEntity, Code: CodeMissingExchangeRate, Title: "Missing exchange rate", Detail: missingRateErr.Error(), Err: err}
+	case errors.Is(err, ErrEmployeeNotFound):
+		return &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Title: "Not found", Detail: "Employee not found", Err: err}
+	case errors.Is(err, ErrEmployeeNotStarted):
+		return &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Title: "Not found", Detail: "The employee has no record as of that date", Err: err}
+	case errors.Is(err, ErrEmployeeDeleted):
+		return &APIError{Status: http.StatusGone, Code: CodeGone, Title: "Gone", Detail: "The employee had been deleted by that date", Err: err}
+	case errors.Is(err, ErrDepartmentNotFound):
+		return &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Title: "Not found", Detail: "Department not found", Err: err}
+	case errors.Is(err, ErrDepartmentNotEmpty):
+		return &APIError{Status: http.StatusConflict, Code: CodeConflict, Title: "Conflict", Detail: "The department still has employees; reassign them first", Err: err}
+	case errors.Is(err, ErrUnknownDepartment):
+		return &APIError{
+			Status: http.StatusUnprocessableEntity,
+			Code:   CodeValidation,
+			Title:  "Validation failed",
+			Detail: "1 invalid fields",
+			Errors: []FieldError{{Field: "departmentId", Message: "does not match a department"}},
+			Err:    err,
+		}
+	case errors.Is(err, ErrEmployeeHasReports):
+		return &APIError{Status: http.StatusConflict, Code: CodeConflict, Title: "Conflict", Detail: "Other employees still report to this employee; reassign them first", Err: err}
+	case errors.Is(err, ErrUnknownManager), errors.Is(err, ErrManagerCycle):
+		message := "does not match an employee"
+		if errors.Is(err, ErrManagerCycle) {
+			message = "would make the employee report to itself"
+		}
+		return &APIError{
+			Status: http.StatusUnprocessableEntity,
+			Code:   CodeValidation,
+			Title:  "Validation failed",
+			Detail: "1 invalid fields",
+			Errors: []FieldError{{Field: "managerId", Message: message}},
+			Err:    err,
+		}
+	case errors.Is(err, ErrExchangeRateNotFound):
+		return &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Title: "Not found", Detail: "Exchange rate not found", Err: err}
+	case errors.Is(err, ErrTimeoutCreatingEmployee),
+		errors.Is(err, ErrTimeoutReadingEmployee),
+		errors.Is(err, ErrTimeoutUpdatingEmployee),
+		errors.Is(err, ErrTimeoutDeletingEmployee),
+		errors.Is(err, ErrTimeoutExchangeRate),
+		errors.Is(err, ErrTimeoutDepartment),
+		errors.Is(err, ErrTimeoutHierarchy),
+		errors.Is(err, ErrTimeoutHistory),
+		errors.Is(err, ErrTimeoutAudit),
+		errors.Is(err, context.DeadlineExceeded):
+		return &APIError{Status: http.StatusGatewayTimeout, Code: CodeTimeout, Title: "Timeout", Detail: err.Error(), Err: err}
+	case errors.Is(err, context.Canceled):
+		return &APIError{Status: statusClientClosedRequest, Code: CodeCancelled, Title: "Request cancelled", Detail: "The request was cancelled by the client", Err: err}
+	default:
+		return &APIError{Status: http.StatusInternalServerError, Code: CodeInternal, Title: "Internal server error", Detail: "An unexpected error occurred", Err: err}
+	}
+}
+
+// writeProblem sends err to the client as application/problem+json
+func writeProblem(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := toAPIError(err)
+	requestID := RequestIDFromContext(r.Context())
+
+	// Internal details stay in the log, correlated by request ID
+	if apiErr.Status >= http.StatusInternalServerError {
+		log.Printf("request %s: %s %s: %v", requestID, r.Method, r.URL.Path, err)
+	}
+
+	problem := Problem{
+		Type:      "/problems/" + apiErr.Code,
+		Title:     apiErr.Title,
+		Status:    apiErr.Status,
+		Detail:    apiErr.Detail,
+		Instance:  r.URL.Path,
+		Code:      apiErr.Code,
+		RequestID: requestID,
+		Errors:    apiErr.Errors,
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// NotFoundHandler answers requests for unknown routes with a problem response
+func NotFoundHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Title: "Not found", Detail: "No such resource"})
+	}
+}
+
+// MethodNotAllowedHandler answers requests with an unsupported method with a problem response
+func MethodNotAllowedHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, &APIError{Status: http.StatusMethodNotAllowed, Code: "method-not-allowed", Title: "Method not allowed", Detail: r.Method + " is not supported on this resource"})
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// requireIfMatch makes If-Match mandatory on employee writes; main sets it from the configuration
+var requireIfMatch bool
+
+// employeeETag is the strong entity tag of an employee, derived from its version
+func employeeETag(emp *Employee) string {
+	return `"` + strconv.Itoa(emp.Version) + `"`
+}
+
+// entityTags splits an If-Match or If-None-Match header into its entity tags
+func entityTags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ifMatchVersion turns the If-Match header into the version a write is conditional on.
+// It returns 0 for an unconditional write (no header, or "*") and -1 when no listed tag can
+// match, such as weak or foreign ones, so the store reports the mismatch. A list of several
+// tags is matched against the employee's current version, which the store then checks again.
+func ifMatchVersion(r *http.Request, store EmployeeStore, id int) (int, error) {
+	tags := entityTags(r.Header.Get("If-Match"))
+	if len(tags) == 0 {
+		if requireIfMatch {
+			return 0, NewPreconditionRequiredError()
+		}
+		return 0, nil
+	}
+	if slices.Contains(tags, "*") {
+		return 0, nil
+	}
+
+	// If-Match uses the strong comparison, so weak tags never match
+	var versions []int
+	for _, tag := range tags {
+		version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(tag, `"`), `"`))
+		if err == nil && strings.HasPrefix(tag, `"`) && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+	switch len(versions) {
+	case 0:
+		return -1, nil
+	case 1:
+		return versions[0], nil
+	}
+
+	current, err := ReadEmployeeAPI(r.Context(), store, id)
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(versions, current.Version) {
+		return -1, nil
+	}
+	return current.Version, nil
+}
+
+// noneMatch reports whether the If-None-Match header lists etag, using the weak comparison
+func noneMatch(r *http.Request, etag string) bool {
+	for _, tag := range entityTags(r.Header.Get("If-None-Match")) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// NewPreconditionRequiredError reports a write sent without the If-Match header the server requires
+func NewPreconditionRequiredError() *APIError {
+	return &APIError{
+		Status: http.StatusPreconditionRequired,
+		Code:   CodePreconditionRequired,
+		Title:  "Precondition required",
+		Detail: "Send If-Match with the employee's current ETag",
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEmployeeETags(t *testing.T) {
+	store := initTestStore(t)
+	if err := InsertTableEmployee(store, []Employee{{Name: "Dan", Designation: "Software Developer", Salary: mustMoney("23456.00")}}); err != nil {
+		t.Fatalf("Unable to insert employee: %v", err)
+	}
+	cr := initTestRouter(t, store)
+	body := `{"name": "Dan", "designation": "Lead", "salary": 30000}`
+
+	tests := []struct {
+		name       string
+		method     string
+		header     string
+		value      string
+		body       string
+		wantStatus int
+		wantETag   string
+	}{
+		{name: "Read returns the ETag", method: "GET", wantStatus: http.StatusOK, wantETag: `"1"`},
+		{name: "Matching If-None-Match", method: "GET", header: "If-None-Match", value: `W/"1"`, wantStatus: http.StatusNotModified, wantETag: `"1"`},
+		{name: "Stale If-None-Match", method: "GET", header: "If-None-Match", value: `"0"`, wantStatus: http.StatusOK, wantETag: `"1"`},
+		{name: "Update with the current ETag", method: "PUT", header: "If-Match", value: `"1"`, body: body, wantStatus: http.StatusOK, wantETag: `"2"`},
+		{name: "Update with a stale ETag", method: "PUT", header: "If-Match", value: `"1"`, body: body, wantStatus: http.StatusPreconditionFailed},
+		{name: "Weak ETags never match writes", method: "PUT", header: "If-Match", value: `W/"2"`, body: body, wantStatus: http.StatusPreconditionFailed},
+		{name: "Patch with a stale ETag", method: "PATCH", header: "If-Match", value: `"1"`, body: `{"salary": 0}`, wantStatus: http.StatusPreconditionFailed},
+		{name: "Update without If-Match", method: "PUT", body: body, wantStatus: http.StatusOK, wantETag: `"3"`},
+		{name: "A list of stale ETags", method: "PUT", header: "If-Match", value: `"1", "2", W/"3"`, body: body, wantStatus: http.StatusPreconditionFailed},
+		{name: "A list holding the current ETag", method: "PUT", header: "If-Match", value: `"2", "3"`, body: body, wantStatus: http.StatusOK, wantETag: `"4"`},
+		{name: "Delete with a stale ETag", method: "DELETE", header: "If-Match", value: `"3"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "Delete with the current ETag", method: "DELETE", header: "If-Match", value: `"4"`, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/employees/1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := serveTestRequest(cr, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("%s /employees/1 status = %d, want %d", tt.method, rec.Code, tt.wantStatus)
+			}
+			if tt.wantETag != "" && rec.Header().Get("ETag") != tt.wantETag {
+				t.Errorf("ETag = %q, want %q", rec.Header().Get("ETag"), tt.wantETag)
+			}
+			if rec.Code == http.StatusPreconditionFailed {
+				if problem := decodeProblem(t, rec); problem.Code != CodePreconditionFailed {
+					t.Errorf("problem code = %q, want %q", problem.Code, CodePreconditionFailed)
+				}
+			}
+		})
+	}
+}
+
+func TestRequireIfMatch(t *testing.T) {
+	defer func(required bool) { requireIfMatch = required }(requireIfMatch)
+	requireIfMatch = true
+
+	store := initTestStore(t)
+	if err := InsertTableEmployee(store, []Employee{{Name: "Dan", Designation: "Software Developer", Salary: mustMoney("23456.00")}}); err != nil {
+		t.Fatalf("Unable to insert employee: %v", err)
+	}
+	cr := initTestRouter(t, store)
+
+	rec := serveTestRequest(cr, httptest.NewRequest("DELETE", "/employees/1", nil))
+	if rec.Code != http.StatusPreconditionRequired {
+		t.Fatalf("DELETE /employees/1 status = %d, want %d", rec.Code, http.StatusPreconditionRequired)
+	}
+	if problem := decodeProblem(t, rec); problem.Code != CodePreconditionRequired {
+		t.Errorf("problem code = %q, want %q", problem.Code, CodePreconditionRequired)
+	}
+
+	// Reads stay unconditional
+	if rec := serveTestRequest(cr, httptest.NewRequest("GET", "/employees/1", nil)); rec.Code != http.StatusOK {
+		t.Errorf("GET /employees/1 status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
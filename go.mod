@@ -4,5 +4,7 @@ go 1.22.4
 
 require (
 	github.com/gorilla/mux v1.8.1
-	github.com/lib/pq v1.10.9 // indirect
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.33
+	gopkg.in/yaml.v3 v3.0.1
 )
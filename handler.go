@@ -1,173 +1,701 @@
 package main
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
-func CreateEmployeeHandler(db *sql.DB) http.HandlerFunc {
+var errEmptyBody = errors.New("request body is null")
+
+// writeJSON sends v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// mediaTypeMergePatch is the content type of an RFC 7396 JSON merge patch
+const mediaTypeMergePatch = "application/merge-patch+json"
+
+// hasMediaType reports whether the request body has one of the given content types
+func hasMediaType(r *http.Request, types ...string) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, t := range types {
+		if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// employeeID parses the {id} route variable
+func employeeID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, NewBadRequestError(CodeInvalidID, "Invalid employee ID", err)
+	}
+	return id, nil
+}
+
+func CreateEmployeeHandler(store EmployeeStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var emp *Employee
-		err := json.NewDecoder(r.Body).Decode(&emp)
+		// Decode and validate the employee before touching the database
+		emp := newEmployee()
+		_, err := decodeAndValidate(r.Body, emp, false)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeProblem(w, r, err)
 			return
 		}
 
 		// Call a function to insert the employee data into the database
-		emp, err = CreateEmployeeAPI(db, emp)
+		emp, err = CreateEmployeeAPI(r.Context(), store, emp)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeProblem(w, r, err)
 			return
 		}
 
-		// Set the appropriate response status and content type
-		w.WriteHeader(http.StatusCreated)
-		w.Header().Set("Content-Type", "application/json")
-
-		// Encode the response JSON
-		json.NewEncoder(w).Encode(emp)
+		// Point to the new resource and encode the response JSON
+		w.Header().Set("Location", "/employees/"+strconv.Itoa(emp.ID))
+		w.Header().Set("ETag", employeeETag(emp))
+		writeJSON(w, http.StatusCreated, emp)
 	}
 }
 
-func ReadEmployeeHandler(db *sql.DB) http.HandlerFunc {
+func ReadEmployeeHandler(store EmployeeStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get the employee ID from the request parameters
-		vars := mux.Vars(r)
-		id, err := strconv.Atoi(vars["id"])
+		id, err := employeeID(r)
 		if err != nil {
-			http.Error(w, "Invalid employee ID", http.StatusBadRequest)
+			writeProblem(w, r, err)
 			return
 		}
 
-		var emp *Employee
+		asOf, fieldErr := parseAsOf(r.URL.Query().Get("asOf"))
+		if fieldErr != nil {
+			writeProblem(w, r, NewInvalidQueryError([]FieldError{*fieldErr}))
+			return
+		}
 
-		// Call the API function to retrieve the employee by ID
-		emp, apiErr := ReadEmployeeAPI(db, id, emp)
-		if apiErr != nil {
-			if apiErr == sql.ErrNoRows {
-				http.Error(w, "Employee not found", http.StatusNotFound)
-			} else {
-				http.Error(w, apiErr.Error(), http.StatusInternalServerError)
+		// A past state is rebuilt from the history and has no version of its own to revalidate
+		if !asOf.IsZero() {
+			emp, err := ReadEmployeeAsOfAPI(r.Context(), store, id, asOf)
+			if err != nil {
+				writeProblem(w, r, err)
+				return
 			}
+			writeJSON(w, http.StatusOK, emp)
 			return
 		}
 
-		// Set the appropriate content type header
-		w.Header().Set("Content-Type", "application/json")
-
-		// Encode the retrieved employee as JSON and send it in the response
-		err = json.NewEncoder(w).Encode(emp)
+		// Call the API function to retrieve the employee by ID
+		emp, err := ReadEmployeeAPI(r.Context(), store, id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeProblem(w, r, err)
+			return
+		}
+
+		// Let clients revalidate a cached copy without the body
+		etag := employeeETag(emp)
+		w.Header().Set("ETag", etag)
+		if noneMatch(r, etag) {
+			w.WriteHeader(http.StatusNotModified)
 			return
 		}
+
+		// Encode the retrieved employee as JSON and send it in the response
+		writeJSON(w, http.StatusOK, emp)
 	}
 }
 
-func ReadEmployeeListHandler(db *sql.DB) http.HandlerFunc {
+func ReadEmployeeListHandler(store EmployeeStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Parse query parameters for pagination
-		page, err := strconv.Atoi(r.URL.Query().Get("page"))
-		if err != nil || page < 1 {
-			page = 1 // Default to page 1 if page parameter is invalid
-		}
-		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
-		if err != nil || limit < 1 {
-			limit = 10 // Default to 10 records per page if limit parameter is invalid
+		// Parse the filter, sort and page parameters
+		q, total, err := parseEmployeeListQuery(r.URL.Query())
+		if err != nil {
+			writeProblem(w, r, err)
+			return
 		}
-		offset := (page - 1) * limit
 
-		// Call the API function to retrieve paginated employees
-		employees, apiErr := ReadEmployeeListAPI(db, limit, offset)
-		if apiErr != nil {
-			http.Error(w, apiErr.Error(), http.StatusInternalServerError)
+		// Call the API function to retrieve the page of employees
+		page, err := ReadEmployeeListAPI(r.Context(), store, q, total)
+		if err != nil {
+			writeProblem(w, r, err)
 			return
 		}
 
-		// Set the appropriate content type header
-		w.Header().Set("Content-Type", "application/json")
+		// Point to the neighbouring pages
+		w.Header().Set("Link", pageLinks(r.URL, q, page))
+
+		// Encode the page as JSON and send it in the response
+		writeJSON(w, http.StatusOK, page)
+	}
+}
 
-		// Encode the retrieved employees as JSON and send them in the response
-		err = json.NewEncoder(w).Encode(employees)
+func SalarySummaryHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Parse the filter and the optional reporting currency; sorting does not apply
+		values := r.URL.Query()
+		f, _, err := parseEmployeeFilter(values)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeProblem(w, r, err)
+			return
+		}
+		var errs []FieldError
+		reporting := parseReportingCurrency(values, f.AsOf, &errs)
+		if len(errs) > 0 {
+			writeProblem(w, r, NewInvalidQueryError(errs))
 			return
 		}
+
+		// Call the API function to total the matching salaries
+		summary, err := SalarySummaryAPI(r.Context(), store, f, reporting)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, summary)
 	}
 }
 
-func UpdateEmployeeHandler(db *sql.DB) http.HandlerFunc {
+func UpdateEmployeeHandler(store EmployeeStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get the employee ID from the request parameters
-		vars := mux.Vars(r)
-		id, err := strconv.Atoi(vars["id"])
+		id, err := employeeID(r)
 		if err != nil {
-			http.Error(w, "Invalid employee ID", http.StatusBadRequest)
+			writeProblem(w, r, err)
 			return
 		}
 
-		var empReq *Employee
-		err = json.NewDecoder(r.Body).Decode(&empReq)
+		// Only overwrite the version the client last saw
+		version, err := ifMatchVersion(r, store, id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeProblem(w, r, err)
 			return
 		}
 
-		// Call the API function to update the employee by ID
-		emp, apiErr := UpdateEmployeeAPI(db, id, empReq)
-		if apiErr != nil {
-			if apiErr == sql.ErrNoRows {
-				http.Error(w, "Employee not found", http.StatusNotFound)
-			} else {
-				http.Error(w, apiErr.Error(), http.StatusInternalServerError)
-			}
+		// Designation and salary changes may be backdated or scheduled
+		effective, err := effectiveFromQuery(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		// PUT replaces the whole employee, so every field is validated
+		empReq := newEmployee()
+		_, err = decodeAndValidate(r.Body, empReq, false)
+		if err != nil {
+			writeProblem(w, r, err)
 			return
 		}
 
-		// Set the appropriate content type header
-		w.Header().Set("Content-Type", "application/json")
+		// Call the API function to update the employee by ID
+		emp, err := UpdateEmployeeAPI(r.Context(), store, id, empReq, version, effective)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
 
 		// Encode the updated employee as JSON and send it in the response
-		err = json.NewEncoder(w).Encode(emp)
+		w.Header().Set("ETag", employeeETag(emp))
+		writeJSON(w, http.StatusOK, emp)
+	}
+}
+
+func PatchEmployeeHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get the employee ID from the request parameters
+		id, err := employeeID(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		// Only overwrite the version the client last saw
+		version, err := ifMatchVersion(r, store, id)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		effective, err := effectiveFromQuery(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeProblem(w, r, err)
 			return
 		}
+
+		var emp *Employee
+		switch {
+		case hasMediaType(r, mediaTypeJSONPatch):
+			// A list of operations applied to the stored employee
+			ops, err := decodeJSONPatch(r.Body)
+			if err != nil {
+				writeProblem(w, r, err)
+				return
+			}
+			emp, err = JSONPatchEmployeeAPI(r.Context(), store, id, ops, version, effective)
+			if err != nil {
+				writeProblem(w, r, err)
+				return
+			}
+		case hasMediaType(r, mediaTypeMergePatch, "application/json"):
+			// Members absent from the merge patch keep their current values
+			empReq := &Employee{}
+			fields, err := decodeAndValidate(r.Body, empReq, true)
+			if err != nil {
+				writeProblem(w, r, err)
+				return
+			}
+			emp, err = PatchEmployeeAPI(r.Context(), store, id, empReq, fields, version, effective)
+			if err != nil {
+				writeProblem(w, r, err)
+				return
+			}
+		default:
+			writeProblem(w, r, NewUnsupportedMediaTypeError(mediaTypeMergePatch, mediaTypeJSONPatch))
+			return
+		}
+
+		// Encode the updated employee as JSON and send it in the response
+		w.Header().Set("ETag", employeeETag(emp))
+		writeJSON(w, http.StatusOK, emp)
 	}
 }
 
-func DeleteEmployeeHandler(db *sql.DB) http.HandlerFunc {
+func DeleteEmployeeHandler(store EmployeeStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get the employee ID from the request parameters
-		vars := mux.Vars(r)
-		id, err := strconv.Atoi(vars["id"])
+		id, err := employeeID(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		// Only delete the version the client last saw
+		version, err := ifMatchVersion(r, store, id)
 		if err != nil {
-			http.Error(w, "Invalid employee ID", http.StatusBadRequest)
+			writeProblem(w, r, err)
 			return
 		}
 
 		// Call the API function to delete the employee by ID
-		apiErr := DeleteEmployeeAPI(db, id)
-		if apiErr != nil {
-			if apiErr == sql.ErrNoRows {
-				http.Error(w, "Employee not found", http.StatusNotFound)
-			} else {
-				http.Error(w, apiErr.Error(), http.StatusInternalServerError)
-			}
+		err = DeleteEmployeeAPI(r.Context(), store, id, version)
+		if err != nil {
+			writeProblem(w, r, err)
 			return
 		}
 
 		// Respond with success message
 		successMessage := map[string]string{"message": "Employee deleted successfully"}
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(successMessage); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSON(w, http.StatusOK, successMessage)
+	}
+}
+
+// departmentID parses the {id} route variable of a department route
+func departmentID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, NewBadRequestError(CodeInvalidID, "Invalid department ID", err)
+	}
+	return id, nil
+}
+
+func CreateDepartmentHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Decode and validate the department before touching the database
+		dept := &Department{}
+		if _, err := decodeAndValidate(r.Body, dept, false); err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		dept, err := CreateDepartmentAPI(r.Context(), store, dept)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		w.Header().Set("Location", "/departments/"+strconv.Itoa(dept.ID))
+		writeJSON(w, http.StatusCreated, dept)
+	}
+}
+
+func ReadDepartmentHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := departmentID(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		dept, err := ReadDepartmentAPI(r.Context(), store, id)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, dept)
+	}
+}
+
+func ReadDepartmentListHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		depts, err := ReadDepartmentListAPI(r.Context(), store)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string][]Department{"items": depts})
+	}
+}
+
+func UpdateDepartmentHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := departmentID(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		dept := &Department{}
+		if _, err := decodeAndValidate(r.Body, dept, false); err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		updated, err := UpdateDepartmentAPI(r.Context(), store, id, dept)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	}
+}
+
+func DeleteDepartmentHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := departmentID(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		if err := DeleteDepartmentAPI(r.Context(), store, id); err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Department deleted successfully"})
+	}
+}
+
+// maxDepthQuery reads the maxDepth query parameter of r
+func maxDepthQuery(r *http.Request) (int, error) {
+	depth, fieldErr := parseMaxDepth(r.URL.Query().Get("maxDepth"))
+	if fieldErr != nil {
+		return 0, NewInvalidQueryError([]FieldError{*fieldErr})
+	}
+	return depth, nil
+}
+
+// ReadReportsHandler lists the direct reports of an employee
+func ReadReportsHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := employeeID(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		list, err := ReadSubordinatesAPI(r.Context(), store, id, 1)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// ReadSubordinatesHandler lists everyone below an employee, level by level
+func ReadSubordinatesHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := employeeID(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		maxDepth, err := maxDepthQuery(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		list, err := ReadSubordinatesAPI(r.Context(), store, id, maxDepth)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// ReadManagerChainHandler lists the managers above an employee, up to the top of the organisation
+func ReadManagerChainHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := employeeID(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		maxDepth, err := maxDepthQuery(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		list, err := ReadManagerChainAPI(r.Context(), store, id, maxDepth)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// orgChartContentTypes maps each format to the media type it is served as
+var orgChartContentTypes = map[string]string{
+	OrgChartJSON:    "application/json",
+	OrgChartDOT:     "text/vnd.graphviz; charset=utf-8",
+	OrgChartMermaid: "text/plain; charset=utf-8",
+}
+
+func OrgChartHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts, format, err := parseOrgChartQuery(r.URL.Query())
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		chart, err := OrgChartAPI(r.Context(), store, opts)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", orgChartContentTypes[format])
+		w.WriteHeader(http.StatusOK)
+		// The status is sent by now, so a failed write can only be logged
+		if err := writeOrgChart(w, chart, format); err != nil {
+			log.Printf("request %s: %s %s: writing the org chart: %v", RequestIDFromContext(r.Context()), r.Method, r.URL.Path, err)
+		}
+	}
+}
+
+// exchangeRateKey parses the {from}, {to} and {date} route variables; currency codes are
+// accepted in any case
+func exchangeRateKey(r *http.Request) (from, to string, date time.Time, err error) {
+	vars := mux.Vars(r)
+	from, to = strings.ToUpper(vars["from"]), strings.ToUpper(vars["to"])
+
+	var errs []FieldError
+	if !validCurrency(from) {
+		errs = append(errs, FieldError{Field: "from", Message: "must be an ISO 4217 currency code"})
+	}
+	if !validCurrency(to) {
+		errs = append(errs, FieldError{Field: "to", Message: "must be an ISO 4217 currency code"})
+	}
+	if v, ok := vars["date"]; ok {
+		if date, err = time.Parse(time.DateOnly, v); err != nil {
+			errs = append(errs, FieldError{Field: "effectiveDate", Message: "must be a date such as 2006-01-02"})
+		}
+	}
+	if len(errs) > 0 {
+		return "", "", time.Time{}, &APIError{
+			Status: http.StatusBadRequest,
+			Code:   CodeInvalidID,
+			Title:  "Bad request",
+			Detail: "Invalid exchange rate",
+			Errors: errs,
+		}
+	}
+	return from, to, date, nil
+}
+
+// exchangeRateRequest is the body of PUT /exchange-rates/{from}/{to}/{date}; the rate may
+// be sent as a JSON number or a decimal string
+type exchangeRateRequest struct {
+	Rate json.Number `json:"rate" validate:"required"`
+}
+
+func PutExchangeRateHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, date, err := exchangeRateKey(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		req := &exchangeRateRequest{}
+		if _, err := decodeAndValidate(r.Body, req, false); err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		rate, err := parseRate(req.Rate.String())
+		if err != nil {
+			writeProblem(w, r, NewValidationError([]FieldError{{Field: "rate", Message: err.Error()}}))
+			return
+		}
+
+		saved, err := PutExchangeRateAPI(r.Context(), store, &ExchangeRate{From: from, To: to, EffectiveDate: date, Rate: rate})
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, saved)
+	}
+}
+
+func ReadExchangeRatesHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Optional from and to parameters narrow the listing to some currencies
+		values := r.URL.Query()
+		f := ExchangeRateFilter{From: strings.ToUpper(values.Get("from")), To: strings.ToUpper(values.Get("to"))}
+		var errs []FieldError
+		if f.From != "" && !validCurrency(f.From) {
+			errs = append(errs, FieldError{Field: "from", Message: "must be an ISO 4217 currency code"})
+		}
+		if f.To != "" && !validCurrency(f.To) {
+			errs = append(errs, FieldError{Field: "to", Message: "must be an ISO 4217 currency code"})
+		}
+		if len(errs) > 0 {
+			writeProblem(w, r, NewInvalidQueryError(errs))
+			return
+		}
+
+		rates, err := ReadExchangeRatesAPI(r.Context(), store, f)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string][]ExchangeRate{"items": rates})
+	}
+}
+
+func ReadEffectiveExchangeRateHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, _, err := exchangeRateKey(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		// The rate in effect today unless a date is given
+		date := today()
+		if v := r.URL.Query().Get("date"); v != "" {
+			if date, err = time.Parse(time.DateOnly, v); err != nil {
+				writeProblem(w, r, NewInvalidQueryError([]FieldError{{Field: "date", Message: "must be a date such as 2006-01-02"}}))
+				return
+			}
+		}
+
+		rate, err := ReadEffectiveExchangeRateAPI(r.Context(), store, from, to, date)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, rate)
+	}
+}
+
+func DeleteExchangeRateHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, date, err := exchangeRateKey(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		if err := DeleteExchangeRateAPI(r.Context(), store, from, to, date); err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Exchange rate deleted successfully"})
+	}
+}
+
+// effectiveFromQuery reads the effectiveFrom date of an update, today when it is absent
+func effectiveFromQuery(r *http.Request) (time.Time, error) {
+	v := r.URL.Query().Get("effectiveFrom")
+	if v == "" {
+		return today(), nil
+	}
+	date, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, NewInvalidQueryError([]FieldError{{Field: "effectiveFrom", Message: "must be a date such as 2006-01-02"}})
+	}
+	return date, nil
+}
+
+func ReadEmployeeHistoryHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := employeeID(r)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		history, err := ReadEmployeeHistoryAPI(r.Context(), store, id)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, history)
+	}
+}
+
+func ReadAuditLogHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseAuditQuery(r.URL.Query())
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+
+		page, err := ReadAuditLogAPI(r.Context(), store, q)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, page)
+	}
+}
+
+func VerifyAuditLogHandler(store EmployeeStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var afterID int64
+		if v := r.URL.Query().Get("afterId"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || id < 0 {
+				writeProblem(w, r, NewInvalidQueryError([]FieldError{{Field: "afterId", Message: "must be an audit entry ID"}}))
+				return
+			}
+			afterID = id
+		}
+
+		result, err := VerifyAuditLogAPI(r.Context(), store, afterID)
+		if err != nil {
+			writeProblem(w, r, err)
 			return
 		}
+		writeJSON(w, http.StatusOK, result)
 	}
 }
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Helper function to serve a single request through a fully set up router
+func serveTestRequest(cr *CustomRouter, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	cr.ServeHTTP(rec, req)
+	return rec
+}
+
+// Helper function to build a router backed by the given store
+func initTestRouter(t *testing.T, store EmployeeStore) *CustomRouter {
+	t.Helper()
+	cr := NewCustomRouter(mux.NewRouter(), store)
+	cr.SetupRouter()
+	return cr
+}
+
+// Helper function to decode a problem+json response
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder) Problem {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("Content-Type = %q, want application/problem+json", ct)
+	}
+	var problem Problem
+	if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+		t.Fatalf("Unable to decode problem: %v", err)
+	}
+	return problem
+}
+
+func TestProblemResponses(t *testing.T) {
+	defer func(timeouts Timeouts) { apiTimeouts = timeouts }(apiTimeouts)
+	apiTimeouts.Read = 10 * time.Millisecond
+
+	store := initTestStore(t)
+	if err := InsertTableEmployee(store, []Employee{{Name: "Dan", Designation: "Software Developer", Salary: mustMoney("23456.00")}}); err != nil {
+		t.Fatalf("Unable to insert employee: %v", err)
+	}
+	cr := initTestRouter(t, store)
+	slow := initTestRouter(t, &blockingStore{MemoryEmployeeStore: store, cancelled: make(chan error, 1)})
+
+	tests := []struct {
+		name       string
+		router     *CustomRouter
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "Employee not found", router: cr, method: "GET", target: "/employees/42", wantStatus: http.StatusNotFound, wantCode: CodeNotFound},
+		{name: "Delete employee not found", router: cr, method: "DELETE", target: "/employees/42", wantStatus: http.StatusNotFound, wantCode: CodeNotFound},
+		{name: "Invalid employee ID", router: cr, method: "GET", target: "/employees/abc", wantStatus: http.StatusBadRequest, wantCode: CodeInvalidID},
+		{name: "Malformed body", router: cr, method: "POST", target: "/employees", body: "{", wantStatus: http.StatusBadRequest, wantCode: CodeMalformedRequest},
+		{name: "Null body", router: cr, method: "PUT", target: "/employees/1", body: "null", wantStatus: http.StatusBadRequest, wantCode: CodeMalformedRequest},
+		{name: "Unknown route", router: cr, method: "GET", target: "/nothing", wantStatus: http.StatusNotFound, wantCode: CodeNotFound},
+		{name: "Unsupported method", router: cr, method: "PATCH", target: "/employeeList", wantStatus: http.StatusMethodNotAllowed, wantCode: "method-not-allowed"},
+		{name: "Timeout", router: slow, method: "GET", target: "/employees/1", wantStatus: http.StatusGatewayTimeout, wantCode: CodeTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set(RequestIDHeader, "test-request")
+			rec := serveTestRequest(tt.router, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			problem := decodeProblem(t, rec)
+			if problem.Code != tt.wantCode || problem.Status != tt.wantStatus {
+				t.Errorf("problem = %+v, want code %s", problem, tt.wantCode)
+			}
+			if problem.RequestID != "test-request" || rec.Header().Get(RequestIDHeader) != "test-request" {
+				t.Errorf("request ID was not propagated: %+v", problem)
+			}
+			if strings.Contains(problem.Detail, "sql:") {
+				t.Errorf("problem leaks internals: %s", problem.Detail)
+			}
+		})
+	}
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	cr := initTestRouter(t, initTestStore(t))
+
+	rec := serveTestRequest(cr, httptest.NewRequest("GET", "/employees/1", nil))
+	problem := decodeProblem(t, rec)
+	if problem.RequestID == "" || problem.RequestID != rec.Header().Get(RequestIDHeader) {
+		t.Errorf("generated request ID %q does not match header %q", problem.RequestID, rec.Header().Get(RequestIDHeader))
+	}
+}
+
+func TestCreateEmployeeHandler(t *testing.T) {
+	cr := initTestRouter(t, initTestStore(t))
+
+	// The server allocates the ID and points to the new resource
+	body := `{"name": "John Doe", "designation": "Engineer", "salary": 50000}`
+	rec := serveTestRequest(cr, httptest.NewRequest("POST", "/employees", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /employees status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	var emp Employee
+	if err := json.NewDecoder(rec.Body).Decode(&emp); err != nil {
+		t.Fatalf("Unable to decode employee: %v", err)
+	}
+	if emp.ID != 1 || rec.Header().Get("Location") != "/employees/1" {
+		t.Errorf("POST /employees = %+v, Location %q", emp, rec.Header().Get("Location"))
+	}
+
+	// Client-chosen IDs are rejected
+	body = `{"id": 7, "name": "John Doe", "designation": "Engineer", "salary": 50000}`
+	rec = serveTestRequest(cr, httptest.NewRequest("POST", "/employees", strings.NewReader(body)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("POST /employees with id status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	if problem := decodeProblem(t, rec); len(problem.Errors) != 1 || problem.Errors[0].Field != "id" {
+		t.Errorf("problem = %+v, want an error on id", problem)
+	}
+}
+
+func TestConflictProblem(t *testing.T) {
+	err := &ConflictError{Resource: "department", Field: "name", Value: "Sales"}
+
+	req := httptest.NewRequest("POST", "/departments", nil)
+	rec := httptest.NewRecorder()
+	writeProblem(rec, req, err)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	problem := decodeProblem(t, rec)
+	if problem.Code != CodeConflict || len(problem.Errors) != 1 || problem.Errors[0].Field != "name" {
+		t.Errorf("problem = %+v, want a conflict on name", problem)
+	}
+}
+
+func TestPatchEmployeeHandler(t *testing.T) {
+	store := initTestStore(t)
+	if err := InsertTableEmployee(store, []Employee{{Name: "Dan", Designation: "Software Developer", Salary: mustMoney("23456.00")}}); err != nil {
+		t.Fatalf("Unable to insert employee: %v", err)
+	}
+	cr := initTestRouter(t, store)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		want        Employee
+	}{
+		{
+			name:        "Absent members keep their values",
+			contentType: "application/merge-patch+json",
+			body:        `{"designation": "Lead"}`,
+			wantStatus:  http.StatusOK,
+			want:        Employee{ID: 1, Name: "Dan", Designation: "Lead", Salary: mustMoney("23456.00")},
+		},
+		{
+			name:        "Zero values are written",
+			contentType: "application/merge-patch+json; charset=utf-8",
+			body:        `{"salary": 0}`,
+			wantStatus:  http.StatusOK,
+			want:        Employee{ID: 1, Name: "Dan", Designation: "Lead", Salary: mustMoney("0")},
+		},
+		{
+			name:        "Null on a required field",
+			contentType: "application/merge-patch+json",
+			body:        `{"name": null}`,
+			wantStatus:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "Unsupported content type",
+			contentType: "text/plain",
+			body:        `{"salary": 1}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PATCH", "/employees/1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := serveTestRequest(cr, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("PATCH /employees/1 status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got Employee
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("Unable to decode employee: %v", err)
+			}
+			tt.want.CreatedAt, tt.want.UpdatedAt = got.CreatedAt, got.UpdatedAt
+			if got != tt.want {
+				t.Errorf("PATCH /employees/1 = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateEmployeeHandlerReplaces(t *testing.T) {
+	store := initTestStore(t)
+	if err := InsertTableEmployee(store, []Employee{{Name: "Dan", Designation: "Software Developer", Salary: mustMoney("23456.00")}}); err != nil {
+		t.Fatalf("Unable to insert employee: %v", err)
+	}
+	cr := initTestRouter(t, store)
+
+	// PUT is a full replacement, so leaving out required fields is an error
+	rec := serveTestRequest(cr, httptest.NewRequest("PUT", "/employees/1", strings.NewReader(`{"salary": 0}`)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("PUT /employees/1 status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	// A complete document may set the salary to zero
+	body := `{"name": "Dan", "designation": "Lead", "salary": 0}`
+	rec = serveTestRequest(cr, httptest.NewRequest("PUT", "/employees/1", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /employees/1 status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if emp, _ := store.ReadEmployee(context.Background(), 1); emp.Designation != "Lead" || emp.Salary != (Money{}) {
+		t.Errorf("stored employee = %+v", emp)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// readinessCheckTimeout bounds each individual readiness check
+const readinessCheckTimeout = 2 * time.Second
+
+// CheckResult is the outcome of a single health check
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthResponse is the body returned by /healthz and /readyz
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// runCheck times a check and records its result
+func runCheck(ctx context.Context, check func(ctx context.Context) error) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{
+		Status:    "ok",
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func writeHealth(w http.ResponseWriter, resp HealthResponse) {
+	status := http.StatusOK
+	if resp.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HealthzHandler reports that the process is alive; it never touches the database
+func HealthzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, HealthResponse{Status: "ok"})
+	}
+}
+
+// ReadyzHandler reports whether the service can take traffic: the store answers,
+// every migration is applied and the server is not shutting down. A nil migrator
+// skips the migration check.
+func ReadyzHandler(store EmployeeStore, migrator *Migrator, shuttingDown *atomic.Bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		checks := map[string]func(ctx context.Context) error{
+			"shutdown": func(ctx context.Context) error {
+				if shuttingDown.Load() {
+					return errors.New("server is shutting down")
+				}
+				return nil
+			},
+			"store": store.Ping,
+		}
+		if migrator != nil {
+			checks["migrations"] = func(ctx context.Context) error {
+				pending, err := migrator.Pending(ctx)
+				if err != nil {
+					return err
+				}
+				if len(pending) > 0 {
+					return fmt.Errorf("%d pending migrations", len(pending))
+				}
+				return nil
+			}
+		}
+
+		resp := HealthResponse{Status: "ok", Checks: make(map[string]CheckResult, len(checks))}
+		for name, check := range checks {
+			result := runCheck(r.Context(), check)
+			if result.Status != "ok" {
+				resp.Status = "fail"
+			}
+			resp.Checks[name] = result
+		}
+
+		writeHealth(w, resp)
+	}
+}
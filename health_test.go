@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestHealthz(t *testing.T) {
+	cr := NewCustomRouter(mux.NewRouter(), initTestStore(t))
+	cr.SetupRouter()
+
+	rec := serveTestRequest(cr, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /healthz status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	db := initTestSQLiteDB(t)
+	migrator, err := NewMigrator(db, SQLiteDialect)
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+
+	cr := NewCustomRouter(mux.NewRouter(), NewSQLiteEmployeeStore(db))
+	cr.Migrator = migrator
+	cr.SetupRouter()
+
+	readyz := func() (int, HealthResponse) {
+		rec := serveTestRequest(cr, httptest.NewRequest("GET", "/readyz", nil))
+		var resp HealthResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Unable to decode /readyz response: %v", err)
+		}
+		return rec.Code, resp
+	}
+
+	// Not ready until the schema is migrated
+	code, resp := readyz()
+	if code != http.StatusServiceUnavailable || resp.Checks["migrations"].Status != "fail" || resp.Checks["store"].Status != "ok" {
+		t.Errorf("GET /readyz before migrating = %d %+v", code, resp)
+	}
+
+	if _, err := migrator.Up(context.Background(), 0); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	code, resp = readyz()
+	if code != http.StatusOK || resp.Status != "ok" || len(resp.Checks) != 3 {
+		t.Errorf("GET /readyz after migrating = %d %+v", code, resp)
+	}
+
+	// Not ready once shutdown has begun
+	cr.ShuttingDown.Store(true)
+	code, resp = readyz()
+	if code != http.StatusServiceUnavailable || resp.Checks["shutdown"].Status != "fail" {
+		t.Errorf("GET /readyz while shutting down = %d %+v", code, resp)
+	}
+
+	// Not ready once the database is gone
+	cr.ShuttingDown.Store(false)
+	db.Close()
+	code, resp = readyz()
+	if code != http.StatusServiceUnavailable || resp.Checks["store"].Status != "fail" {
+		t.Errorf("GET /readyz with a closed database = %d %+v", code, resp)
+	}
+}
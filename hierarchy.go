@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// OrgEntry is an employee found by walking reporting lines, Depth levels away from where
+// the walk started
+type OrgEntry struct {
+	Employee
+	Depth int `json:"depth"`
+}
+
+// OrgEntryList is the result of a walk along reporting lines
+type OrgEntryList struct {
+	Items    []OrgEntry `json:"items"`
+	MaxDepth int        `json:"maxDepth"`
+	// Truncated is set when the walk stopped at MaxDepth with employees left beyond it
+	Truncated bool `json:"truncated"`
+}
+
+// ErrUnknownManager is returned when an employee is given a manager that does not exist
+var ErrUnknownManager = errors.New("manager does not exist")
+
+// ErrManagerCycle is returned when an employee is given itself, or one of its reports, as manager
+var ErrManagerCycle = errors.New("manager would create a reporting cycle")
+
+// ErrEmployeeHasReports is returned when deleting an employee that others still report to
+var ErrEmployeeHasReports = errors.New("employee still has reports")
+
+var ErrTimeoutHierarchy = errors.New("timeout occurred while reading reporting lines")
+
+// orgLimits bounds reporting line walks; main replaces it with the configured values
+var orgLimits = DefaultOrgConfig()
+
+// parseMaxDepth reads the maxDepth parameter v of a reporting line walk, defaulting to the
+// configured limit
+func parseMaxDepth(v string) (int, *FieldError) {
+	if v == "" {
+		return orgLimits.MaxDepth, nil
+	}
+	depth, err := strconv.Atoi(v)
+	if err != nil || depth < 1 || depth > orgLimits.MaxDepth {
+		return 0, &FieldError{Field: "maxDepth", Message: fmt.Sprintf("must be a number from 1 to %d", orgLimits.MaxDepth)}
+	}
+	return depth, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHierarchyHandlers(t *testing.T) {
+	stores := map[string]func(t *testing.T) EmployeeStore{
+		"memory": func(t *testing.T) EmployeeStore { return initTestStore(t) },
+		"sqlite": func(t *testing.T) EmployeeStore { return initTestSQLiteStore(t) },
+	}
+	for storeName, newStore := range stores {
+		t.Run(storeName, func(t *testing.T) {
+			cr := initTestRouter(t, newStore(t))
+			do := func(method, path, body string, wantStatus int) *httptest.ResponseRecorder {
+				t.Helper()
+				req := httptest.NewRequest(method, path, strings.NewReader(body))
+				if method == "PATCH" {
+					req.Header.Set("Content-Type", mediaTypeMergePatch)
+				}
+				rec := serveTestRequest(cr, req)
+				if rec.Code != wantStatus {
+					t.Fatalf("%s %s status = %d, want %d: %s", method, path, rec.Code, wantStatus, rec.Body)
+				}
+				return rec
+			}
+			names := func(rec *httptest.ResponseRecorder) (string, OrgEntryList) {
+				t.Helper()
+				var list OrgEntryList
+				if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+					t.Fatal(err)
+				}
+				var parts []string
+				for _, entry := range list.Items {
+					parts = append(parts, fmt.Sprintf("%s:%d", entry.Name, entry.Depth))
+				}
+				return strings.Join(parts, " "), list
+			}
+
+			// 1 Cat (CEO) <- 2 Dan <- 4 Eve <- 5 Fay; 1 Cat <- 3 Ben
+			do("POST", "/employees", `{"name": "Cat", "designation": "CEO", "salary": 100}`, http.StatusCreated)
+			do("POST", "/employees", `{"name": "Dan", "designation": "CTO", "salary": 100, "managerId": 1}`, http.StatusCreated)
+			do("POST", "/employees", `{"name": "Ben", "designation": "CFO", "salary": 100, "managerId": 1}`, http.StatusCreated)
+			do("POST", "/employees", `{"name": "Eve", "designation": "Lead", "salary": 100, "managerId": 2}`, http.StatusCreated)
+			do("POST", "/employees", `{"name": "Fay", "designation": "Developer", "salary": 100}`, http.StatusCreated)
+			do("PATCH", "/employees/5", `{"managerId": 4}`, http.StatusOK)
+
+			problem := decodeProblem(t, do("POST", "/employees", `{"name": "Gus", "designation": "Lead", "salary": 100, "managerId": 9}`, http.StatusUnprocessableEntity))
+			if len(problem.Errors) != 1 || problem.Errors[0].Field != "managerId" {
+				t.Errorf("unknown manager problem = %+v", problem)
+			}
+			do("PATCH", "/employees/3", `{"managerId": 9}`, http.StatusUnprocessableEntity)
+
+			// Neither the employee itself nor anyone below it can become its manager
+			for _, managerID := range []string{"1", "5"} {
+				problem := decodeProblem(t, do("PATCH", "/employees/1", `{"managerId": `+managerID+`}`, http.StatusUnprocessableEntity))
+				if len(problem.Errors) != 1 || problem.Errors[0].Field != "managerId" {
+					t.Errorf("cycle through %s problem = %+v", managerID, problem)
+				}
+			}
+			do("PUT", "/employees/2", `{"name": "Dan", "designation": "CTO", "salary": 100, "managerId": 4}`, http.StatusUnprocessableEntity)
+
+			tests := []struct {
+				path          string
+				want          string
+				wantTruncated bool
+			}{
+				{"/employees/1/reports", "Dan:1 Ben:1", true},
+				{"/employees/5/reports", "", false},
+				{"/employees/1/subordinates", "Dan:1 Ben:1 Eve:2 Fay:3", false},
+				{"/employees/1/subordinates?maxDepth=2", "Dan:1 Ben:1 Eve:2", true},
+				{"/employees/2/subordinates", "Eve:1 Fay:2", false},
+				{"/employees/5/managers", "Eve:1 Dan:2 Cat:3", false},
+				{"/employees/5/managers?maxDepth=1", "Eve:1", true},
+				{"/employees/1/managers", "", false},
+			}
+			for _, tt := range tests {
+				got, list := names(do("GET", tt.path, "", http.StatusOK))
+				if got != tt.want || list.Truncated != tt.wantTruncated {
+					t.Errorf("GET %s = %q truncated %v, want %q truncated %v", tt.path, got, list.Truncated, tt.want, tt.wantTruncated)
+				}
+			}
+			do("GET", "/employees/9/subordinates", "", http.StatusNotFound)
+			do("GET", "/employees/9/managers", "", http.StatusNotFound)
+			do("GET", "/employees/1/subordinates?maxDepth=0", "", http.StatusBadRequest)
+			do("GET", "/employees/1/managers?maxDepth=1000", "", http.StatusBadRequest)
+
+			// Filter by manager
+			var page EmployeePage
+			if err := json.NewDecoder(do("GET", "/employees?manager=1", "", http.StatusOK).Body).Decode(&page); err != nil ||
+				len(page.Items) != 2 || page.Items[0].Name != "Dan" || page.Items[1].Name != "Ben" {
+				t.Errorf("GET /employees?manager=1 = %+v, %v", page.Items, err)
+			}
+
+			// Moving a subtree elsewhere is fine; deleting a manager is not until the reports move
+			do("PATCH", "/employees/4", `{"managerId": 3}`, http.StatusOK)
+			if got, _ := names(do("GET", "/employees/5/managers", "", http.StatusOK)); got != "Eve:1 Ben:2 Cat:3" {
+				t.Errorf("chain after move = %q", got)
+			}
+			do("DELETE", "/employees/3", "", http.StatusConflict)
+			do("PATCH", "/employees/4", `{"managerId": null}`, http.StatusOK)
+			do("DELETE", "/employees/3", "", http.StatusOK)
+		})
+	}
+}
+
+func TestSQLiteManagerCheckOnUnknownEmployee(t *testing.T) {
+	store := initTestSQLiteStore(t)
+	ctx := context.Background()
+	boss := &Employee{Name: "Cat", Designation: "CEO", Currency: "USD"}
+	if err := store.CreateEmployee(ctx, boss); err != nil {
+		t.Fatal(err)
+	}
+
+	// The failed check must leave the single connection usable
+	_, err := store.UpdateEmployee(ctx, 7, &Employee{ManagerID: &boss.ID}, []string{"managerId"}, 0, time.Time{})
+	if !errors.Is(err, ErrEmployeeNotFound) {
+		t.Fatalf("UpdateEmployee of an unknown employee = %v, want %v", err, ErrEmployeeNotFound)
+	}
+	if _, err := store.ReadEmployee(ctx, boss.ID); err != nil {
+		t.Fatalf("ReadEmployee after the failed update: %v", err)
+	}
+}
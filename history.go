@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+)
+
+// HistoryEntry records a change of designation, salary or both taking effect on
+// EffectiveFrom. Entries are only ever added; an employee's first entry holds everything.
+type HistoryEntry struct {
+	ID            int
+	EmployeeID    int
+	EffectiveFrom time.Time
+	// Designation is nil when the entry leaves the designation as it was
+	Designation *string
+	// Salary is nil when the entry leaves the salary as it was; Currency is set with it
+	Salary     *Money
+	Currency   string
+	RecordedAt time.Time
+}
+
+// Position is the part of an employee the history tracks
+type Position struct {
+	Designation string
+	Salary      Money
+	Currency    string
+}
+
+// positionFields lists the employeeFields that make up a Position
+var positionFields = []string{"designation", "salary", "currency"}
+
+func positionOf(emp *Employee) Position {
+	return Position{Designation: emp.Designation, Salary: emp.Salary, Currency: emp.Currency}
+}
+
+func (p Position) applyTo(emp *Employee) {
+	emp.Designation, emp.Salary, emp.Currency = p.Designation, p.Salary, p.Currency
+}
+
+// tracksPosition reports whether an update of fields may change the position
+func tracksPosition(fields []string) bool {
+	return slices.ContainsFunc(fields, func(name string) bool { return slices.Contains(positionFields, name) })
+}
+
+// compareHistoryEntries orders entries by effective date; of two taking effect on the same
+// day the one recorded later wins
+func compareHistoryEntries(a, b HistoryEntry) int {
+	if c := a.EffectiveFrom.Compare(b.EffectiveFrom); c != 0 {
+		return c
+	}
+	return a.ID - b.ID
+}
+
+// positionAt returns the position in effect on date, from entries in compareHistoryEntries
+// order. ok is false when no entry has taken effect by then.
+func positionAt(entries []HistoryEntry, date time.Time) (p Position, ok bool) {
+	for _, entry := range entries {
+		if entry.EffectiveFrom.After(date) {
+			break
+		}
+		if entry.Designation != nil {
+			p.Designation = *entry.Designation
+		}
+		if entry.Salary != nil {
+			p.Salary, p.Currency = *entry.Salary, entry.Currency
+		}
+		ok = true
+	}
+	return p, ok
+}
+
+// newHistoryEntry returns the entry recording a change to target taking effect on effective,
+// holding only what differs from the position in effect then, or nil when nothing does. An
+// entry dated before all others becomes the first one and holds the whole position.
+func newHistoryEntry(entries []HistoryEntry, target Position, effective time.Time) *HistoryEntry {
+	before, ok := positionAt(entries, effective)
+	entry := &HistoryEntry{EffectiveFrom: effective}
+	if !ok || target.Designation != before.Designation {
+		entry.Designation = &target.Designation
+	}
+	if !ok || target.Salary.Cmp(before.Salary) != 0 || target.Currency != before.Currency {
+		entry.Salary, entry.Currency = &target.Salary, target.Currency
+	}
+	if entry.Designation == nil && entry.Salary == nil {
+		return nil
+	}
+	return entry
+}
+
+// recordPosition works out what an update writing fields of emp, effective on effective,
+// does to the history in entries (sorted): the entry to add, if any, and the position in
+// effect today once it has been added. Fields other than the position apply at once.
+func recordPosition(entries []HistoryEntry, emp *Employee, fields []string, effective time.Time) (*HistoryEntry, Position) {
+	// The fields the update leaves out keep the values they have on the effective date, or
+	// the ones the employee started with when the update is dated before that
+	var target Employee
+	before, ok := positionAt(entries, effective)
+	if !ok && len(entries) > 0 {
+		before, _ = positionAt(entries, entries[0].EffectiveFrom)
+	}
+	before.applyTo(&target)
+	for _, name := range fields {
+		if slices.Contains(positionFields, name) {
+			employeeFields[name].Copy(&target, emp)
+		}
+	}
+
+	entry := newHistoryEntry(entries, positionOf(&target), effective)
+	if entry != nil {
+		// Sorting by date alone keeps the new entry after those recorded earlier on its day
+		entries = append(slices.Clone(entries), *entry)
+		slices.SortStableFunc(entries, func(a, b HistoryEntry) int { return a.EffectiveFrom.Compare(b.EffectiveFrom) })
+	}
+	current, _ := positionAt(entries, today())
+	return entry, current
+}
+
+// initialHistoryEntry is the first entry of an employee created at now: its whole position,
+// in effect from that day
+func initialHistoryEntry(emp *Employee, now time.Time) *HistoryEntry {
+	designation, salary := emp.Designation, emp.Salary
+	return &HistoryEntry{
+		EmployeeID:    emp.ID,
+		EffectiveFrom: now.Truncate(24 * time.Hour),
+		Designation:   &designation,
+		Salary:        &salary,
+		Currency:      emp.Currency,
+		RecordedAt:    now,
+	}
+}
+
+// EmployeeSnapshot is the whole employee as a write left it at RecordedAt. Deleted marks the
+// snapshot taken as the employee was deleted.
+type EmployeeSnapshot struct {
+	Employee
+	Deleted    bool
+	RecordedAt time.Time
+}
+
+// employeeAsOf rebuilds the employee as it was on date: the position in effect then from its
+// history entries, and the rest from the last of its snapshots (in ID order) recorded by the
+// end of that day. A history backdated to before the employee was created falls back on the
+// first snapshot.
+func employeeAsOf(snapshots []EmployeeSnapshot, entries []HistoryEntry, date time.Time) (Employee, error) {
+	if len(snapshots) == 0 {
+		return Employee{}, ErrEmployeeNotFound
+	}
+	position, ok := positionAt(entries, date)
+	if !ok {
+		return Employee{}, ErrEmployeeNotStarted
+	}
+
+	snapshot := snapshots[0]
+	end := date.AddDate(0, 0, 1)
+	for _, candidate := range snapshots {
+		if candidate.RecordedAt.Before(end) {
+			snapshot = candidate
+		}
+	}
+	if snapshot.Deleted {
+		return Employee{}, ErrEmployeeDeleted
+	}
+	emp := snapshot.Employee
+	position.applyTo(&emp)
+	return emp, nil
+}
+
+// parseAsOf reads an asOf parameter: a date, or an RFC 3339 timestamp standing for the UTC
+// day it falls on, since changes take effect a day at a time. It is zero when v is empty.
+func parseAsOf(v string) (time.Time, *FieldError) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.Parse(time.DateOnly, v); err == nil {
+		return date, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, &FieldError{Field: "asOf", Message: "must be a date such as 2006-01-02 or an RFC 3339 timestamp"}
+	}
+	return t.UTC().Truncate(24 * time.Hour), nil
+}
+
+// HistoryItem is one step of an employee's timeline: the position from EffectiveFrom on
+// and which parts of it changed then
+type HistoryItem struct {
+	EffectiveFrom string    `json:"effectiveFrom"`
+	Designation   string    `json:"designation"`
+	Salary        Money     `json:"salary"`
+	Currency      string    `json:"currency"`
+	Changed       []string  `json:"changed"`
+	RecordedAt    time.Time `json:"recordedAt"`
+	// Scheduled marks changes that have not taken effect yet
+	Scheduled bool `json:"scheduled,omitempty"`
+}
+
+// EmployeeHistory is the timeline of an employee's positions, oldest first
+type EmployeeHistory struct {
+	Items []HistoryItem `json:"items"`
+}
+
+var ErrTimeoutHistory = errors.New("timeout occurred while reading employee history")
+
+// ErrEmployeeNotStarted is returned when reading an employee as of a date before its history begins
+var ErrEmployeeNotStarted = errors.New("employee has no history by that date")
+
+// ErrEmployeeDeleted is returned when reading an employee as of a date it had been deleted by
+var ErrEmployeeDeleted = errors.New("employee had been deleted by that date")
+
+// scheduledChangeInterval is how often runScheduledChanges looks for changes coming due
+const scheduledChangeInterval = time.Hour
+
+// runScheduledChanges copies future-dated changes into the employees they belong to as they
+// take effect, until ctx is done. The first pass catches up on everything already due.
+func runScheduledChanges(ctx context.Context, store EmployeeStore, interval time.Duration) {
+	var since time.Time
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		until := today()
+		n, err := store.ApplyScheduledChanges(ctx, since, until)
+		if err != nil && ctx.Err() == nil {
+			log.Println("Error applying scheduled changes:", err)
+		} else if err == nil {
+			since = until
+		}
+		if n > 0 {
+			log.Printf("Applied scheduled changes to %d employees", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordPosition(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	designation := func(s string) *string { return &s }
+	salary := func(s string) *Money { m := mustMoney(s); return &m }
+	now := today()
+	past, future := now.AddDate(0, -6, 0), now.AddDate(0, 1, 0)
+	hired := []HistoryEntry{{ID: 1, EffectiveFrom: day("2020-01-01"), Designation: designation("Developer"), Salary: salary("100"), Currency: "USD"}}
+
+	tests := []struct {
+		name         string
+		entries      []HistoryEntry
+		emp          Employee
+		fields       []string
+		effective    time.Time
+		wantEntry    string
+		wantPosition Position
+	}{
+		{
+			name:         "A raise today changes the salary only",
+			entries:      hired,
+			emp:          Employee{Designation: "Developer", Salary: mustMoney("120"), Currency: "USD"},
+			fields:       replaceEmployeeFields,
+			effective:    now,
+			wantEntry:    "salary=120.00 USD",
+			wantPosition: Position{"Developer", mustMoney("120"), "USD"},
+		},
+		{
+			name:         "Nothing changes",
+			entries:      hired,
+			emp:          Employee{Designation: "Developer", Salary: mustMoney("100"), Currency: "USD"},
+			fields:       replaceEmployeeFields,
+			effective:    now,
+			wantEntry:    "none",
+			wantPosition: Position{"Developer", mustMoney("100"), "USD"},
+		},
+		{
+			name:         "A scheduled promotion is recorded but not in effect",
+			entries:      hired,
+			emp:          Employee{Designation: "Lead"},
+			fields:       []string{"designation"},
+			effective:    future,
+			wantEntry:    "designation=Lead",
+			wantPosition: Position{"Developer", mustMoney("100"), "USD"},
+		},
+		{
+			name:         "A currency change keeps the amount",
+			entries:      hired,
+			emp:          Employee{Currency: "EUR"},
+			fields:       []string{"currency"},
+			effective:    now,
+			wantEntry:    "salary=100.00 EUR",
+			wantPosition: Position{"Developer", mustMoney("100"), "EUR"},
+		},
+		{
+			name:         "A change dated before the hire holds the whole position",
+			entries:      hired,
+			emp:          Employee{Salary: mustMoney("90")},
+			fields:       []string{"salary"},
+			effective:    day("2019-01-01"),
+			wantEntry:    "designation=Developer salary=90.00 USD",
+			wantPosition: Position{"Developer", mustMoney("100"), "USD"},
+		},
+		{
+			name: "A backdated change is overridden by a later one already in effect",
+			entries: append(hired[:1:1], HistoryEntry{
+				ID: 2, EffectiveFrom: now.AddDate(0, -1, 0), Salary: salary("150"), Currency: "USD",
+			}),
+			emp:          Employee{Salary: mustMoney("130")},
+			fields:       []string{"salary"},
+			effective:    past,
+			wantEntry:    "salary=130.00 USD",
+			wantPosition: Position{"Developer", mustMoney("150"), "USD"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, position := recordPosition(tt.entries, &tt.emp, tt.fields, tt.effective)
+			got := "none"
+			if entry != nil {
+				var parts []string
+				if entry.Designation != nil {
+					parts = append(parts, "designation="+*entry.Designation)
+				}
+				if entry.Salary != nil {
+					parts = append(parts, fmt.Sprintf("salary=%s %s", entry.Salary, entry.Currency))
+				}
+				got = strings.Join(parts, " ")
+				if !entry.EffectiveFrom.Equal(tt.effective) {
+					t.Errorf("entry effective from %v, want %v", entry.EffectiveFrom, tt.effective)
+				}
+			}
+			if got != tt.wantEntry {
+				t.Errorf("entry = %s, want %s", got, tt.wantEntry)
+			}
+			if position != tt.wantPosition {
+				t.Errorf("position = %+v, want %+v", position, tt.wantPosition)
+			}
+		})
+	}
+}
+
+func TestEmployeeHistoryHandlers(t *testing.T) {
+	stores := map[string]func(t *testing.T) EmployeeStore{
+		"memory": func(t *testing.T) EmployeeStore { return initTestStore(t) },
+		"sqlite": func(t *testing.T) EmployeeStore { return initTestSQLiteStore(t) },
+	}
+	for storeName, newStore := range stores {
+		t.Run(storeName, func(t *testing.T) {
+			store := newStore(t)
+			cr := initTestRouter(t, store)
+			do := func(method, path, body string, wantStatus int) *httptest.ResponseRecorder {
+				t.Helper()
+				req := httptest.NewRequest(method, path, strings.NewReader(body))
+				if method == "PATCH" {
+					req.Header.Set("Content-Type", mediaTypeMergePatch)
+				}
+				rec := serveTestRequest(cr, req)
+				if rec.Code != wantStatus {
+					t.Fatalf("%s %s status = %d, want %d: %s", method, path, rec.Code, wantStatus, rec.Body)
+				}
+				return rec
+			}
+			readEmployee := func() (Employee, string) {
+				t.Helper()
+				var emp Employee
+				rec := do("GET", "/employees/1", "", http.StatusOK)
+				if err := json.NewDecoder(rec.Body).Decode(&emp); err != nil {
+					t.Fatal(err)
+				}
+				return emp, rec.Header().Get("ETag")
+			}
+			timeline := func() string {
+				t.Helper()
+				var history EmployeeHistory
+				if err := json.NewDecoder(do("GET", "/employees/1/history", "", http.StatusOK).Body).Decode(&history); err != nil {
+					t.Fatal(err)
+				}
+				var steps []string
+				for _, item := range history.Items {
+					step := fmt.Sprintf("%s %s %s %s [%s]", item.EffectiveFrom, item.Designation, item.Salary, item.Currency, strings.Join(item.Changed, ","))
+					if item.Scheduled {
+						step += " scheduled"
+					}
+					steps = append(steps, step)
+				}
+				return strings.Join(steps, "\n")
+			}
+			now := today()
+			nextMonth := now.AddDate(0, 1, 0).Format(time.DateOnly)
+			lastYear := now.AddDate(-1, 0, 0).Format(time.DateOnly)
+
+			do("POST", "/employees", `{"name": "Dan", "designation": "Developer", "salary": 100}`, http.StatusCreated)
+			// Renaming leaves the history alone; a raise on PUT takes effect today
+			do("PATCH", "/employees/1", `{"name": "Daniel"}`, http.StatusOK)
+			do("PUT", "/employees/1", `{"name": "Daniel", "designation": "Developer", "salary": 110}`, http.StatusOK)
+			// A promotion next month is recorded but the employee keeps the current title
+			rec := do("PATCH", "/employees/1?effectiveFrom="+nextMonth, `{"designation": "Lead", "salary": 150}`, http.StatusOK)
+			var emp Employee
+			if err := json.NewDecoder(rec.Body).Decode(&emp); err != nil || emp.Designation != "Developer" || emp.Salary != mustMoney("110") {
+				t.Errorf("employee after a scheduled promotion = %+v, %v", emp, err)
+			}
+			// A correction dated before the hire only changes the past
+			do("PATCH", "/employees/1?effectiveFrom="+lastYear, `{"salary": 90}`, http.StatusOK)
+
+			today := now.Format(time.DateOnly)
+			want := strings.Join([]string{
+				lastYear + " Developer 90.00 USD [designation,salary,currency]",
+				today + " Developer 100.00 USD [designation,salary,currency]",
+				today + " Developer 110.00 USD [salary,currency]",
+				nextMonth + " Lead 150.00 USD [designation,salary,currency] scheduled",
+			}, "\n")
+			if got := timeline(); got != want {
+				t.Errorf("timeline =\n%s\nwant\n%s", got, want)
+			}
+			if emp, _ := readEmployee(); emp.Salary != mustMoney("110") {
+				t.Errorf("salary after a backdated correction = %s, want 110.00", emp.Salary)
+			}
+
+			// The scheduled change reaches the employee once it is due, and only once
+			for i, wantApplied := range []int{1, 0} {
+				applied, err := store.ApplyScheduledChanges(context.Background(), now, now.AddDate(0, 1, 0))
+				if err != nil || applied != wantApplied {
+					t.Errorf("ApplyScheduledChanges() pass %d = %d, %v, want %d", i, applied, err, wantApplied)
+				}
+			}
+			if emp, etag := readEmployee(); emp.Designation != "Lead" || emp.Salary != mustMoney("150") || etag != `"6"` {
+				t.Errorf("employee after the scheduled change = %+v, ETag %s", emp, etag)
+			}
+
+			// The history outlives the employee
+			want = timeline()
+			do("DELETE", "/employees/1", "", http.StatusOK)
+			do("GET", "/employees/1", "", http.StatusNotFound)
+			if got := timeline(); got != want {
+				t.Errorf("timeline after the delete =\n%s\nwant\n%s", got, want)
+			}
+			if applied, err := store.ApplyScheduledChanges(context.Background(), time.Time{}, now.AddDate(1, 0, 0)); err != nil || applied != 0 {
+				t.Errorf("ApplyScheduledChanges() after the delete = %d, %v, want 0", applied, err)
+			}
+
+			do("GET", "/employees/9/history", "", http.StatusNotFound)
+			do("PATCH", "/employees/1?effectiveFrom=soon", `{"salary": 1}`, http.StatusBadRequest)
+		})
+	}
+}
+
+func TestSQLiteHistoryMigrationBackfills(t *testing.T) {
+	db := initTestSQLiteDB(t)
+	migrator, err := NewMigrator(db, SQLiteDialect)
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 8); err != nil {
+		t.Fatalf("Up(8) error = %v", err)
+	}
+
+	created := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	if _, err := db.Exec("INSERT INTO employee (Name, Designation, Salary, Currency, CreatedAt) VALUES ('Sen', 'Lead', 100, 'EUR', ?)", created); err != nil {
+		t.Fatalf("Unable to insert employee: %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 9); err != nil {
+		t.Fatalf("Up(9) error = %v", err)
+	}
+
+	entries, err := NewSQLiteEmployeeStore(db).ReadHistory(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("ReadHistory() error = %v", err)
+	}
+	want := Position{"Lead", mustMoney("100"), "EUR"}
+	if position, ok := positionAt(entries, created); len(entries) != 1 || !ok || position != want ||
+		!entries[0].EffectiveFrom.Equal(time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("history = %+v, want one entry of %+v from 2024-05-06", entries, want)
+	}
+}
+
+func TestSQLiteDueEmployees(t *testing.T) {
+	ctx := context.Background()
+	store := initTestSQLiteStore(t)
+	for _, name := range []string{"Sen", "Dan", "Ann"} {
+		if err := store.CreateEmployee(ctx, &Employee{Name: name, Designation: "Developer", Salary: mustMoney("100.50"), Currency: "USD"}); err != nil {
+			t.Fatalf("CreateEmployee() error = %v", err)
+		}
+	}
+	now := today()
+	nextMonth := now.AddDate(0, 1, 0)
+	raise := &Employee{Salary: mustMoney("120"), Currency: "USD"}
+	if _, err := store.UpdateEmployee(ctx, 2, raise, []string{"salary", "currency"}, 0, nextMonth); err != nil {
+		t.Fatalf("UpdateEmployee() error = %v", err)
+	}
+
+	// Every employee has an entry due since the zero time, but only Dan's differs from him
+	tests := []struct {
+		name  string
+		until time.Time
+		want  []int
+	}{
+		{"today", now, nil},
+		{"next month", nextMonth, []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := store.dueEmployees(ctx, time.Time{}, tt.until)
+			if err != nil || !slices.Equal(ids, tt.want) {
+				t.Errorf("dueEmployees() = %v, %v, want %v", ids, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmployeeAsOf(t *testing.T) {
+	stores := map[string]func(t *testing.T) EmployeeStore{
+		"memory": func(t *testing.T) EmployeeStore { return initTestStore(t) },
+		"sqlite": func(t *testing.T) EmployeeStore { return initTestSQLiteStore(t) },
+	}
+	for storeName, newStore := range stores {
+		t.Run(storeName, func(t *testing.T) {
+			store := newStore(t)
+			cr := initTestRouter(t, store)
+			do := func(method, path, body string, wantStatus int) *httptest.ResponseRecorder {
+				t.Helper()
+				req := httptest.NewRequest(method, path, strings.NewReader(body))
+				req.Header.Set("Content-Type", mediaTypeMergePatch)
+				rec := serveTestRequest(cr, req)
+				if rec.Code != wantStatus {
+					t.Fatalf("%s %s status = %d, want %d: %s", method, path, rec.Code, wantStatus, rec.Body)
+				}
+				return rec
+			}
+
+			// Dan joined as a developer in 2025 and got a raise in June; Ann joined today
+			do("POST", "/employees", `{"name": "Dan", "designation": "Senior Developer", "salary": 120}`, http.StatusCreated)
+			do("POST", "/employees", `{"name": "Ann", "designation": "Lead", "salary": 200}`, http.StatusCreated)
+			do("PATCH", "/employees/1?effectiveFrom=2025-01-01", `{"designation": "Developer", "salary": 90}`, http.StatusOK)
+			do("PATCH", "/employees/1?effectiveFrom=2025-06-01", `{"salary": 100}`, http.StatusOK)
+
+			reads := []struct {
+				asOf            string
+				wantDesignation string
+				wantSalary      string
+			}{
+				{"2025-03-31", "Developer", "90.00"},
+				{"2025-06-01", "Developer", "100.00"},
+				{"2025-06-01T08:00:00%2B02:00", "Developer", "100.00"},
+				{today().Format(time.DateOnly), "Senior Developer", "120.00"},
+			}
+			for _, tt := range reads {
+				rec := do("GET", "/employees/1?asOf="+tt.asOf, "", http.StatusOK)
+				var emp Employee
+				if err := json.NewDecoder(rec.Body).Decode(&emp); err != nil {
+					t.Fatal(err)
+				}
+				if emp.Name != "Dan" || emp.Designation != tt.wantDesignation || emp.Salary != mustMoney(tt.wantSalary) {
+					t.Errorf("employee as of %s = %+v, want %s earning %s", tt.asOf, emp, tt.wantDesignation, tt.wantSalary)
+				}
+				if etag := rec.Header().Get("ETag"); etag != "" {
+					t.Errorf("employee as of %s has ETag %s", tt.asOf, etag)
+				}
+			}
+			do("GET", "/employees/2?asOf=2025-03-31", "", http.StatusNotFound)
+			do("GET", "/employees/1?asOf=yesterday", "", http.StatusBadRequest)
+
+			// Listings filter and sort on the past values and leave out employees not there yet
+			lists := []struct {
+				query string
+				want  string
+			}{
+				{"asOf=2025-07-01&total=true", "Dan Developer 100.00 of 1"},
+				{"asOf=2025-07-01&maxSalary=95&total=true", " of 0"},
+				{"asOf=2025-03-31&designation=developer&total=true", "Dan Developer 90.00 of 1"},
+				{"designation=developer&total=true", " of 0"},
+				{"sort=-salary&total=true", "Ann Lead 200.00,Dan Senior Developer 120.00 of 2"},
+			}
+			for _, tt := range lists {
+				var page EmployeePage
+				if err := json.NewDecoder(do("GET", "/employees?"+tt.query, "", http.StatusOK).Body).Decode(&page); err != nil {
+					t.Fatal(err)
+				}
+				var items []string
+				for _, emp := range page.Items {
+					items = append(items, fmt.Sprintf("%s %s %s", emp.Name, emp.Designation, emp.Salary))
+				}
+				if got := fmt.Sprintf("%s of %d", strings.Join(items, ","), *page.Total); got != tt.want {
+					t.Errorf("GET /employees?%s = %s, want %s", tt.query, got, tt.want)
+				}
+			}
+
+			var summary SalarySummary
+			if err := json.NewDecoder(do("GET", "/employees/salary-summary?asOf=2025-03-31", "", http.StatusOK).Body).Decode(&summary); err != nil {
+				t.Fatal(err)
+			}
+			if summary.Count != 1 || summary.Currencies[0].Total != mustMoney("90.00") {
+				t.Errorf("salary summary as of 2025-03-31 = %+v", summary)
+			}
+
+			// Renaming and deleting Dan changes neither his past name nor whether he was there
+			do("PATCH", "/employees/1", `{"name": "Daniel"}`, http.StatusOK)
+			do("DELETE", "/employees/1", "", http.StatusOK)
+			var emp Employee
+			if err := json.NewDecoder(do("GET", "/employees/1?asOf=2025-03-31", "", http.StatusOK).Body).Decode(&emp); err != nil {
+				t.Fatal(err)
+			}
+			if emp.Name != "Dan" || emp.Designation != "Developer" {
+				t.Errorf("deleted employee as of 2025-03-31 = %+v, want Dan the Developer", emp)
+			}
+			var page EmployeePage
+			if err := json.NewDecoder(do("GET", "/employees?asOf=2025-07-01&total=true", "", http.StatusOK).Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+			if *page.Total != 1 || page.Items[0].Name != "Dan" {
+				t.Errorf("employees as of 2025-07-01 after the delete = %+v", page.Items)
+			}
+			rec := do("GET", "/employees/1?asOf="+today().Format(time.DateOnly), "", http.StatusGone)
+			if problem := decodeProblem(t, rec); problem.Code != CodeGone {
+				t.Errorf("problem code = %q, want %q", problem.Code, CodeGone)
+			}
+			do("GET", "/employees/9?asOf=2025-03-31", "", http.StatusNotFound)
+		})
+	}
+}
+
+func TestEmployeeAsOfSnapshots(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+	snapshot := func(name string, recorded time.Time, deleted bool) EmployeeSnapshot {
+		return EmployeeSnapshot{Employee: Employee{ID: 1, Name: name}, Deleted: deleted, RecordedAt: recorded}
+	}
+	designation := "Developer"
+	salary := mustMoney("100")
+	entries := []HistoryEntry{{EffectiveFrom: day(1), Designation: &designation, Salary: &salary, Currency: "USD"}}
+	snapshots := []EmployeeSnapshot{
+		snapshot("Dan", day(5).Add(9*time.Hour), false),
+		snapshot("Daniel", day(10).Add(9*time.Hour), false),
+		snapshot("Daniel", day(20).Add(9*time.Hour), true),
+	}
+
+	tests := []struct {
+		name      string
+		snapshots []EmployeeSnapshot
+		date      time.Time
+		wantName  string
+		wantErr   error
+	}{
+		{"history backdated to before the first snapshot", snapshots, day(2), "Dan", nil},
+		{"snapshot recorded during the day", snapshots, day(5), "Dan", nil},
+		{"later snapshot", snapshots, day(15), "Daniel", nil},
+		{"deleted by then", snapshots, day(20), "", ErrEmployeeDeleted},
+		{"history not begun", snapshots, day(1).AddDate(0, 0, -1), "", ErrEmployeeNotStarted},
+		{"unknown employee", nil, day(15), "", ErrEmployeeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			emp, err := employeeAsOf(tt.snapshots, entries, tt.date)
+			if err != tt.wantErr || emp.Name != tt.wantName {
+				t.Fatalf("employeeAsOf() = %+v, %v, want %q, %v", emp, err, tt.wantName, tt.wantErr)
+			}
+			if err == nil && (emp.Designation != designation || emp.Salary != salary) {
+				t.Errorf("employeeAsOf() position = %s %s, want %s %s", emp.Designation, emp.Salary, designation, salary)
+			}
+		})
+	}
+}
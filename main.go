@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
@@ -12,33 +20,181 @@ import (
 
 type CustomRouter struct {
 	*mux.Router
-	DB *sql.DB `json:"db,omitempty"`
+	Store EmployeeStore `json:"store,omitempty"`
+	// Migrator is nil for backends without a schema
+	Migrator *Migrator `json:"-"`
+	// ShuttingDown is set once the server starts draining so /readyz fails
+	ShuttingDown atomic.Bool `json:"-"`
 }
 
-// NewCustomRouter creates a new CustomRouter instance with the provided router and database connection
-func NewCustomRouter(router *mux.Router, db *sql.DB) *CustomRouter {
+// NewCustomRouter creates a new CustomRouter instance with the provided router and employee store
+func NewCustomRouter(router *mux.Router, store EmployeeStore) *CustomRouter {
 	return &CustomRouter{
 		Router: router,
-		DB:     db,
+		Store:  store,
 	}
 }
 
 func main() {
+	os.Exit(run(os.Args[1:], os.Getenv))
+}
+
+// run starts the service and returns the process exit code once it has shut down
+func run(argv []string, getenv func(string) string) int {
+	cfg, args, err := LoadConfig(argv, getenv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	apiTimeouts = cfg.Timeouts
+	allowedDesignations = cfg.Validation.Designations
+	requireIfMatch = cfg.Concurrency.RequireIfMatch
+	listLimits = cfg.List
+	moneyJSONFormat = cfg.Money.JSONFormat
+	defaultCurrency = cfg.Money.DefaultCurrency
+	orgLimits = cfg.Org
+	auditSettings = cfg.Audit
+
+	// Stop on SIGINT or SIGTERM, cancelling a migration still running
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Open the database for the SQL backends
+	var conn *sql.DB
+	var dialect Dialect
+	switch cfg.Store {
+	case "postgres":
+		conn, err = initDB(cfg)
+		dialect = PostgresDialect
+	case "sqlite":
+		conn, err = initSQLiteDB(cfg.SQLite.Path)
+		dialect = SQLiteDialect
+	}
+	if err != nil {
+		log.Println("Error opening the database:", err)
+		return 1
+	}
+
+	var migrator *Migrator
+	if conn != nil {
+		// Close the pool only after the server has drained
+		defer func() {
+			if err := conn.Close(); err != nil {
+				log.Println("Error closing the database:", err)
+			}
+		}()
+
+		migrator, err = NewMigrator(conn, dialect)
+		if err != nil {
+			log.Println("Error loading migrations:", err)
+			return 1
+		}
+	}
+
+	// Run the migrate subcommand instead of the server when requested
+	if len(args) > 0 && args[0] == "migrate" {
+		if migrator == nil {
+			log.Printf("The %s store has no schema to migrate", cfg.Store)
+			return 2
+		}
+		return runMigrateCommand(ctx, migrator, args[1:])
+	}
+
+	// Select the employee store backend
+	var store EmployeeStore
+	switch cfg.Store {
+	case "postgres":
+		store = NewPostgresEmployeeStore(conn)
+	case "sqlite":
+		store = NewSQLiteEmployeeStore(conn)
+	case "memory":
+		store = NewMemoryEmployeeStore()
+		fmt.Println("Using in-memory employee store; data will not be persisted")
+	}
 
-	db := initDB()
-	defer db.Close()
+	// Print an org chart instead of serving when requested; being read-only, it never migrates
+	// and refuses an outdated schema instead
+	orgChart := len(args) > 0 && args[0] == "orgchart"
+
+	// Bring the schema up to date, or refuse to start against an outdated one
+	if migrator != nil {
+		if cfg.AutoMigrate && !orgChart {
+			if code := runMigrateCommand(ctx, migrator, []string{"up"}); code != 0 {
+				return code
+			}
+		} else {
+			pending, err := migrator.Pending(ctx)
+			if err != nil {
+				log.Println("Error checking migrations:", err)
+				return 1
+			}
+			if len(pending) > 0 {
+				log.Printf("%d pending migrations; run the migrate subcommand or start with -auto-migrate", len(pending))
+				return 1
+			}
+		}
+	}
+
+	if orgChart {
+		return runOrgChartCommand(store, args[1:], os.Stdout)
+	}
 
 	// Create a new router
 	r := mux.NewRouter()
 
 	// Setup routes
-	customRouter := NewCustomRouter(r, db)
+	customRouter := NewCustomRouter(r, store)
+	customRouter.Migrator = migrator
 
 	// Setup routes
 	customRouter.SetupRouter()
 
+	server := &http.Server{
+		Addr:         cfg.ListenAddr,
+		Handler:      r,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+
+	// Apply future-dated designation and salary changes as they come due
+	go runScheduledChanges(ctx, store, scheduledChangeInterval)
+
 	// Start the HTTP server
-	port := ":8080"
-	fmt.Printf("Server started at %s\n", port)
-	log.Fatal(http.ListenAndServe(port, r))
+	serveErr := make(chan error, 1)
+	go func() {
+		fmt.Printf("Server started at %s\n", cfg.ListenAddr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		log.Println("Server failed:", err)
+		return 1
+	case <-ctx.Done():
+		stop()
+	}
+
+	// Fail readiness first and keep serving until load balancers have noticed, then drain
+	// in-flight requests within the grace period
+	customRouter.ShuttingDown.Store(true)
+	if cfg.Server.ShutdownDelay > 0 {
+		fmt.Printf("Shutting down, serving for %s while readiness fails\n", cfg.Server.ShutdownDelay)
+		time.Sleep(cfg.Server.ShutdownDelay)
+	}
+	fmt.Printf("Shutting down, waiting up to %s for in-flight requests\n", cfg.Server.ShutdownGrace)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownGrace)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("Graceful shutdown did not complete:", err)
+		server.Close()
+		return 1
+	}
+
+	fmt.Println("Server stopped")
+	return 0
 }
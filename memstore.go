@@ -0,0 +1,688 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryEmployeeStore is an EmployeeStore that keeps employees in process memory.
+// It is safe for concurrent use and is intended for tests and local demos.
+type MemoryEmployeeStore struct {
+	mu        sync.RWMutex
+	employees map[int]Employee
+	nextID    int
+	rates     map[rateKey]ExchangeRate
+	depts     map[int]Department
+	nextDept  int
+	// history holds the entries of each employee in compareHistoryEntries order
+	history     map[int][]HistoryEntry
+	nextHistory int
+	snapshots   map[int][]EmployeeSnapshot
+	// audit is the audit log in ID order
+	audit []AuditEntry
+}
+
+// rateKey identifies an exchange rate the way the exchange_rate primary key does
+type rateKey struct {
+	From, To, EffectiveDate string
+}
+
+func newRateKey(from, to string, date time.Time) rateKey {
+	return rateKey{From: from, To: to, EffectiveDate: date.Format(time.DateOnly)}
+}
+
+// NewMemoryEmployeeStore creates a new, empty MemoryEmployeeStore
+func NewMemoryEmployeeStore() *MemoryEmployeeStore {
+	return &MemoryEmployeeStore{
+		employees:   make(map[int]Employee),
+		nextID:      1,
+		rates:       make(map[rateKey]ExchangeRate),
+		depts:       make(map[int]Department),
+		nextDept:    1,
+		history:     make(map[int][]HistoryEntry),
+		nextHistory: 1,
+		snapshots:   make(map[int][]EmployeeSnapshot),
+	}
+}
+
+func (s *MemoryEmployeeStore) CreateEmployee(ctx context.Context, emp *Employee) error {
+	// Honour cancellation like the SQL backends do
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.departmentExists(emp.DepartmentID) {
+		return ErrUnknownDepartment
+	}
+	if emp.ManagerID != nil {
+		if _, ok := s.employees[*emp.ManagerID]; !ok {
+			return ErrUnknownManager
+		}
+	}
+
+	// Allocate the next ID from the sequence; any ID on emp is ignored
+	id := s.nextID
+	s.nextID++
+
+	now := time.Now().UTC()
+	emp.ID = id
+	emp.CreatedAt = now
+	emp.UpdatedAt = now
+	emp.Version = 1
+	s.employees[id] = *emp
+	s.addHistoryEntry(initialHistoryEntry(emp, now))
+	s.addSnapshot(*emp, false)
+	return s.appendAudit(ctx, AuditEmployee, AuditCreate, strconv.Itoa(id), nil, emp)
+}
+
+// addHistoryEntry allocates the entry an ID and files it with its employee's history
+func (s *MemoryEmployeeStore) addHistoryEntry(entry *HistoryEntry) {
+	entry.ID = s.nextHistory
+	s.nextHistory++
+	entries := append(s.history[entry.EmployeeID], *entry)
+	slices.SortFunc(entries, compareHistoryEntries)
+	s.history[entry.EmployeeID] = entries
+}
+
+func (s *MemoryEmployeeStore) ReadEmployee(ctx context.Context, id int) (*Employee, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	emp, ok := s.employees[id]
+	if !ok {
+		return nil, ErrEmployeeNotFound
+	}
+	return &emp, nil
+}
+
+func (s *MemoryEmployeeStore) ReadEmployeeList(ctx context.Context, q EmployeeListQuery) ([]Employee, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if q.Limit < 0 {
+		return nil, errors.New("LIMIT must not be negative")
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Filter, then order like the SQL backends
+	var matched []Employee
+	for _, emp := range s.employeesAsOf(q.Filter.AsOf) {
+		if q.Filter.Matches(&emp) && q.beyondCursor(&emp) {
+			matched = append(matched, emp)
+		}
+	}
+	slices.SortFunc(matched, func(a, b Employee) int {
+		return q.Sort.compareEmployees(&a, &b)
+	})
+
+	// Keep the rows nearest the cursor
+	if len(matched) > q.Limit {
+		if q.Cursor != nil && q.Cursor.Backward {
+			matched = matched[len(matched)-q.Limit:]
+		} else {
+			matched = matched[:q.Limit]
+		}
+	}
+	return matched, nil
+}
+
+// employeesAsOf returns every employee as it was on asOf, leaving out those whose history had
+// not begun and those deleted by then; zero means as they are now. The caller holds s.mu.
+func (s *MemoryEmployeeStore) employeesAsOf(asOf time.Time) map[int]Employee {
+	if asOf.IsZero() {
+		return s.employees
+	}
+	past := make(map[int]Employee, len(s.snapshots))
+	for id, snapshots := range s.snapshots {
+		if emp, err := employeeAsOf(snapshots, s.history[id], asOf); err == nil {
+			past[id] = emp
+		}
+	}
+	return past
+}
+
+func (s *MemoryEmployeeStore) CountEmployees(ctx context.Context, f EmployeeFilter, _ bool) (int, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, false, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, emp := range s.employeesAsOf(f.AsOf) {
+		if f.Matches(&emp) {
+			count++
+		}
+	}
+	return count, false, nil
+}
+
+func (s *MemoryEmployeeStore) UpdateEmployee(ctx context.Context, id int, updatedEmp *Employee, fields []string, version int, effective time.Time) (*Employee, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	emp, ok := s.employees[id]
+	if !ok {
+		return nil, ErrEmployeeNotFound
+	}
+	if version != 0 && emp.Version != version {
+		return nil, ErrVersionMismatch
+	}
+	before := emp
+
+	for _, name := range fields {
+		field, ok := employeeFields[name]
+		if !ok {
+			return nil, fmt.Errorf("employee field %q cannot be updated", name)
+		}
+		field.Copy(&emp, updatedEmp)
+	}
+	if !s.departmentExists(emp.DepartmentID) {
+		return nil, ErrUnknownDepartment
+	}
+	if err := s.checkManager(id, emp.ManagerID); err != nil {
+		return nil, err
+	}
+
+	// The employee keeps the position in effect today; the history keeps the rest
+	now := time.Now().UTC()
+	if tracksPosition(fields) {
+		entry, position := recordPosition(s.history[id], updatedEmp, fields, effective)
+		if entry != nil {
+			entry.EmployeeID = id
+			entry.RecordedAt = now
+			s.addHistoryEntry(entry)
+		}
+		position.applyTo(&emp)
+	}
+	emp.UpdatedAt = now
+	emp.Version++
+	s.employees[id] = emp
+	s.addSnapshot(emp, false)
+
+	if err := s.appendAudit(ctx, AuditEmployee, AuditUpdate, strconv.Itoa(id), before, emp); err != nil {
+		return nil, err
+	}
+	return &emp, nil
+}
+
+func (s *MemoryEmployeeStore) DeleteEmployee(ctx context.Context, id int, version int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	emp, ok := s.employees[id]
+	if !ok {
+		return ErrEmployeeNotFound
+	}
+	if version != 0 && emp.Version != version {
+		return ErrVersionMismatch
+	}
+	for _, other := range s.employees {
+		if other.ManagerID != nil && *other.ManagerID == id {
+			return ErrEmployeeHasReports
+		}
+	}
+	// The history and snapshots are kept: they record the employee's past
+	delete(s.employees, id)
+	s.addSnapshot(emp, true)
+	return s.appendAudit(ctx, AuditEmployee, AuditDelete, strconv.Itoa(id), emp, nil)
+}
+
+// addSnapshot records emp as a write left it; deleted marks the snapshot of a deletion
+func (s *MemoryEmployeeStore) addSnapshot(emp Employee, deleted bool) {
+	snapshot := EmployeeSnapshot{Employee: emp, Deleted: deleted, RecordedAt: time.Now().UTC()}
+	s.snapshots[emp.ID] = append(s.snapshots[emp.ID], snapshot)
+}
+
+func (s *MemoryEmployeeStore) ReadSnapshots(ctx context.Context, id int) ([]EmployeeSnapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.snapshots[id]), nil
+}
+
+func (s *MemoryEmployeeStore) ReadHistory(ctx context.Context, id int) ([]HistoryEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.history[id]), nil
+}
+
+func (s *MemoryEmployeeStore) ApplyScheduledChanges(ctx context.Context, since, until time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	ctx = withSchedulerActor(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	applied := 0
+	for id, entries := range s.history {
+		due := slices.ContainsFunc(entries, func(entry HistoryEntry) bool {
+			return entry.EffectiveFrom.After(since) && !entry.EffectiveFrom.After(until)
+		})
+		if !due {
+			continue
+		}
+		emp, ok := s.employees[id]
+		if !ok {
+			continue
+		}
+		position, ok := positionAt(entries, until)
+		if !ok || position == positionOf(&emp) {
+			continue
+		}
+		before := emp
+		position.applyTo(&emp)
+		emp.UpdatedAt = time.Now().UTC()
+		emp.Version++
+		s.employees[id] = emp
+		s.addSnapshot(emp, false)
+		if err := s.appendAudit(ctx, AuditEmployee, AuditUpdate, strconv.Itoa(id), before, emp); err != nil {
+			return applied, err
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+// checkManager reports whether employee id may report to managerID: the manager must exist
+// and must not be id itself or anyone reporting to it. nil means no manager.
+func (s *MemoryEmployeeStore) checkManager(id int, managerID *int) error {
+	if managerID == nil {
+		return nil
+	}
+	if _, ok := s.employees[*managerID]; !ok {
+		return ErrUnknownManager
+	}
+	seen := map[int]bool{}
+	for next := managerID; next != nil && !seen[*next]; next = s.employees[*next].ManagerID {
+		if *next == id {
+			return ErrManagerCycle
+		}
+		seen[*next] = true
+	}
+	return nil
+}
+
+func (s *MemoryEmployeeStore) ReadSubordinates(ctx context.Context, id int, maxDepth int) ([]OrgEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Collect the reports of each manager once, then walk them a level at a time
+	reports := make(map[int][]Employee)
+	for _, emp := range s.employees {
+		if emp.ManagerID != nil {
+			reports[*emp.ManagerID] = append(reports[*emp.ManagerID], emp)
+		}
+	}
+
+	var entries []OrgEntry
+	level := []int{id}
+	for depth := 1; depth <= maxDepth && len(level) > 0; depth++ {
+		var found []OrgEntry
+		for _, managerID := range level {
+			for _, emp := range reports[managerID] {
+				found = append(found, OrgEntry{Employee: emp, Depth: depth})
+			}
+		}
+		slices.SortFunc(found, func(a, b OrgEntry) int { return a.ID - b.ID })
+
+		level = level[:0]
+		for _, entry := range found {
+			level = append(level, entry.ID)
+		}
+		entries = append(entries, found...)
+	}
+	return entries, nil
+}
+
+func (s *MemoryEmployeeStore) ReadManagerChain(ctx context.Context, id int, maxDepth int) ([]OrgEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []OrgEntry
+	next := s.employees[id].ManagerID
+	for depth := 1; depth <= maxDepth && next != nil; depth++ {
+		manager, ok := s.employees[*next]
+		if !ok {
+			break
+		}
+		entries = append(entries, OrgEntry{Employee: manager, Depth: depth})
+		next = manager.ManagerID
+	}
+	return entries, nil
+}
+
+func (s *MemoryEmployeeStore) SalaryTotals(ctx context.Context, f EmployeeFilter) ([]CurrencyTotal, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byCurrency := make(map[string]*CurrencyTotal)
+	for _, emp := range s.employeesAsOf(f.AsOf) {
+		if !f.Matches(&emp) {
+			continue
+		}
+		total, ok := byCurrency[emp.Currency]
+		if !ok {
+			total = &CurrencyTotal{Currency: emp.Currency, Min: emp.Salary, Max: emp.Salary}
+			byCurrency[emp.Currency] = total
+		}
+		sum, err := total.Total.Add(emp.Salary)
+		if err != nil {
+			return nil, err
+		}
+		total.Total = sum
+		total.Count++
+		if emp.Salary.Cmp(total.Min) < 0 {
+			total.Min = emp.Salary
+		}
+		if emp.Salary.Cmp(total.Max) > 0 {
+			total.Max = emp.Salary
+		}
+	}
+
+	totals := make([]CurrencyTotal, 0, len(byCurrency))
+	for _, total := range byCurrency {
+		totals = append(totals, *total)
+	}
+	slices.SortFunc(totals, func(a, b CurrencyTotal) int {
+		return strings.Compare(a.Currency, b.Currency)
+	})
+	return totals, nil
+}
+
+func (s *MemoryEmployeeStore) PutExchangeRate(ctx context.Context, rate *ExchangeRate) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := newRateKey(rate.From, rate.To, rate.EffectiveDate)
+	action := AuditCreate
+	var before any
+	if current, ok := s.rates[key]; ok {
+		action, before = AuditUpdate, current
+	}
+	s.rates[key] = *rate
+	return s.appendAudit(ctx, AuditExchangeRate, action, rate.auditID(), before, rate)
+}
+
+func (s *MemoryEmployeeStore) ReadExchangeRates(ctx context.Context, f ExchangeRateFilter) ([]ExchangeRate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rates []ExchangeRate
+	for _, rate := range s.rates {
+		if f.Matches(&rate) {
+			rates = append(rates, rate)
+		}
+	}
+	slices.SortFunc(rates, compareExchangeRates)
+	return rates, nil
+}
+
+func (s *MemoryEmployeeStore) ReadEffectiveExchangeRate(ctx context.Context, from, to string, date time.Time) (*ExchangeRate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// The latest of the pair's rates that took effect by date
+	var effective *ExchangeRate
+	for _, rate := range s.rates {
+		if rate.From != from || rate.To != to || rate.EffectiveDate.After(date) {
+			continue
+		}
+		if effective == nil || rate.EffectiveDate.After(effective.EffectiveDate) {
+			effective = &rate
+		}
+	}
+	if effective == nil {
+		return nil, ErrExchangeRateNotFound
+	}
+	return effective, nil
+}
+
+func (s *MemoryEmployeeStore) DeleteExchangeRate(ctx context.Context, from, to string, date time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := newRateKey(from, to, date)
+	rate, ok := s.rates[key]
+	if !ok {
+		return ErrExchangeRateNotFound
+	}
+	delete(s.rates, key)
+	return s.appendAudit(ctx, AuditExchangeRate, AuditDelete, rate.auditID(), rate, nil)
+}
+
+// departmentExists reports whether an employee may refer to the department id; nil refers to none
+func (s *MemoryEmployeeStore) departmentExists(id *int) bool {
+	if id == nil {
+		return true
+	}
+	_, ok := s.depts[*id]
+	return ok
+}
+
+func (s *MemoryEmployeeStore) CreateDepartment(ctx context.Context, dept *Department) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.departmentNamed(dept.Name, 0) {
+		return &ConflictError{Resource: "department", Field: "name", Value: dept.Name}
+	}
+
+	now := time.Now().UTC()
+	dept.ID = s.nextDept
+	dept.CreatedAt = now
+	dept.UpdatedAt = now
+	s.nextDept++
+	s.depts[dept.ID] = *dept
+	return s.appendAudit(ctx, AuditDepartment, AuditCreate, strconv.Itoa(dept.ID), nil, dept)
+}
+
+// departmentNamed reports whether a department other than except is called name, as the
+// unique constraint on department.Name would
+func (s *MemoryEmployeeStore) departmentNamed(name string, except int) bool {
+	for id, dept := range s.depts {
+		if id != except && dept.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryEmployeeStore) ReadDepartment(ctx context.Context, id int) (*Department, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dept, ok := s.depts[id]
+	if !ok {
+		return nil, ErrDepartmentNotFound
+	}
+	return &dept, nil
+}
+
+func (s *MemoryEmployeeStore) ReadDepartmentList(ctx context.Context) ([]Department, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var depts []Department
+	for _, dept := range s.depts {
+		depts = append(depts, dept)
+	}
+	slices.SortFunc(depts, func(a, b Department) int { return a.ID - b.ID })
+	return depts, nil
+}
+
+func (s *MemoryEmployeeStore) UpdateDepartment(ctx context.Context, id int, updatedDept *Department) (*Department, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dept, ok := s.depts[id]
+	if !ok {
+		return nil, ErrDepartmentNotFound
+	}
+	if s.departmentNamed(updatedDept.Name, id) {
+		return nil, &ConflictError{Resource: "department", Field: "name", Value: updatedDept.Name}
+	}
+	before := dept
+	dept.Name = updatedDept.Name
+	dept.UpdatedAt = time.Now().UTC()
+	s.depts[id] = dept
+	if err := s.appendAudit(ctx, AuditDepartment, AuditUpdate, strconv.Itoa(id), before, dept); err != nil {
+		return nil, err
+	}
+	return &dept, nil
+}
+
+func (s *MemoryEmployeeStore) DeleteDepartment(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dept, ok := s.depts[id]
+	if !ok {
+		return ErrDepartmentNotFound
+	}
+	for _, emp := range s.employees {
+		if emp.DepartmentID != nil && *emp.DepartmentID == id {
+			return ErrDepartmentNotEmpty
+		}
+	}
+	delete(s.depts, id)
+	return s.appendAudit(ctx, AuditDepartment, AuditDelete, strconv.Itoa(id), dept, nil)
+}
+
+// appendAudit chains the entry of a write to the end of the audit log; see newAuditEntry
+func (s *MemoryEmployeeStore) appendAudit(ctx context.Context, resource, action, targetID string, before, after any) error {
+	entry, err := newAuditEntry(ctx, resource, action, targetID, before, after)
+	if err != nil {
+		return err
+	}
+	var last *AuditEntry
+	if n := len(s.audit); n > 0 {
+		last = &s.audit[n-1]
+	}
+	entry.chain(last)
+	s.audit = append(s.audit, *entry)
+	return nil
+}
+
+func (s *MemoryEmployeeStore) ReadAuditLog(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []AuditEntry
+	for _, entry := range s.audit {
+		if len(entries) == q.Limit {
+			break
+		}
+		if entry.ID > q.AfterID && q.Filter.Matches(&entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (s *MemoryEmployeeStore) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// reset removes every employee, department, exchange rate and audit entry and restarts the ID sequences
+func (s *MemoryEmployeeStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.employees = make(map[int]Employee)
+	s.nextID = 1
+	s.rates = make(map[rateKey]ExchangeRate)
+	s.depts = make(map[int]Department)
+	s.nextDept = 1
+	s.history = make(map[int][]HistoryEntry)
+	s.nextHistory = 1
+	s.snapshots = make(map[int][]EmployeeSnapshot)
+	s.audit = nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestMemoryEmployeeStoreConcurrentCreate(t *testing.T) {
+	store := NewMemoryEmployeeStore()
+
+	// Create employees from many goroutines at once
+	const workers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			emp := &Employee{Name: "Dan", Designation: "Software Developer", Salary: mustMoney("23456.00")}
+			if err := store.CreateEmployee(context.Background(), emp); err != nil {
+				t.Errorf("CreateEmployee() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every employee must have received a distinct ID from the sequence
+	employees, err := store.ReadEmployeeList(context.Background(), EmployeeListQuery{Limit: workers})
+	if err != nil {
+		t.Fatalf("ReadEmployeeList() error = %v", err)
+	}
+	if len(employees) != workers {
+		t.Fatalf("ReadEmployeeList() returned %d employees, want %d", len(employees), workers)
+	}
+	for i, emp := range employees {
+		if emp.ID != i+1 {
+			t.Errorf("employees[%d].ID = %d, want %d", i, emp.ID, i+1)
+		}
+		if emp.CreatedAt.IsZero() || emp.UpdatedAt.IsZero() {
+			t.Errorf("employees[%d] timestamps were not stamped", i)
+		}
+	}
+}
+
+func TestMemoryEmployeeStoreIgnoresClientIDs(t *testing.T) {
+	store := NewMemoryEmployeeStore()
+
+	// IDs always come from the sequence, whatever the caller supplied
+	first := &Employee{ID: 5, Name: "Dan"}
+	second := &Employee{ID: 1, Name: "Ben"}
+	if err := store.CreateEmployee(context.Background(), first); err != nil {
+		t.Fatalf("CreateEmployee() error = %v", err)
+	}
+	if err := store.CreateEmployee(context.Background(), second); err != nil {
+		t.Fatalf("CreateEmployee() error = %v", err)
+	}
+	if first.ID != 1 || second.ID != 2 {
+		t.Errorf("CreateEmployee() IDs = %d, %d, want 1, 2", first.ID, second.ID)
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Migrations live in migrations/<dialect name>/NNNN_description.(up|down).sql
+//
+//go:embed migrations
+var migrationFS embed.FS
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var ErrMigrationChecksumMismatch = errors.New("applied migration has been edited")
+var ErrUnknownMigration = errors.New("database has a migration that is not known to this build")
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	// Checksum covers both scripts, so that editing either is caught
+	Checksum string
+	// UpChecksum covers the up script alone, as recorded by builds that did not check down scripts
+	UpChecksum string
+}
+
+// MigrationStatus reports whether a migration has been applied to the database
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type appliedMigration struct {
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Migrator applies and rolls back the embedded migrations for a database
+type Migrator struct {
+	DB         *sql.DB
+	Dialect    Dialect
+	Migrations []Migration
+}
+
+// NewMigrator creates a Migrator loaded with the embedded migrations for the dialect
+func NewMigrator(db *sql.DB, dialect Dialect) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFS, path.Join("migrations", dialect.Name()))
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Dialect: dialect, Migrations: migrations}, nil
+}
+
+// loadMigrations reads the migrations in dir ordered by version
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			return nil, fmt.Errorf("unexpected file %s in %s", entry.Name(), dir)
+		}
+		version, _ := strconv.Atoi(match[1])
+		contents, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(contents)
+		} else {
+			m.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		m.Checksum, m.UpChecksum = migrationChecksum(m.Up, m.Down), migrationChecksum(m.Up)
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// migrationChecksum hashes the scripts of a migration, each ending with a NUL byte
+func migrationChecksum(scripts ...string) string {
+	h := sha256.New()
+	for _, script := range scripts {
+		h.Write([]byte(script))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// applied returns the migrations recorded in schema_migrations, creating the table if needed
+func (m *Migrator) applied(ctx context.Context, q querier) (map[int]appliedMigration, error) {
+	_, err := q.ExecContext(ctx, m.Dialect.CreateMigrationsTableSQL())
+	if err != nil {
+		return nil, fmt.Errorf("unable to create schema_migrations table: %w", err)
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT Version, Name, Checksum, AppliedAt FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// verify checks that every applied migration is known and unchanged since it was applied
+func (m *Migrator) verify(applied map[int]appliedMigration) error {
+	known := make(map[int]Migration, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		known[migration.Version] = migration
+	}
+
+	for version, a := range applied {
+		migration, ok := known[version]
+		if !ok {
+			return fmt.Errorf("%w: version %d (%s)", ErrUnknownMigration, version, a.Name)
+		}
+		if a.Checksum != migration.Checksum && a.Checksum != migration.UpChecksum {
+			return fmt.Errorf("%w: version %d (%s)", ErrMigrationChecksumMismatch, version, migration.Name)
+		}
+	}
+	return nil
+}
+
+// upgradeChecksums records the checksum of both scripts for migrations applied by builds
+// that only checked the up script; down scripts edited before then cannot be told apart
+func (m *Migrator) upgradeChecksums(ctx context.Context, tx *sql.Tx, applied map[int]appliedMigration) error {
+	for _, migration := range m.Migrations {
+		a, ok := applied[migration.Version]
+		if !ok || a.Checksum == migration.Checksum || a.Checksum != migration.UpChecksum {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, m.Dialect.Rebind("UPDATE schema_migrations SET Checksum = $1 WHERE Version = $2"), migration.Checksum, migration.Version)
+		if err != nil {
+			return err
+		}
+		a.Checksum = migration.Checksum
+		applied[migration.Version] = a
+	}
+	return nil
+}
+
+// step runs fn in a transaction holding the migration lock, passing it the verified
+// migrations applied so far. Migrators of replicas starting together take turns, so each
+// of them sees what the others applied and no migration runs twice.
+func (m *Migrator) step(ctx context.Context, fn func(tx *sql.Tx, applied map[int]appliedMigration) error) error {
+	return m.inTx(ctx, func(tx *sql.Tx) error {
+		if lock := m.Dialect.MigrationLockSQL(); lock != "" {
+			if _, err := tx.ExecContext(ctx, lock); err != nil {
+				return fmt.Errorf("unable to take the migration lock: %w", err)
+			}
+		}
+		applied, err := m.applied(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if err := m.upgradeChecksums(ctx, tx, applied); err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+		return fn(tx, applied)
+	})
+}
+
+// Up applies every pending migration up to and including target; a target of 0 means the latest
+func (m *Migrator) Up(ctx context.Context, target int) ([]Migration, error) {
+	var done []Migration
+	for {
+		var next *Migration
+		err := m.step(ctx, func(tx *sql.Tx, applied map[int]appliedMigration) error {
+			for i, migration := range m.Migrations {
+				if target > 0 && migration.Version > target {
+					break
+				}
+				if _, ok := applied[migration.Version]; !ok {
+					next = &m.Migrations[i]
+					break
+				}
+			}
+			if next == nil {
+				return nil
+			}
+
+			if _, err := tx.ExecContext(ctx, next.Up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, m.Dialect.Rebind("INSERT INTO schema_migrations (Version, Name, Checksum, AppliedAt) VALUES ($1, $2, $3, $4)"),
+				next.Version, next.Name, next.Checksum, time.Now())
+			return err
+		})
+		if err != nil {
+			if next != nil {
+				err = fmt.Errorf("migration %d_%s failed: %w", next.Version, next.Name, err)
+			}
+			return done, err
+		}
+		if next == nil {
+			return done, nil
+		}
+		done = append(done, *next)
+	}
+}
+
+// Down rolls back the most recently applied migrations, at most steps of them
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	for len(done) < steps {
+		var last *Migration
+		err := m.step(ctx, func(tx *sql.Tx, applied map[int]appliedMigration) error {
+			for i := len(m.Migrations) - 1; i >= 0; i-- {
+				if _, ok := applied[m.Migrations[i].Version]; ok {
+					last = &m.Migrations[i]
+					break
+				}
+			}
+			if last == nil {
+				return nil
+			}
+			if last.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down script", last.Version, last.Name)
+			}
+
+			if _, err := tx.ExecContext(ctx, last.Down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, m.Dialect.Rebind("DELETE FROM schema_migrations WHERE Version = $1"), last.Version)
+			return err
+		})
+		if err != nil {
+			if last != nil && last.Down != "" {
+				err = fmt.Errorf("rollback of migration %d_%s failed: %w", last.Version, last.Name, err)
+			}
+			return done, err
+		}
+		if last == nil {
+			break
+		}
+		done = append(done, *last)
+	}
+	return done, nil
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx, m.DB)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.verify(applied); err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		a, ok := applied[migration.Version]
+		status = append(status, MigrationStatus{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Applied:   ok,
+			AppliedAt: a.AppliedAt,
+		})
+	}
+	return status, nil
+}
+
+// Pending returns the migrations that have not yet been applied
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for i, s := range status {
+		if !s.Applied {
+			pending = append(pending, m.Migrations[i])
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// runMigrateCommand implements the `migrate` subcommand and returns the process exit code
+//
+//	migrate up [version]   apply pending migrations, optionally stopping at version
+//	migrate down [steps]   roll back the last applied migration, or the last steps of them
+//	migrate status         list migrations and whether they have been applied
+func runMigrateCommand(ctx context.Context, migrator *Migrator, args []string) int {
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	// Optional numeric argument: the target version for up, the number of steps for down
+	n := 0
+	if len(args) > 1 {
+		var err error
+		n, err = strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			fmt.Printf("Invalid migrate argument %q\n", args[1])
+			return 2
+		}
+	}
+
+	switch action {
+	case "up":
+		done, err := migrator.Up(ctx, n)
+		for _, migration := range done {
+			fmt.Printf("Applied migration %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Println("Error applying migrations:", err)
+			return 1
+		}
+		if len(done) == 0 {
+			fmt.Println("Database schema is up to date")
+		}
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		done, err := migrator.Down(ctx, n)
+		for _, migration := range done {
+			fmt.Printf("Rolled back migration %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Println("Error rolling back migrations:", err)
+			return 1
+		}
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			fmt.Println("Error reading migration status:", err)
+			return 1
+		}
+		for _, s := range status {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, state)
+		}
+	default:
+		fmt.Printf("Unknown migrate action %q (expected up, down or status)\n", action)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_add_index.up.sql":           {Data: []byte("CREATE INDEX i ON t (c);")},
+		"m/0001_create_table.up.sql":        {Data: []byte("CREATE TABLE t (c INTEGER);")},
+		"m/0001_create_table.down.sql":      {Data: []byte("DROP TABLE t;")},
+		"edited/0001_create_table.up.sql":   {Data: []byte("CREATE TABLE t (c INTEGER);")},
+		"edited/0001_create_table.down.sql": {Data: []byte("DROP TABLE t CASCADE;")},
+		"bad/0001_create_table.down.sql":    {Data: []byte("DROP TABLE t;")},
+		"mixed/0001_one.up.sql":             {Data: []byte("SELECT 1;")},
+		"mixed/0001_two.down.sql":           {Data: []byte("SELECT 1;")},
+		"stray/0001_create_table.up.sql":    {Data: []byte("SELECT 1;")},
+		"stray/README.md":                   {Data: []byte("notes")},
+	}
+
+	migrations, err := loadMigrations(fsys, "m")
+	if err != nil {
+		t.Fatalf("loadMigrations() error = %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Version != 2 {
+		t.Fatalf("loadMigrations() = %+v, want versions 1 and 2 in order", migrations)
+	}
+	if migrations[0].Down == "" || migrations[1].Down != "" {
+		t.Errorf("loadMigrations() did not pair down scripts with their versions")
+	}
+
+	// Editing the down script changes the checksum too
+	edited, err := loadMigrations(fsys, "edited")
+	if err != nil {
+		t.Fatalf("loadMigrations(%q) error = %v", "edited", err)
+	}
+	if edited[0].Checksum == migrations[0].Checksum || edited[0].UpChecksum != migrations[0].UpChecksum {
+		t.Errorf("checksums of an edited down script = %s/%s, want the first to change", edited[0].Checksum, edited[0].UpChecksum)
+	}
+
+	for _, dir := range []string{"bad", "mixed", "stray"} {
+		if _, err := loadMigrations(fsys, dir); err == nil {
+			t.Errorf("loadMigrations(%q) did not return an error", dir)
+		}
+	}
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	db := initTestSQLiteDB(t)
+	migrator, err := NewMigrator(db, SQLiteDialect)
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+
+	// Apply everything, then check that a second run is a no-op
+	done, err := migrator.Up(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if len(done) != len(migrator.Migrations) {
+		t.Fatalf("Up() applied %d migrations, want %d", len(done), len(migrator.Migrations))
+	}
+	done, err = migrator.Up(context.Background(), 0)
+	if err != nil || len(done) != 0 {
+		t.Fatalf("second Up() = %d migrations, %v, want none", len(done), err)
+	}
+	pending, err := migrator.Pending(context.Background())
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("Pending() = %d migrations, %v, want none", len(pending), err)
+	}
+
+	// Roll everything back and make sure the tables are gone
+	done, err = migrator.Down(context.Background(), len(migrator.Migrations))
+	if err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+	if len(done) != len(migrator.Migrations) {
+		t.Fatalf("Down() rolled back %d migrations, want %d", len(done), len(migrator.Migrations))
+	}
+	if _, err := db.Exec("SELECT 1 FROM employee"); err == nil {
+		t.Errorf("employee table still exists after rolling back every migration")
+	}
+	pending, err = migrator.Pending(context.Background())
+	if err != nil || len(pending) != len(migrator.Migrations) {
+		t.Fatalf("Pending() = %d migrations, %v, want %d", len(pending), err, len(migrator.Migrations))
+	}
+}
+
+func TestConcurrentMigratorsApplyEachMigrationOnce(t *testing.T) {
+	// Replicas starting together share the database but not the connection
+	path := filepath.Join(t.TempDir(), "employee.db")
+	migrators := make([]*Migrator, 3)
+	for i := range migrators {
+		db, err := initSQLiteDB(path)
+		if err != nil {
+			t.Fatalf("initSQLiteDB() error = %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		migrator, err := NewMigrator(db, SQLiteDialect)
+		if err != nil {
+			t.Fatalf("NewMigrator() error = %v", err)
+		}
+		migrators[i] = migrator
+	}
+
+	applied := make([]int, len(migrators))
+	errs := make([]error, len(migrators))
+	var wg sync.WaitGroup
+	for i, migrator := range migrators {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			done, err := migrator.Up(context.Background(), 0)
+			applied[i], errs[i] = len(done), err
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Up() of migrator %d error = %v", i, err)
+		}
+		total += applied[i]
+	}
+	if total != len(migrators[0].Migrations) {
+		t.Errorf("migrators applied %v migrations, want %d in all", applied, len(migrators[0].Migrations))
+	}
+}
+
+func TestMigratorDetectsEditedAndUnknownMigrations(t *testing.T) {
+	db := initTestSQLiteDB(t)
+	migrator, err := NewMigrator(db, SQLiteDialect)
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 0); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+
+	// Checksums of the up script alone, recorded by older builds, are brought up to date
+	if _, err := db.Exec("UPDATE schema_migrations SET Checksum = ? WHERE Version = 1", migrator.Migrations[0].UpChecksum); err != nil {
+		t.Fatalf("Unable to record an up-only checksum: %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 0); err != nil {
+		t.Fatalf("Up() with an up-only checksum error = %v", err)
+	}
+	var checksum string
+	if err := db.QueryRow("SELECT Checksum FROM schema_migrations WHERE Version = 1").Scan(&checksum); err != nil || checksum != migrator.Migrations[0].Checksum {
+		t.Errorf("checksum of migration 1 = %q, %v, want %q", checksum, err, migrator.Migrations[0].Checksum)
+	}
+
+	// An applied migration whose down script was edited afterwards cannot be rolled back
+	edited := *migrator
+	edited.Migrations = slices.Clone(migrator.Migrations)
+	last := &edited.Migrations[len(edited.Migrations)-1]
+	last.Down += "\nDROP TABLE employee;"
+	last.Checksum = migrationChecksum(last.Up, last.Down)
+	if _, err := edited.Down(context.Background(), 1); !errors.Is(err, ErrMigrationChecksumMismatch) {
+		t.Errorf("Down() with an edited down script error = %v, want %v", err, ErrMigrationChecksumMismatch)
+	}
+
+	// Simulate an applied migration whose file was edited afterwards
+	if _, err := db.Exec("UPDATE schema_migrations SET Checksum = 'edited' WHERE Version = 1"); err != nil {
+		t.Fatalf("Unable to tamper with schema_migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 0); !errors.Is(err, ErrMigrationChecksumMismatch) {
+		t.Errorf("Up() error = %v, want %v", err, ErrMigrationChecksumMismatch)
+	}
+
+	// Simulate a database migrated by a newer build
+	if _, err := db.Exec("DELETE FROM schema_migrations"); err != nil {
+		t.Fatalf("Unable to clear schema_migrations: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO schema_migrations (Version, Name, Checksum) VALUES (9999, 'future', 'x')"); err != nil {
+		t.Fatalf("Unable to insert into schema_migrations: %v", err)
+	}
+	if _, err := migrator.Status(context.Background()); !errors.Is(err, ErrUnknownMigration) {
+		t.Errorf("Status() error = %v, want %v", err, ErrUnknownMigration)
+	}
+}
+
+func TestSQLiteSalaryMigrationKeepsRowsAndSequence(t *testing.T) {
+	db := initTestSQLiteDB(t)
+	migrator, err := NewMigrator(db, SQLiteDialect)
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 4); err != nil {
+		t.Fatalf("Up(4) error = %v", err)
+	}
+
+	// A float salary and a deleted row whose ID must not be handed out again
+	if _, err := db.Exec("INSERT INTO employee (Name, Designation, Salary) VALUES ('Sen', 'Lead', 44566.1), ('Dan', 'Developer', 1)"); err != nil {
+		t.Fatalf("Unable to insert employees: %v", err)
+	}
+	if _, err := db.Exec("DELETE FROM employee WHERE ID = 2"); err != nil {
+		t.Fatalf("Unable to delete employee: %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 5); err != nil {
+		t.Fatalf("Up(5) error = %v", err)
+	}
+
+	var salary Money
+	if err := db.QueryRow("SELECT Salary FROM employee WHERE ID = 1").Scan(&salary); err != nil || salary != mustMoney("44566.10") {
+		t.Fatalf("salary of employee 1 = %v, %v, want 44566.10", salary, err)
+	}
+	result, err := db.Exec("INSERT INTO employee (Name, Designation, Salary) VALUES ('Ben', 'Developer', 1)")
+	if err != nil {
+		t.Fatalf("Unable to insert employee: %v", err)
+	}
+	if id, _ := result.LastInsertId(); id != 3 {
+		t.Errorf("new employee ID = %d, want 3", id)
+	}
+}
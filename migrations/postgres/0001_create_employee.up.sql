@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS employee (
+	ID SERIAL PRIMARY KEY,
+	Name VARCHAR(100) NOT NULL,
+	Designation VARCHAR(100) NOT NULL,
+	Salary FLOAT8 NOT NULL,
+	CreatedAt TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	UpdatedAt TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
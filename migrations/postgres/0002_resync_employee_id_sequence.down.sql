@@ -0,0 +1 @@
+-- The sequence position cannot be restored and does not need to be.
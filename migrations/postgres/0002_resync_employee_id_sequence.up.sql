@@ -0,0 +1,3 @@
+-- Rows inserted with explicit IDs never advanced the SERIAL sequence, so move it past
+-- the highest existing ID before IDs are always allocated from it.
+SELECT setval(pg_get_serial_sequence('employee', 'id'), COALESCE(MAX(ID), 1), MAX(ID) IS NOT NULL) FROM employee;
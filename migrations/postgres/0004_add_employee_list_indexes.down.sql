@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS employee_updated_at_idx;
+DROP INDEX IF EXISTS employee_created_at_idx;
+DROP INDEX IF EXISTS employee_salary_idx;
+DROP INDEX IF EXISTS employee_designation_idx;
+DROP INDEX IF EXISTS employee_name_idx;
+DROP INDEX IF EXISTS employee_designation_lower_idx;
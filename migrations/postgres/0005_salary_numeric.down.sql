@@ -0,0 +1 @@
+ALTER TABLE employee ALTER COLUMN Salary TYPE FLOAT8 USING Salary::FLOAT8;
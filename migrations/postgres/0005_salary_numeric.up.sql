@@ -0,0 +1,2 @@
+-- Salaries are exact amounts with two decimal places.
+ALTER TABLE employee ALTER COLUMN Salary TYPE NUMERIC(14, 2) USING ROUND(Salary::NUMERIC, 2);
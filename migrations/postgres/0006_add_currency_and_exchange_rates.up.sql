@@ -0,0 +1,13 @@
+-- Salaries carry an ISO 4217 currency code; existing salaries are taken to be in USD.
+ALTER TABLE employee ADD COLUMN IF NOT EXISTS Currency CHAR(3) NOT NULL DEFAULT 'USD';
+CREATE INDEX IF NOT EXISTS employee_currency_idx ON employee (Currency, ID);
+
+-- One unit of FromCurrency is worth Rate units of ToCurrency from EffectiveDate until the
+-- pair's next effective date.
+CREATE TABLE IF NOT EXISTS exchange_rate (
+	FromCurrency CHAR(3) NOT NULL,
+	ToCurrency CHAR(3) NOT NULL,
+	EffectiveDate DATE NOT NULL,
+	Rate NUMERIC(20, 10) NOT NULL CHECK (Rate > 0),
+	PRIMARY KEY (FromCurrency, ToCurrency, EffectiveDate)
+);
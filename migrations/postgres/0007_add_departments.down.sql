@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS employee_department_idx;
+ALTER TABLE employee DROP COLUMN IF EXISTS DepartmentID;
+DROP TABLE IF EXISTS department;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS department (
+	ID SERIAL PRIMARY KEY,
+	Name VARCHAR(100) NOT NULL UNIQUE,
+	CreatedAt TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	UpdatedAt TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- A department with employees cannot be deleted until they are reassigned.
+ALTER TABLE employee ADD COLUMN IF NOT EXISTS DepartmentID INTEGER REFERENCES department (ID);
+CREATE INDEX IF NOT EXISTS employee_department_idx ON employee (DepartmentID, ID);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS employee_manager_idx;
+ALTER TABLE employee DROP COLUMN IF EXISTS ManagerID;
@@ -0,0 +1,18 @@
+-- Every change of designation or salary, effective from a date that may lie in the future.
+-- A row holds only what changed; Salary and Currency always change together.
+CREATE TABLE IF NOT EXISTS employee_history (
+	ID SERIAL PRIMARY KEY,
+	EmployeeID INTEGER NOT NULL REFERENCES employee (ID) ON DELETE CASCADE,
+	EffectiveFrom DATE NOT NULL,
+	Designation VARCHAR(100),
+	Salary NUMERIC(14, 2),
+	Currency CHAR(3),
+	RecordedAt TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	CHECK (Designation IS NOT NULL OR Salary IS NOT NULL),
+	CHECK ((Salary IS NULL) = (Currency IS NULL))
+);
+CREATE INDEX IF NOT EXISTS employee_history_employee_idx ON employee_history (EmployeeID, EffectiveFrom, ID);
+
+-- Existing employees start their history with what they hold now, from the day they were created
+INSERT INTO employee_history (EmployeeID, EffectiveFrom, Designation, Salary, Currency, RecordedAt)
+	SELECT ID, CAST(CreatedAt AT TIME ZONE 'UTC' AS DATE), Designation, Salary, Currency, CreatedAt FROM employee;
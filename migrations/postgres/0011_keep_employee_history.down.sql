@@ -0,0 +1,3 @@
+DELETE FROM employee_history WHERE EmployeeID NOT IN (SELECT ID FROM employee);
+ALTER TABLE employee_history ADD CONSTRAINT employee_history_employeeid_fkey
+	FOREIGN KEY (EmployeeID) REFERENCES employee (ID) ON DELETE CASCADE;
@@ -0,0 +1,3 @@
+-- The history outlives the employee, so a deletion no longer takes the employee's past
+-- raises and promotions with it
+ALTER TABLE employee_history DROP CONSTRAINT IF EXISTS employee_history_employeeid_fkey;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS employee (
+	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+	Name VARCHAR(100) NOT NULL,
+	Designation VARCHAR(100) NOT NULL,
+	Salary REAL NOT NULL,
+	CreatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UpdatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+-- AUTOINCREMENT always allocates above the highest ID ever used, so there is nothing
+-- to resync; this keeps the migration versions aligned with PostgreSQL.
+SELECT 1;
@@ -0,0 +1,2 @@
+-- Version is bumped by every update and exposed as the employee's ETag.
+ALTER TABLE employee ADD COLUMN Version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,9 @@
+-- Indexes backing the filters and sort orders of the employee listing. Each sort column is
+-- paired with ID, the tie-breaker of every ordering. Substring searches on the name cannot
+-- use a B-tree index and scan instead.
+CREATE INDEX IF NOT EXISTS employee_designation_lower_idx ON employee (LOWER(Designation));
+CREATE INDEX IF NOT EXISTS employee_name_idx ON employee (Name, ID);
+CREATE INDEX IF NOT EXISTS employee_designation_idx ON employee (Designation, ID);
+CREATE INDEX IF NOT EXISTS employee_salary_idx ON employee (Salary, ID);
+CREATE INDEX IF NOT EXISTS employee_created_at_idx ON employee (CreatedAt, ID);
+CREATE INDEX IF NOT EXISTS employee_updated_at_idx ON employee (UpdatedAt, ID);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS exchange_rate;
+DROP INDEX IF EXISTS employee_currency_idx;
+ALTER TABLE employee DROP COLUMN Currency;
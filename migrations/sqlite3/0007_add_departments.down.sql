@@ -0,0 +1,30 @@
+-- SQLite cannot drop a column with a foreign key, so the table is rebuilt without it
+CREATE TABLE employee_rebuilt (
+	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+	Name VARCHAR(100) NOT NULL,
+	Designation VARCHAR(100) NOT NULL,
+	Salary NUMERIC(14, 2) NOT NULL,
+	CreatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UpdatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	Version INTEGER NOT NULL DEFAULT 1,
+	Currency CHAR(3) NOT NULL DEFAULT 'USD'
+);
+INSERT INTO employee_rebuilt (ID, Name, Designation, Salary, CreatedAt, UpdatedAt, Version, Currency)
+	SELECT ID, Name, Designation, Salary, CreatedAt, UpdatedAt, Version, Currency FROM employee;
+
+-- Keep the ID sequence where it was, even past deleted rows
+DELETE FROM sqlite_sequence WHERE name = 'employee_rebuilt';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'employee_rebuilt', seq FROM sqlite_sequence WHERE name = 'employee';
+
+DROP TABLE employee;
+ALTER TABLE employee_rebuilt RENAME TO employee;
+
+CREATE INDEX employee_designation_lower_idx ON employee (LOWER(Designation));
+CREATE INDEX employee_name_idx ON employee (Name, ID);
+CREATE INDEX employee_designation_idx ON employee (Designation, ID);
+CREATE INDEX employee_salary_idx ON employee (Salary, ID);
+CREATE INDEX employee_created_at_idx ON employee (CreatedAt, ID);
+CREATE INDEX employee_updated_at_idx ON employee (UpdatedAt, ID);
+CREATE INDEX employee_currency_idx ON employee (Currency, ID);
+
+DROP TABLE department;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS department (
+	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+	Name VARCHAR(100) NOT NULL UNIQUE,
+	CreatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UpdatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A department with employees cannot be deleted until they are reassigned.
+ALTER TABLE employee ADD COLUMN DepartmentID INTEGER REFERENCES department (ID);
+CREATE INDEX IF NOT EXISTS employee_department_idx ON employee (DepartmentID, ID);
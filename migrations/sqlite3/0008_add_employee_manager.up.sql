@@ -0,0 +1,4 @@
+-- Reporting lines: ManagerID is the employee's manager, NULL at the top of the hierarchy.
+-- Managers with reports cannot be deleted until the reports are reassigned.
+ALTER TABLE employee ADD COLUMN ManagerID INTEGER REFERENCES employee (ID);
+CREATE INDEX IF NOT EXISTS employee_manager_idx ON employee (ManagerID, ID);
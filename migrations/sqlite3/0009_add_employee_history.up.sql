@@ -0,0 +1,20 @@
+-- Every change of designation or salary, effective from a date that may lie in the future.
+-- A row holds only what changed; Salary and Currency always change together.
+-- Dropping employee, as a table rebuild does, deletes every row here through the cascade.
+CREATE TABLE IF NOT EXISTS employee_history (
+	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+	EmployeeID INTEGER NOT NULL REFERENCES employee (ID) ON DELETE CASCADE,
+	EffectiveFrom DATE NOT NULL,
+	Designation VARCHAR(100),
+	Salary NUMERIC(14, 2),
+	Currency CHAR(3),
+	RecordedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CHECK (Designation IS NOT NULL OR Salary IS NOT NULL),
+	CHECK ((Salary IS NULL) = (Currency IS NULL))
+);
+CREATE INDEX IF NOT EXISTS employee_history_employee_idx ON employee_history (EmployeeID, EffectiveFrom, ID);
+
+-- Existing employees start their history with what they hold now, from the day they were
+-- created. Dates are written the way the driver writes a time.Time at midnight UTC.
+INSERT INTO employee_history (EmployeeID, EffectiveFrom, Designation, Salary, Currency, RecordedAt)
+	SELECT ID, substr(CreatedAt, 1, 10) || ' 00:00:00+00:00', Designation, Salary, Currency, CreatedAt FROM employee;
@@ -0,0 +1,28 @@
+-- One row per write made through the API. Every row carries the hash of the row before it,
+-- so changing or removing a row breaks the chain from there on.
+CREATE TABLE IF NOT EXISTS audit_log (
+	ID INTEGER PRIMARY KEY,
+	RecordedAt TIMESTAMP NOT NULL,
+	Actor VARCHAR(128) NOT NULL,
+	Action VARCHAR(20) NOT NULL,
+	Resource VARCHAR(40) NOT NULL,
+	TargetID VARCHAR(100) NOT NULL,
+	Changes TEXT NOT NULL,
+	RequestID VARCHAR(128) NOT NULL,
+	ClientIP VARCHAR(64) NOT NULL,
+	PrevHash CHAR(64) NOT NULL,
+	Hash CHAR(64) NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audit_log_target_idx ON audit_log (Resource, TargetID);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (Actor);
+CREATE INDEX IF NOT EXISTS audit_log_recorded_at_idx ON audit_log (RecordedAt);
+
+-- The log is append-only for anyone who cannot drop the triggers
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
@@ -0,0 +1,22 @@
+CREATE TABLE employee_history_rebuilt (
+	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+	EmployeeID INTEGER NOT NULL REFERENCES employee (ID) ON DELETE CASCADE,
+	EffectiveFrom DATE NOT NULL,
+	Designation VARCHAR(100),
+	Salary NUMERIC(14, 2),
+	Currency CHAR(3),
+	RecordedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CHECK (Designation IS NOT NULL OR Salary IS NOT NULL),
+	CHECK ((Salary IS NULL) = (Currency IS NULL))
+);
+INSERT INTO employee_history_rebuilt (ID, EmployeeID, EffectiveFrom, Designation, Salary, Currency, RecordedAt)
+	SELECT ID, EmployeeID, EffectiveFrom, Designation, Salary, Currency, RecordedAt FROM employee_history
+	WHERE EmployeeID IN (SELECT ID FROM employee);
+
+DELETE FROM sqlite_sequence WHERE name = 'employee_history_rebuilt';
+INSERT INTO sqlite_sequence (name, seq) SELECT 'employee_history_rebuilt', seq FROM sqlite_sequence WHERE name = 'employee_history';
+
+DROP TABLE employee_history;
+ALTER TABLE employee_history_rebuilt RENAME TO employee_history;
+
+CREATE INDEX employee_history_employee_idx ON employee_history (EmployeeID, EffectiveFrom, ID);
@@ -0,0 +1,23 @@
+-- The whole employee row as every write left it, so reads as of a past date show the name,
+-- department and manager of the time. Deleted marks the snapshot taken as the employee was
+-- deleted; like the history, snapshots outlive the employee.
+CREATE TABLE IF NOT EXISTS employee_snapshot (
+	ID INTEGER PRIMARY KEY AUTOINCREMENT,
+	EmployeeID INTEGER NOT NULL,
+	Name VARCHAR(100) NOT NULL,
+	Designation VARCHAR(100) NOT NULL,
+	Salary NUMERIC(14, 2) NOT NULL,
+	Currency CHAR(3) NOT NULL,
+	DepartmentID INTEGER,
+	ManagerID INTEGER,
+	CreatedAt TIMESTAMP NOT NULL,
+	UpdatedAt TIMESTAMP NOT NULL,
+	Version INTEGER NOT NULL,
+	Deleted BOOLEAN NOT NULL DEFAULT FALSE,
+	RecordedAt TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS employee_snapshot_employee_idx ON employee_snapshot (EmployeeID, RecordedAt, ID);
+
+-- Existing employees are only known as they are now
+INSERT INTO employee_snapshot (EmployeeID, Name, Designation, Salary, Currency, DepartmentID, ManagerID, CreatedAt, UpdatedAt, Version, RecordedAt)
+	SELECT ID, Name, Designation, Salary, Currency, DepartmentID, ManagerID, CreatedAt, UpdatedAt, Version, UpdatedAt FROM employee;
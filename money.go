@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Money is an exact amount held in minor units (hundredths), so sums and comparisons never
+// pick up the rounding errors of binary floating point. The zero value is 0.00.
+type Money struct {
+	minor int64
+}
+
+// Money JSON formats, selected by moneyJSONFormat
+const (
+	// MoneyJSONNumber writes amounts as JSON numbers with two decimals, as Salary was before
+	// it became Money. It is the transitional default.
+	MoneyJSONNumber = "number"
+	// MoneyJSONString writes amounts as decimal strings, e.g. "50000.50"
+	MoneyJSONString = "string"
+	// MoneyJSONMinor writes amounts as integer minor units, e.g. 5000050, and reads JSON
+	// numbers as minor units too
+	MoneyJSONMinor = "minor"
+)
+
+// moneyJSONFormat is how Money is written to JSON; main replaces it with the configured format
+var moneyJSONFormat = MoneyJSONNumber
+
+var (
+	errMoneyPrecision = errors.New("must have at most 2 decimal places")
+	errMoneyRange     = errors.New("is out of range")
+	errMoneySyntax    = errors.New("must be a decimal amount")
+)
+
+// MoneyFromMinor returns the amount of the given number of minor units
+func MoneyFromMinor(minor int64) Money {
+	return Money{minor: minor}
+}
+
+// ParseMoney reads a decimal amount such as "50000.5" or "-12", exactly. Amounts with more
+// than two decimal places are rejected rather than rounded.
+func ParseMoney(s string) (Money, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Money{}, errMoneySyntax
+	}
+	return moneyFromRat(r)
+}
+
+func moneyFromRat(r *big.Rat) (Money, error) {
+	r = new(big.Rat).Mul(r, big.NewRat(100, 1))
+	if !r.IsInt() {
+		return Money{}, errMoneyPrecision
+	}
+	if !r.Num().IsInt64() {
+		return Money{}, errMoneyRange
+	}
+	return Money{minor: r.Num().Int64()}, nil
+}
+
+// Minor returns the amount in minor units
+func (m Money) Minor() int64 {
+	return m.minor
+}
+
+// Rat returns the amount as an exact rational, for arithmetic beyond Add
+func (m Money) Rat() *big.Rat {
+	return big.NewRat(m.minor, 100)
+}
+
+// Cmp compares m and o, returning -1, 0 or +1
+func (m Money) Cmp(o Money) int {
+	switch {
+	case m.minor < o.minor:
+		return -1
+	case m.minor > o.minor:
+		return 1
+	}
+	return 0
+}
+
+// Add returns m + o, failing instead of overflowing
+func (m Money) Add(o Money) (Money, error) {
+	if (o.minor > 0 && m.minor > math.MaxInt64-o.minor) || (o.minor < 0 && m.minor < math.MinInt64-o.minor) {
+		return Money{}, errMoneyRange
+	}
+	return Money{minor: m.minor + o.minor}, nil
+}
+
+// Mul returns m × r rounded to the cent, halves away from zero, e.g. to convert m at an
+// exchange rate
+func (m Money) Mul(r *big.Rat) (Money, error) {
+	x := new(big.Rat).Mul(new(big.Rat).SetInt64(m.minor), r)
+	q, rem := new(big.Int).QuoRem(x.Num(), x.Denom(), new(big.Int))
+	if twice := new(big.Int).Lsh(rem.Abs(rem), 1); twice.Cmp(x.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(x.Sign())))
+	}
+	if !q.IsInt64() {
+		return Money{}, errMoneyRange
+	}
+	return Money{minor: q.Int64()}, nil
+}
+
+// SumMoney adds up amounts exactly; use it for every total
+func SumMoney(amounts ...Money) (Money, error) {
+	var sum Money
+	for _, amount := range amounts {
+		var err error
+		if sum, err = sum.Add(amount); err != nil {
+			return Money{}, err
+		}
+	}
+	return sum, nil
+}
+
+// String formats the amount with two decimals, e.g. "-0.50"
+func (m Money) String() string {
+	sign := ""
+	minor := uint64(m.minor)
+	if m.minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/100, minor%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	switch moneyJSONFormat {
+	case MoneyJSONString:
+		return json.Marshal(m.String())
+	case MoneyJSONMinor:
+		return []byte(strconv.FormatInt(m.minor, 10)), nil
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts decimal strings in every format, and JSON numbers as a decimal
+// amount or, in the minor format, as minor units
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var s string
+	if data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return errMoneySyntax
+		}
+	} else {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return errMoneySyntax
+		}
+		s = n.String()
+		if moneyJSONFormat == MoneyJSONMinor {
+			minor, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return errors.New("must be a whole number of minor units")
+			}
+			*m = Money{minor: minor}
+			return nil
+		}
+	}
+
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value stores the amount as an exact decimal string
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan reads a NUMERIC column. SQLite keeps NUMERIC values as integers or doubles, which
+// are exact to the cent for any amount below 10^13.
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	case int64:
+		parsed, err := moneyFromRat(new(big.Rat).SetInt64(v))
+		*m = parsed
+		return err
+	case float64:
+		*m = Money{minor: int64(math.Round(v * 100))}
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into Money", src)
+}
+
+func (m *Money) scanString(s string) error {
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return fmt.Errorf("scanning money %q: %w", s, err)
+	}
+	*m = parsed
+	return nil
+}
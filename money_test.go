@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "50000", want: 5000000},
+		{in: "50000.5", want: 5000050},
+		{in: "0.10", want: 10},
+		{in: "-12.34", want: -1234},
+		{in: "1.5e2", want: 15000},
+		{in: "0.1e-1", want: 1},
+		{in: "0.105", wantErr: true},
+		{in: "1e-3", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+		{in: "lots", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseMoney(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMoney(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if err == nil && got.Minor() != tt.want {
+				t.Errorf("ParseMoney(%q) = %d minor units, want %d", tt.in, got.Minor(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		minor int64
+		want  string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{-50, "-0.50"},
+		{5000050, "50000.50"},
+		{math.MinInt64, "-92233720368547758.08"},
+	}
+	for _, tt := range tests {
+		if got := MoneyFromMinor(tt.minor).String(); got != tt.want {
+			t.Errorf("MoneyFromMinor(%d).String() = %q, want %q", tt.minor, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	defer func(format string) { moneyJSONFormat = format }(moneyJSONFormat)
+
+	tests := []struct {
+		format string
+		want   string
+		in     string
+	}{
+		{format: MoneyJSONNumber, want: `50000.50`, in: `50000.5`},
+		{format: MoneyJSONString, want: `"50000.50"`, in: `"50000.50"`},
+		{format: MoneyJSONMinor, want: `5000050`, in: `5000050`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			moneyJSONFormat = tt.format
+			got, err := json.Marshal(mustMoney("50000.50"))
+			if err != nil || string(got) != tt.want {
+				t.Fatalf("json.Marshal() = %s, %v, want %s", got, err, tt.want)
+			}
+
+			var m Money
+			if err := json.Unmarshal([]byte(tt.in), &m); err != nil || m != mustMoney("50000.50") {
+				t.Errorf("json.Unmarshal(%s) = %v, %v, want 50000.50", tt.in, m, err)
+			}
+
+			// Decimal strings are accepted whatever the format
+			if err := json.Unmarshal([]byte(`"0.25"`), &m); err != nil || m.Minor() != 25 {
+				t.Errorf(`json.Unmarshal("0.25") = %v, %v, want 0.25`, m, err)
+			}
+		})
+	}
+
+	moneyJSONFormat = MoneyJSONMinor
+	var m Money
+	if err := json.Unmarshal([]byte(`100.5`), &m); err == nil {
+		t.Errorf("json.Unmarshal(100.5) in minor format did not return an error")
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		src  any
+		want int64
+	}{
+		{src: []byte("50000.50"), want: 5000050},
+		{src: "0.07", want: 7},
+		{src: int64(42), want: 4200},
+		{src: 0.1 + 0.2, want: 30},
+		{src: 1234567890.99, want: 123456789099},
+	}
+	for _, tt := range tests {
+		var m Money
+		if err := m.Scan(tt.src); err != nil || m.Minor() != tt.want {
+			t.Errorf("Scan(%v) = %d, %v, want %d", tt.src, m.Minor(), err, tt.want)
+		}
+	}
+
+	var m Money
+	if err := m.Scan(true); err == nil {
+		t.Errorf("Scan(true) did not return an error")
+	}
+}
+
+func TestSumMoney(t *testing.T) {
+	// Ten cents ten times is exactly one unit, unlike with floats
+	var dimes []Money
+	for i := 0; i < 10; i++ {
+		dimes = append(dimes, mustMoney("0.10"))
+	}
+	if sum, err := SumMoney(dimes...); err != nil || sum != mustMoney("1") {
+		t.Errorf("SumMoney(10 x 0.10) = %v, %v, want 1.00", sum, err)
+	}
+
+	if _, err := SumMoney(MoneyFromMinor(math.MaxInt64), MoneyFromMinor(1)); err == nil {
+		t.Errorf("SumMoney() overflow did not return an error")
+	}
+}
+
+func TestSQLiteSalaryIsExact(t *testing.T) {
+	store := initTestSQLiteStore(t)
+
+	employees := []Employee{
+		{Name: "Sen", Designation: "Account Manager", Salary: mustMoney("0.10")},
+		{Name: "Dan", Designation: "Software Developer", Salary: mustMoney("999999999.99")},
+	}
+	if err := InsertTableEmployee(store, employees); err != nil {
+		t.Fatalf("Unable to insert employees: %v", err)
+	}
+	for _, want := range employees {
+		got, err := store.ReadEmployee(context.Background(), want.ID)
+		if err != nil {
+			t.Fatalf("ReadEmployee() error = %v", err)
+		}
+		if got.Salary != want.Salary {
+			t.Errorf("ReadEmployee(%d).Salary = %v, want %v", want.ID, got.Salary, want.Salary)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Org chart formats
+const (
+	OrgChartJSON    = "json"
+	OrgChartDOT     = "dot"
+	OrgChartMermaid = "mermaid"
+)
+
+// OrgChartOptions selects what an org chart shows
+type OrgChartOptions struct {
+	// RootID roots the chart at one employee; nil charts everyone without a manager
+	RootID *int
+	// MaxDepth is how many levels below each root are drawn
+	MaxDepth int
+	// Designation and Department add those labels to each employee's name
+	Designation bool
+	Department  bool
+}
+
+// OrgChartNode is one employee of an org chart with everyone reporting to it
+type OrgChartNode struct {
+	ID          int             `json:"id"`
+	Name        string          `json:"name"`
+	Designation string          `json:"designation,omitempty"`
+	Department  string          `json:"department,omitempty"`
+	Reports     []*OrgChartNode `json:"reports"`
+	// Truncated is set when the employee has reports below MaxDepth that were left out
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// OrgChart is a forest of reporting lines, one tree per root
+type OrgChart struct {
+	Roots    []*OrgChartNode `json:"roots"`
+	MaxDepth int             `json:"maxDepth"`
+}
+
+// parseOrgChartQuery reads the parameters of an org chart:
+//
+//	root=1                         employee the chart is rooted at; every employee without
+//	  a manager by default
+//	format=dot                     json (default), dot or mermaid
+//	labels=designation,department  labels shown under each name
+//	maxDepth=3                     levels below the root, 1 to org.maxDepth
+//
+// Every invalid parameter is reported at once.
+func parseOrgChartQuery(values url.Values) (OrgChartOptions, string, error) {
+	var errs []FieldError
+	var opts OrgChartOptions
+
+	if v := values.Get("root"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, FieldError{Field: "root", Message: "must be an employee ID"})
+		}
+		opts.RootID = &id
+	}
+
+	format := values.Get("format")
+	switch format {
+	case "":
+		format = OrgChartJSON
+	case OrgChartJSON, OrgChartDOT, OrgChartMermaid:
+	default:
+		errs = append(errs, FieldError{Field: "format", Message: "must be json, dot or mermaid"})
+	}
+
+	if v := values.Get("labels"); v != "" {
+		for _, label := range strings.Split(v, ",") {
+			switch label {
+			case "designation":
+				opts.Designation = true
+			case "department":
+				opts.Department = true
+			default:
+				errs = append(errs, FieldError{Field: "labels", Message: fmt.Sprintf("unknown label %q; use designation or department", label)})
+			}
+		}
+	}
+
+	var fieldErr *FieldError
+	if opts.MaxDepth, fieldErr = parseMaxDepth(values.Get("maxDepth")); fieldErr != nil {
+		errs = append(errs, *fieldErr)
+	}
+
+	if len(errs) > 0 {
+		return OrgChartOptions{}, "", NewInvalidQueryError(errs)
+	}
+	return opts, format, nil
+}
+
+// labelLines returns the lines of a node's label: the name, then any labels asked for
+func (n *OrgChartNode) labelLines() []string {
+	lines := []string{n.Name}
+	for _, label := range []string{n.Designation, n.Department} {
+		if label != "" {
+			lines = append(lines, label)
+		}
+	}
+	return lines
+}
+
+// walk calls visit for every node in depth-first order, passing the manager of each
+func (c *OrgChart) walk(visit func(manager, n *OrgChartNode)) {
+	var walk func(manager, n *OrgChartNode)
+	walk = func(manager, n *OrgChartNode) {
+		visit(manager, n)
+		for _, report := range n.Reports {
+			walk(n, report)
+		}
+	}
+	for _, root := range c.Roots {
+		walk(nil, root)
+	}
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// DOT renders the chart as a Graphviz digraph; truncated employees are drawn dashed
+func (c *OrgChart) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph orgchart {\n\tnode [shape=box];\n")
+	c.walk(func(manager, n *OrgChartNode) {
+		lines := n.labelLines()
+		for i, line := range lines {
+			lines[i] = dotEscaper.Replace(line)
+		}
+		style := ""
+		if n.Truncated {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "\te%d [label=\"%s\"%s];\n", n.ID, strings.Join(lines, `\n`), style)
+		if manager != nil {
+			fmt.Fprintf(&b, "\te%d -> e%d;\n", manager.ID, n.ID)
+		}
+	})
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// mermaidEscaper turns the characters Mermaid gives a meaning inside labels into entity codes
+var mermaidEscaper = strings.NewReplacer("#", "#35;", `"`, "#quot;", "<", "#lt;", ">", "#gt;")
+
+// Mermaid renders the chart as a top-down Mermaid flowchart; truncated employees are drawn dashed
+func (c *OrgChart) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	truncated := false
+	c.walk(func(manager, n *OrgChartNode) {
+		lines := n.labelLines()
+		for i, line := range lines {
+			lines[i] = mermaidEscaper.Replace(line)
+		}
+		class := ""
+		if n.Truncated {
+			class, truncated = ":::truncated", true
+		}
+		fmt.Fprintf(&b, "    e%d[\"%s\"]%s\n", n.ID, strings.Join(lines, "<br/>"), class)
+		if manager != nil {
+			fmt.Fprintf(&b, "    e%d --> e%d\n", manager.ID, n.ID)
+		}
+	})
+	if truncated {
+		b.WriteString("    classDef truncated stroke-dasharray: 5 5\n")
+	}
+	return b.String()
+}
+
+// writeOrgChart sends the chart in format to w
+func writeOrgChart(w io.Writer, chart *OrgChart, format string) error {
+	var err error
+	switch format {
+	case OrgChartDOT:
+		_, err = io.WriteString(w, chart.DOT())
+	case OrgChartMermaid:
+		_, err = io.WriteString(w, chart.Mermaid())
+	default:
+		err = json.NewEncoder(w).Encode(chart)
+	}
+	return err
+}
+
+// runOrgChartCommand prints an org chart to out; it takes the parameters of GET /orgchart
+// as flags, e.g. orgchart -root 1 -format dot -labels designation
+func runOrgChartCommand(store EmployeeStore, args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("orgchart", flag.ContinueOnError)
+	root := fs.String("root", "", "ID of the employee the chart is rooted at (default all employees without a manager)")
+	format := fs.String("format", OrgChartJSON, "output format: json, dot or mermaid")
+	labels := fs.String("labels", "", "comma-separated labels to show under each name: designation, department")
+	maxDepth := fs.String("max-depth", "", "levels below the root to draw (default org.maxDepth)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	values := url.Values{}
+	for name, v := range map[string]string{"root": *root, "format": *format, "labels": *labels, "maxDepth": *maxDepth} {
+		if v != "" {
+			values.Set(name, v)
+		}
+	}
+	opts, chartFormat, err := parseOrgChartQuery(values)
+	if err != nil {
+		for _, fieldErr := range toAPIError(err).Errors {
+			fmt.Fprintf(fs.Output(), "Invalid orgchart %s: %s\n", fieldErr.Field, fieldErr.Message)
+		}
+		return 2
+	}
+
+	chart, err := OrgChartAPI(context.Background(), store, opts)
+	if err != nil {
+		fmt.Fprintln(fs.Output(), "Error building the org chart:", err)
+		return 1
+	}
+	if err := writeOrgChart(out, chart, chartFormat); err != nil {
+		fmt.Fprintln(fs.Output(), "Error writing the org chart:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// seedOrgChart creates Cat (CEO, Board) <- Dan (CTO, R&D) <- Eve ("Lead" <Dev>), plus Ann
+// without a manager
+func seedOrgChart(t *testing.T, store EmployeeStore) {
+	t.Helper()
+	ctx := context.Background()
+	board := &Department{Name: "Board"}
+	rnd := &Department{Name: "R&D"}
+	for _, dept := range []*Department{board, rnd} {
+		if err := store.CreateDepartment(ctx, dept); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Managers are created first, so the IDs their reports point at are filled in by then
+	cat := &Employee{Name: "Cat", Designation: "CEO", Currency: "USD", DepartmentID: &board.ID}
+	dan := &Employee{Name: "Dan", Designation: "CTO", Currency: "USD", DepartmentID: &rnd.ID, ManagerID: &cat.ID}
+	eve := &Employee{Name: "Eve", Designation: `"Lead" <Dev>`, Currency: "USD", ManagerID: &dan.ID}
+	ann := &Employee{Name: "Ann", Designation: "Auditor", Currency: "USD"}
+	for _, emp := range []*Employee{cat, dan, eve, ann} {
+		if err := store.CreateEmployee(ctx, emp); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOrgChartFormats(t *testing.T) {
+	stores := map[string]func(t *testing.T) EmployeeStore{
+		"memory": func(t *testing.T) EmployeeStore { return initTestStore(t) },
+		"sqlite": func(t *testing.T) EmployeeStore { return initTestSQLiteStore(t) },
+	}
+	tests := []struct {
+		name            string
+		query           string
+		wantContentType string
+		want            string
+	}{
+		{
+			name:            "DOT of everyone with labels",
+			query:           "format=dot&labels=designation,department",
+			wantContentType: "text/vnd.graphviz; charset=utf-8",
+			want: `digraph orgchart {
+	node [shape=box];
+	e1 [label="Cat\nCEO\nBoard"];
+	e2 [label="Dan\nCTO\nR&D"];
+	e1 -> e2;
+	e3 [label="Eve\n\"Lead\" <Dev>"];
+	e2 -> e3;
+	e4 [label="Ann\nAuditor"];
+}
+`,
+		},
+		{
+			name:            "Mermaid limited to one level",
+			query:           "format=mermaid&root=1&maxDepth=1&labels=designation",
+			wantContentType: "text/plain; charset=utf-8",
+			want: `flowchart TD
+    e1["Cat<br/>CEO"]
+    e2["Dan<br/>CTO"]:::truncated
+    e1 --> e2
+    classDef truncated stroke-dasharray: 5 5
+`,
+		},
+		{
+			name:            "Mermaid escapes labels",
+			query:           "format=mermaid&root=3&labels=designation",
+			wantContentType: "text/plain; charset=utf-8",
+			want: `flowchart TD
+    e3["Eve<br/>#quot;Lead#quot; #lt;Dev#gt;"]
+`,
+		},
+		{
+			name:            "JSON tree",
+			query:           "root=2&labels=department",
+			wantContentType: "application/json",
+			want: `{"roots":[{"id":2,"name":"Dan","department":"R\u0026D","reports":[{"id":3,"name":"Eve","reports":[]}]}],"maxDepth":50}
+`,
+		},
+	}
+	for storeName, newStore := range stores {
+		t.Run(storeName, func(t *testing.T) {
+			store := newStore(t)
+			seedOrgChart(t, store)
+			cr := initTestRouter(t, store)
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					rec := serveTestRequest(cr, httptest.NewRequest("GET", "/orgchart?"+tt.query, nil))
+					if rec.Code != http.StatusOK {
+						t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+					}
+					if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+						t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+					}
+					if got := rec.Body.String(); got != tt.want {
+						t.Errorf("body =\n%s\nwant\n%s", got, tt.want)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestOrgChartQueryErrors(t *testing.T) {
+	cr := initTestRouter(t, initTestStore(t))
+
+	rec := serveTestRequest(cr, httptest.NewRequest("GET", "/orgchart?root=x&format=svg&labels=salary&maxDepth=0", nil))
+	problem := decodeProblem(t, rec)
+	if rec.Code != http.StatusBadRequest || problem.Code != CodeInvalidQuery {
+		t.Fatalf("status = %d, code = %q, want %d %q", rec.Code, problem.Code, http.StatusBadRequest, CodeInvalidQuery)
+	}
+	var fields []string
+	for _, fieldErr := range problem.Errors {
+		fields = append(fields, fieldErr.Field)
+	}
+	if got := strings.Join(fields, ","); got != "root,format,labels,maxDepth" {
+		t.Errorf("invalid fields = %s, want root,format,labels,maxDepth", got)
+	}
+
+	if rec := serveTestRequest(cr, httptest.NewRequest("GET", "/orgchart?root=9", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown root status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestOrgChartCommand(t *testing.T) {
+	store := initTestStore(t)
+	seedOrgChart(t, store)
+
+	var out strings.Builder
+	if code := runOrgChartCommand(store, []string{"-root", "1", "-max-depth", "1"}, &out); code != 0 {
+		t.Fatalf("exit code = %d, want 0", code)
+	}
+	var chart OrgChart
+	if err := json.Unmarshal([]byte(out.String()), &chart); err != nil {
+		t.Fatal(err)
+	}
+	if len(chart.Roots) != 1 || len(chart.Roots[0].Reports) != 1 || !chart.Roots[0].Reports[0].Truncated {
+		t.Errorf("chart = %s", out.String())
+	}
+
+	if code := runOrgChartCommand(store, []string{"-format", "svg"}, &out); code != 2 {
+		t.Errorf("invalid format exit code = %d, want 2", code)
+	}
+}
+
+func TestOrgChartCommandDoesNotMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "employee.db")
+	if code := run([]string{"-store", "sqlite", "-sqlite-path", path, "orgchart"}, testEnv(nil)); code != 1 {
+		t.Errorf("exit code against an unmigrated database = %d, want 1", code)
+	}
+
+	db, err := initSQLiteDB(path)
+	if err != nil {
+		t.Fatalf("initSQLiteDB() error = %v", err)
+	}
+	defer db.Close()
+	migrator, err := NewMigrator(db, SQLiteDialect)
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	if pending, err := migrator.Pending(context.Background()); err != nil || len(pending) != len(migrator.Migrations) {
+		t.Errorf("Pending() = %d migrations, %v, want all %d left pending", len(pending), err, len(migrator.Migrations))
+	}
+}
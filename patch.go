@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// mediaTypeJSONPatch is the content type of an RFC 6902 JSON patch
+const mediaTypeJSONPatch = "application/json-patch+json"
+
+// PatchOperation is a single operation of a JSON patch. Employees are flat objects, so
+// paths name top-level members only.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// decodeJSONPatch reads a JSON patch document; anything but an array of operations is a bad request
+func decodeJSONPatch(body io.Reader) ([]PatchOperation, error) {
+	var ops []PatchOperation
+	if err := json.NewDecoder(body).Decode(&ops); err != nil {
+		return nil, NewBadRequestError(CodeMalformedRequest, "Request body is not a valid JSON patch", err)
+	}
+	if ops == nil {
+		return nil, NewBadRequestError(CodeMalformedRequest, "Request body is not a valid JSON patch", errEmptyBody)
+	}
+	return ops, nil
+}
+
+// NewInvalidPatchError reports a patch operation that cannot be applied to the resource
+func NewInvalidPatchError(i int, op PatchOperation, reason string) *APIError {
+	return &APIError{
+		Status: http.StatusUnprocessableEntity,
+		Code:   CodeInvalidPatch,
+		Title:  "Invalid patch",
+		Detail: fmt.Sprintf("operation %d (%s %s): %s", i, op.Op, op.Path, reason),
+	}
+}
+
+// NewPatchTestFailedError reports a test operation whose value did not match
+func NewPatchTestFailedError(i int, op PatchOperation) *APIError {
+	return &APIError{
+		Status: http.StatusConflict,
+		Code:   CodePatchTestFailed,
+		Title:  "Patch test failed",
+		Detail: fmt.Sprintf("operation %d (test %s): value does not match", i, op.Path),
+	}
+}
+
+// patchMember decodes a JSON pointer naming a top-level member, e.g. "/salary"
+func patchMember(path string) (string, bool) {
+	if !strings.HasPrefix(path, "/") || strings.Contains(path[1:], "/") {
+		return "", false
+	}
+	// Unescape in the order RFC 6901 requires
+	return strings.ReplaceAll(strings.ReplaceAll(path[1:], "~1", "/"), "~0", "~"), true
+}
+
+// applyJSONPatch applies ops to doc in order. Members outside writable may be tested but
+// not changed. Either every operation succeeds or doc is left untouched.
+func applyJSONPatch(doc map[string]json.RawMessage, ops []PatchOperation, writable map[string]employeeField) error {
+	patched := make(map[string]json.RawMessage, len(doc))
+	for k, v := range doc {
+		patched[k] = v
+	}
+
+	for i, op := range ops {
+		member, ok := patchMember(op.Path)
+		if !ok {
+			return NewInvalidPatchError(i, op, "path must name a top-level member")
+		}
+		_, exists := patched[member]
+		_, known := doc[member]
+		if _, ok := writable[member]; !ok && known && op.Op != "test" {
+			return NewInvalidPatchError(i, op, "member is assigned by the server and cannot be changed")
+		}
+
+		switch op.Op {
+		case "add", "replace":
+			if op.Value == nil {
+				return NewInvalidPatchError(i, op, "value is required")
+			}
+			if op.Op == "replace" && !exists {
+				return NewInvalidPatchError(i, op, "path does not exist")
+			}
+			patched[member] = op.Value
+		case "remove":
+			if !exists {
+				return NewInvalidPatchError(i, op, "path does not exist")
+			}
+			delete(patched, member)
+		case "test":
+			if op.Value == nil {
+				return NewInvalidPatchError(i, op, "value is required")
+			}
+			if !exists || !jsonEqual(patched[member], op.Value) {
+				return NewPatchTestFailedError(i, op)
+			}
+		default:
+			return NewInvalidPatchError(i, op, "unsupported operation")
+		}
+	}
+
+	for k := range doc {
+		delete(doc, k)
+	}
+	for k, v := range patched {
+		doc[k] = v
+	}
+	return nil
+}
+
+// jsonEqual compares two JSON values structurally, so formatting and key order do not matter
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// patchedEmployee applies ops to the JSON form of emp and decodes and validates the result
+// as a full replacement
+func patchedEmployee(emp *Employee, ops []PatchOperation) (*Employee, error) {
+	encoded, err := json.Marshal(emp)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return nil, err
+	}
+
+	if err := applyJSONPatch(doc, ops, employeeFields); err != nil {
+		return nil, err
+	}
+
+	// Server-managed members only take part in tests; the replacement is built from the rest
+	for member := range doc {
+		if _, ok := employeeFields[member]; !ok && isEmployeeMember(member) {
+			delete(doc, member)
+		}
+	}
+	encoded, err = json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	patched := newEmployee()
+	if _, err := decodeAndValidate(bytes.NewReader(encoded), patched, false); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+// isEmployeeMember reports whether name is a JSON member of Employee
+func isEmployeeMember(name string) bool {
+	rt := reflect.TypeOf(Employee{})
+	for i := 0; i < rt.NumField(); i++ {
+		if jsonFieldName(rt.Field(i)) == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJSONPatchEmployeeHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   string
+		want       Employee
+	}{
+		{
+			name:       "Replace guarded by a test",
+			body:       `[{"op": "test", "path": "/designation", "value": "Software Developer"}, {"op": "replace", "path": "/salary", "value": 0}]`,
+			wantStatus: http.StatusOK,
+			want:       Employee{ID: 1, Name: "Dan", Designation: "Software Developer", Salary: mustMoney("0"), Currency: "EUR", Version: 2},
+		},
+		{
+			name:       "Failed test applies nothing",
+			body:       `[{"op": "replace", "path": "/salary", "value": 1}, {"op": "test", "path": "/designation", "value": "Lead"}]`,
+			wantStatus: http.StatusConflict,
+			wantCode:   CodePatchTestFailed,
+		},
+		{
+			name:       "Server-managed members can be tested",
+			body:       `[{"op": "test", "path": "/id", "value": 1}, {"op": "add", "path": "/name", "value": "Ben"}]`,
+			wantStatus: http.StatusOK,
+			want:       Employee{ID: 1, Name: "Ben", Designation: "Software Developer", Salary: mustMoney("23456.00"), Currency: "EUR", Version: 2},
+		},
+		{
+			name:       "Removing the currency falls back to the default",
+			body:       `[{"op": "remove", "path": "/currency"}]`,
+			wantStatus: http.StatusOK,
+			want:       Employee{ID: 1, Name: "Dan", Designation: "Software Developer", Salary: mustMoney("23456.00"), Currency: "USD", Version: 2},
+		},
+		{
+			name:       "Server-managed members cannot be changed",
+			body:       `[{"op": "replace", "path": "/id", "value": 2}]`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   CodeInvalidPatch,
+		},
+		{
+			name:       "Removing a required member fails validation",
+			body:       `[{"op": "remove", "path": "/name"}]`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   CodeValidation,
+		},
+		{
+			name:       "Adding an unknown member fails validation",
+			body:       `[{"op": "add", "path": "/bonus", "value": 10}]`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   CodeValidation,
+		},
+		{
+			name:       "Unsupported operation",
+			body:       `[{"op": "move", "from": "/name", "path": "/designation"}]`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   CodeInvalidPatch,
+		},
+		{
+			name:       "Not an array of operations",
+			body:       `{"op": "remove", "path": "/name"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeMalformedRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := initTestStore(t)
+			if err := InsertTableEmployee(store, []Employee{{Name: "Dan", Designation: "Software Developer", Salary: mustMoney("23456.00"), Currency: "EUR"}}); err != nil {
+				t.Fatalf("Unable to insert employee: %v", err)
+			}
+			cr := initTestRouter(t, store)
+
+			req := httptest.NewRequest("PATCH", "/employees/1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", mediaTypeJSONPatch)
+			rec := serveTestRequest(cr, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("PATCH /employees/1 status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			got, err := store.ReadEmployee(context.Background(), 1)
+			if err != nil {
+				t.Fatalf("ReadEmployee() error = %v", err)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if problem := decodeProblem(t, rec); problem.Code != tt.wantCode {
+					t.Errorf("problem code = %q, want %q", problem.Code, tt.wantCode)
+				}
+				// A rejected patch leaves the employee untouched
+				if got.Name != "Dan" || got.Salary != mustMoney("23456.00") {
+					t.Errorf("stored employee = %+v after a rejected patch", got)
+				}
+				return
+			}
+
+			tt.want.CreatedAt, tt.want.UpdatedAt = got.CreatedAt, got.UpdatedAt
+			if *got != tt.want {
+				t.Errorf("stored employee = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listLimits bounds the page size of list endpoints; main replaces it with the configured values
+var listLimits = DefaultListConfig()
+
+// TotalMode selects whether and how a listing counts the matching employees
+type TotalMode int
+
+const (
+	TotalNone TotalMode = iota
+	TotalExact
+	// TotalEstimate uses planner statistics for unfiltered listings where the database keeps
+	// them, and counts exactly otherwise
+	TotalEstimate
+)
+
+// EmployeeFilter narrows an employee listing; zero-valued fields do not filter
+type EmployeeFilter struct {
+	// Designation matches exactly, ignoring case
+	Designation string
+	// The salary range compares amounts as they are, whatever their currency
+	MinSalary *Money
+	MaxSalary *Money
+	// Currency matches the salary currency code exactly
+	Currency string
+	// DepartmentID matches the employees of one department
+	DepartmentID *int
+	// ManagerID matches the direct reports of one employee
+	ManagerID *int
+	// NoManager matches the employees at the top of the organisation
+	NoManager bool
+	// The time ranges are inclusive
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	// NameContains matches a substring of the name, ignoring case
+	NameContains string
+	// Search matches a substring of the name or the designation, ignoring case
+	Search string
+	// AsOf, when set, lists the employees as they were on that date, as employeeAsOf rebuilds
+	// them: those whose history had begun and who had not been deleted yet. The other filters
+	// and the sort apply to those values.
+	AsOf time.Time
+}
+
+// EmployeeSort orders an employee listing by one of employeeSortKeys. Ties are broken by ID
+// so the order is always stable.
+type EmployeeSort struct {
+	Field string
+	Desc  bool
+}
+
+// EmployeeListQuery selects one page of employees
+type EmployeeListQuery struct {
+	Filter EmployeeFilter
+	Sort   EmployeeSort
+	Limit  int
+	// Cursor, when set, starts the page next to a row of a previous page instead of at the start
+	Cursor *EmployeeCursor
+	// Reporting, when set, adds every salary converted into a reporting currency to the page.
+	// Stores ignore it.
+	Reporting *ReportingCurrency
+}
+
+// EmployeeCursor marks a position in a sorted employee listing: the page continues after
+// Last, or before it when Backward is set. Only Last's ID and sort field are used.
+// FromEnd marks the last page of the listing instead, and ignores Last.
+type EmployeeCursor struct {
+	Sort     EmployeeSort
+	Last     Employee
+	Backward bool
+	FromEnd  bool
+}
+
+// cursorJSON is the encoded form of an EmployeeCursor
+type cursorJSON struct {
+	Sort     string          `json:"s"`
+	Desc     bool            `json:"d,omitempty"`
+	Backward bool            `json:"b,omitempty"`
+	FromEnd  bool            `json:"e,omitempty"`
+	Key      json.RawMessage `json:"k,omitempty"`
+}
+
+// errInvalidCursor is reported for cursors that were not issued by this service
+var errInvalidCursor = errors.New("invalid cursor")
+
+// newEmployeeCursor returns the cursor of the page next to emp in the given direction
+func newEmployeeCursor(sort EmployeeSort, emp *Employee, backward bool) *EmployeeCursor {
+	return &EmployeeCursor{Sort: sort, Last: *emp, Backward: backward}
+}
+
+// lastPageCursor returns the cursor of the final page of a listing
+func lastPageCursor(sort EmployeeSort) *EmployeeCursor {
+	return &EmployeeCursor{Sort: sort, Backward: true, FromEnd: true}
+}
+
+// Encode returns the opaque form of the cursor handed to clients
+func (c *EmployeeCursor) Encode() string {
+	field := c.Sort.Field
+	if _, ok := employeeSortKeys[field]; !ok {
+		field = "id"
+	}
+	if c.FromEnd {
+		encoded, _ := json.Marshal(cursorJSON{Sort: field, Desc: c.Sort.Desc, Backward: true, FromEnd: true})
+		return base64.RawURLEncoding.EncodeToString(encoded)
+	}
+
+	// Keep only the members the position depends on
+	encoded, _ := json.Marshal(&c.Last)
+	var members map[string]json.RawMessage
+	json.Unmarshal(encoded, &members)
+	key, _ := json.Marshal(map[string]json.RawMessage{"id": members["id"], field: members[field]})
+
+	encoded, _ = json.Marshal(cursorJSON{Sort: field, Desc: c.Sort.Desc, Backward: c.Backward, Key: key})
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeEmployeeCursor parses a cursor produced by Encode
+func decodeEmployeeCursor(s string) (*EmployeeCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var c cursorJSON
+	if err := json.Unmarshal(decoded, &c); err != nil {
+		return nil, errInvalidCursor
+	}
+	if _, ok := employeeSortKeys[c.Sort]; !ok {
+		return nil, errInvalidCursor
+	}
+
+	cursor := &EmployeeCursor{Sort: EmployeeSort{Field: c.Sort, Desc: c.Desc}, Backward: c.Backward || c.FromEnd, FromEnd: c.FromEnd}
+	if c.FromEnd {
+		return cursor, nil
+	}
+	if err := json.Unmarshal(c.Key, &cursor.Last); err != nil {
+		return nil, errInvalidCursor
+	}
+	return cursor, nil
+}
+
+// EmployeePage is one page of an employee listing. The cursors fetch the neighbouring pages
+// and are empty when there is nothing in that direction.
+type EmployeePage struct {
+	Items      []Employee `json:"items"`
+	Limit      int        `json:"limit"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+	PrevCursor string     `json:"prevCursor,omitempty"`
+	// Total counts every employee matching the filter, when it was asked for
+	Total          *int `json:"total,omitempty"`
+	TotalEstimated bool `json:"totalEstimated,omitempty"`
+	// ReportingCurrency says what the items' reportingSalary was converted into
+	ReportingCurrency string `json:"reportingCurrency,omitempty"`
+	// AsOf is the date the items were read as of, or whose rates converted them
+	AsOf string `json:"asOf,omitempty"`
+}
+
+// employeeSortKey is a field employee listings can be sorted by
+type employeeSortKey struct {
+	Column  string
+	Value   func(emp *Employee) any
+	Compare func(a, b *Employee) int
+}
+
+// employeeSortKeys whitelists the sort fields by their JSON name
+var employeeSortKeys = map[string]employeeSortKey{
+	"id": {
+		Column:  "ID",
+		Value:   func(emp *Employee) any { return emp.ID },
+		Compare: func(a, b *Employee) int { return cmp.Compare(a.ID, b.ID) },
+	},
+	"name": {
+		Column:  "Name",
+		Value:   func(emp *Employee) any { return emp.Name },
+		Compare: func(a, b *Employee) int { return strings.Compare(a.Name, b.Name) },
+	},
+	"designation": {
+		Column:  "Designation",
+		Value:   func(emp *Employee) any { return emp.Designation },
+		Compare: func(a, b *Employee) int { return strings.Compare(a.Designation, b.Designation) },
+	},
+	"salary": {
+		Column:  "Salary",
+		Value:   func(emp *Employee) any { return emp.Salary },
+		Compare: func(a, b *Employee) int { return a.Salary.Cmp(b.Salary) },
+	},
+	"createdAt": {
+		Column:  "CreatedAt",
+		Value:   func(emp *Employee) any { return emp.CreatedAt },
+		Compare: func(a, b *Employee) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	},
+	"updatedAt": {
+		Column:  "UpdatedAt",
+		Value:   func(emp *Employee) any { return emp.UpdatedAt },
+		Compare: func(a, b *Employee) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+	},
+}
+
+// String returns the sort in the form of the sort parameter, e.g. "-salary"
+func (s EmployeeSort) String() string {
+	field := s.Field
+	if field == "" {
+		field = "id"
+	}
+	if s.Desc {
+		return "-" + field
+	}
+	return field
+}
+
+// key returns the sort key for Field, ID when none is set
+func (s EmployeeSort) key() employeeSortKey {
+	if key, ok := employeeSortKeys[s.Field]; ok {
+		return key
+	}
+	return employeeSortKeys["id"]
+}
+
+// compareEmployees orders a and b as the sort asks, breaking ties by ID
+func (s EmployeeSort) compareEmployees(a, b *Employee) int {
+	c := s.key().Compare(a, b)
+	if c == 0 {
+		c = cmp.Compare(a.ID, b.ID)
+	}
+	if s.Desc {
+		return -c
+	}
+	return c
+}
+
+// beyondCursor reports whether emp lies on the far side of the cursor, in the direction it points
+func (q EmployeeListQuery) beyondCursor(emp *Employee) bool {
+	if q.Cursor == nil || q.Cursor.FromEnd {
+		return true
+	}
+	c := q.Sort.compareEmployees(emp, &q.Cursor.Last)
+	if q.Cursor.Backward {
+		return c < 0
+	}
+	return c > 0
+}
+
+// Matches reports whether emp passes every filter that is set
+func (f EmployeeFilter) Matches(emp *Employee) bool {
+	contains := func(s, substr string) bool {
+		return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+	}
+	switch {
+	case f.Designation != "" && !strings.EqualFold(emp.Designation, f.Designation),
+		f.MinSalary != nil && emp.Salary.Cmp(*f.MinSalary) < 0,
+		f.MaxSalary != nil && emp.Salary.Cmp(*f.MaxSalary) > 0,
+		f.Currency != "" && emp.Currency != f.Currency,
+		f.DepartmentID != nil && (emp.DepartmentID == nil || *emp.DepartmentID != *f.DepartmentID),
+		f.ManagerID != nil && (emp.ManagerID == nil || *emp.ManagerID != *f.ManagerID),
+		f.NoManager && emp.ManagerID != nil,
+		!f.CreatedAfter.IsZero() && emp.CreatedAt.Before(f.CreatedAfter),
+		!f.CreatedBefore.IsZero() && emp.CreatedAt.After(f.CreatedBefore),
+		!f.UpdatedAfter.IsZero() && emp.UpdatedAt.Before(f.UpdatedAfter),
+		!f.UpdatedBefore.IsZero() && emp.UpdatedAt.After(f.UpdatedBefore),
+		f.NameContains != "" && !contains(emp.Name, f.NameContains),
+		f.Search != "" && !contains(emp.Name, f.Search) && !contains(emp.Designation, f.Search):
+		return false
+	}
+	return true
+}
+
+// NewInvalidQueryError reports query parameters that could not be understood
+func NewInvalidQueryError(errs []FieldError) *APIError {
+	return &APIError{
+		Status: http.StatusBadRequest,
+		Code:   CodeInvalidQuery,
+		Title:  "Invalid query",
+		Detail: fmt.Sprintf("%d invalid query parameters", len(errs)),
+		Errors: errs,
+	}
+}
+
+// parseEmployeeListQuery reads a page request of an employee listing: the filter and sort
+// parameters described at parseEmployeeFilter, plus
+//
+//	limit=10          page size, 1 to listLimits.MaxLimit
+//	cursor=...        nextCursor or prevCursor of a previous page, continuing its sort order
+//	total=true        also count every matching employee; total=estimate allows an estimate
+//	reportingCurrency=EUR
+//	                  also convert every salary into EUR at the rates effective on asOf,
+//	                  or today
+func parseEmployeeListQuery(values url.Values) (EmployeeListQuery, TotalMode, error) {
+	filter, sort, err := parseEmployeeFilter(values)
+	if err != nil {
+		return EmployeeListQuery{}, TotalNone, err
+	}
+	q := EmployeeListQuery{Filter: filter, Sort: sort, Limit: listLimits.DefaultLimit}
+
+	var errs []FieldError
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > listLimits.MaxLimit {
+			errs = append(errs, FieldError{Field: "limit", Message: fmt.Sprintf("must be a number from 1 to %d", listLimits.MaxLimit)})
+		}
+		q.Limit = limit
+	}
+	if v := values.Get("cursor"); v != "" {
+		cursor, err := decodeEmployeeCursor(v)
+		switch {
+		case err != nil:
+			errs = append(errs, FieldError{Field: "cursor", Message: "is not a cursor returned by this service"})
+		case values.Get("sort") != "" && cursor.Sort != sort:
+			errs = append(errs, FieldError{Field: "cursor", Message: "was issued for a different sort order"})
+		default:
+			q.Cursor = cursor
+			q.Sort = cursor.Sort
+		}
+	}
+
+	total := TotalNone
+	switch v := values.Get("total"); v {
+	case "", "false":
+	case "true", "exact":
+		total = TotalExact
+	case "estimate":
+		total = TotalEstimate
+	default:
+		errs = append(errs, FieldError{Field: "total", Message: "must be true, exact, estimate or false"})
+	}
+	q.Reporting = parseReportingCurrency(values, filter.AsOf, &errs)
+
+	if len(errs) > 0 {
+		return EmployeeListQuery{}, TotalNone, NewInvalidQueryError(errs)
+	}
+	return q, total, nil
+}
+
+// pageLinks builds the RFC 8288 Link header of a listing page. The links keep the request's
+// other parameters and spell out the sort, which a cursor may have implied.
+func pageLinks(u *url.URL, q EmployeeListQuery, page *EmployeePage) string {
+	link := func(rel string, cursor string) string {
+		values := u.Query()
+		values.Del("cursor")
+		values.Set("limit", strconv.Itoa(q.Limit))
+		values.Set("sort", q.Sort.String())
+		if cursor != "" {
+			values.Set("cursor", cursor)
+		}
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, u.Path, values.Encode(), rel)
+	}
+
+	links := []string{link("first", "")}
+	if page.PrevCursor != "" {
+		links = append(links, link("prev", page.PrevCursor))
+	}
+	if page.NextCursor != "" {
+		links = append(links, link("next", page.NextCursor))
+	}
+	links = append(links, link("last", lastPageCursor(q.Sort).Encode()))
+	return strings.Join(links, ", ")
+}
+
+// parseEmployeeFilter reads the filter and sort parameters of an employee listing:
+//
+//	designation=Engineer        exact designation, ignoring case
+//	minSalary=60000&maxSalary=  inclusive salary range, in each salary's own currency
+//	currency=EUR                salary currency, ignoring case
+//	department=3                department ID
+//	manager=7                   direct reports of an employee ID; manager=none for
+//	  employees without a manager
+//	createdAfter, createdBefore inclusive RFC 3339 time ranges, likewise updatedAfter
+//	  and updatedBefore
+//	name=jo                     name contains, ignoring case
+//	q=dev                       name or designation contains, ignoring case
+//	asOf=2006-01-02             the employees as they were on that date; an RFC 3339
+//	  timestamp stands for its UTC day
+//	sort=-salary                one of employeeSortKeys; a leading - sorts descending
+//
+// Every invalid parameter is reported at once.
+func parseEmployeeFilter(values url.Values) (EmployeeFilter, EmployeeSort, error) {
+	var errs []FieldError
+	filter := EmployeeFilter{
+		Designation:  values.Get("designation"),
+		NameContains: values.Get("name"),
+		Search:       values.Get("q"),
+	}
+
+	salary := func(param string) *Money {
+		v := values.Get(param)
+		if v == "" {
+			return nil
+		}
+		m, err := ParseMoney(v)
+		if err != nil {
+			errs = append(errs, FieldError{Field: param, Message: err.Error()})
+			return nil
+		}
+		return &m
+	}
+	filter.MinSalary = salary("minSalary")
+	filter.MaxSalary = salary("maxSalary")
+
+	if v := values.Get("currency"); v != "" {
+		filter.Currency = strings.ToUpper(v)
+		if !validCurrency(filter.Currency) {
+			errs = append(errs, FieldError{Field: "currency", Message: "must be an ISO 4217 currency code"})
+		}
+	}
+
+	if v := values.Get("department"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, FieldError{Field: "department", Message: "must be a department ID"})
+		}
+		filter.DepartmentID = &id
+	}
+
+	if v := values.Get("manager"); v == "none" {
+		filter.NoManager = true
+	} else if v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, FieldError{Field: "manager", Message: "must be an employee ID"})
+		}
+		filter.ManagerID = &id
+	}
+
+	timestamp := func(param string) time.Time {
+		v := values.Get(param)
+		if v == "" {
+			return time.Time{}
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			errs = append(errs, FieldError{Field: param, Message: "must be an RFC 3339 timestamp"})
+			return time.Time{}
+		}
+		// Timestamps are stored in UTC
+		return t.UTC()
+	}
+	filter.CreatedAfter = timestamp("createdAfter")
+	filter.CreatedBefore = timestamp("createdBefore")
+	filter.UpdatedAfter = timestamp("updatedAfter")
+	filter.UpdatedBefore = timestamp("updatedBefore")
+
+	var fieldErr *FieldError
+	if filter.AsOf, fieldErr = parseAsOf(values.Get("asOf")); fieldErr != nil {
+		errs = append(errs, *fieldErr)
+	}
+
+	sort := EmployeeSort{Field: "id"}
+	if v := values.Get("sort"); v != "" {
+		sort.Desc = strings.HasPrefix(v, "-")
+		sort.Field = strings.TrimPrefix(v, "-")
+		if _, ok := employeeSortKeys[sort.Field]; !ok {
+			errs = append(errs, FieldError{Field: "sort", Message: "must be one of id, name, designation, salary, createdAt, updatedAt, optionally prefixed with -"})
+		}
+	}
+
+	if len(errs) > 0 {
+		return EmployeeFilter{}, EmployeeSort{}, NewInvalidQueryError(errs)
+	}
+	return filter, sort, nil
+}
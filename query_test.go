@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestEmployeeListFilters(t *testing.T) {
+	stores := map[string]func(t *testing.T) EmployeeStore{
+		"memory": func(t *testing.T) EmployeeStore { return initTestStore(t) },
+		"sqlite": func(t *testing.T) EmployeeStore { return initTestSQLiteStore(t) },
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantIDs    []int
+		wantStatus int
+	}{
+		{name: "No filters", query: "", wantIDs: []int{1, 2, 3, 4}},
+		{name: "Designation ignores case", query: "designation=engineer", wantIDs: []int{1, 3}},
+		{name: "Salary range", query: "minSalary=50000&maxSalary=70000", wantIDs: []int{1, 2}},
+		{name: "Engineers over 60k by name", query: "designation=Engineer&minSalary=60000&sort=name", wantIDs: []int{3, 1}},
+		{name: "Name contains", query: "name=AN", wantIDs: []int{1, 2}},
+		{name: "Free-text search", query: "q=dev", wantIDs: []int{2, 4}},
+		{name: "Sort descending", query: "sort=-salary", wantIDs: []int{3, 1, 2, 4}},
+		{name: "Ties broken by ID", query: "sort=designation", wantIDs: []int{1, 3, 2, 4}},
+		{name: "Created range", query: "createdAfter=2000-01-01T00:00:00Z&createdBefore=2999-01-01T00:00:00%2B05:30", wantIDs: []int{1, 2, 3, 4}},
+		{name: "Invalid parameters", query: "minSalary=lots&createdAfter=yesterday&sort=bonus", wantStatus: http.StatusBadRequest},
+	}
+	for storeName, newStore := range stores {
+		t.Run(storeName, func(t *testing.T) {
+			store := newStore(t)
+			employees := []Employee{
+				{Name: "Dan", Designation: "Engineer", Salary: mustMoney("60000")},
+				{Name: "Ann", Designation: "Software Developer", Salary: mustMoney("50000")},
+				{Name: "Ben", Designation: "Engineer", Salary: mustMoney("80000")},
+				{Name: "Cid", Designation: "Software Developer", Salary: mustMoney("40000")},
+			}
+			if err := InsertTableEmployee(store, employees); err != nil {
+				t.Fatalf("Unable to insert employees: %v", err)
+			}
+			cr := initTestRouter(t, store)
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					rec := serveTestRequest(cr, httptest.NewRequest("GET", "/employeeList?"+tt.query, nil))
+					if tt.wantStatus == http.StatusBadRequest {
+						if rec.Code != tt.wantStatus {
+							t.Fatalf("GET /employeeList?%s status = %d, want %d", tt.query, rec.Code, tt.wantStatus)
+						}
+						if problem := decodeProblem(t, rec); problem.Code != CodeInvalidQuery || len(problem.Errors) != 3 {
+							t.Errorf("problem = %+v, want 3 invalid-query errors", problem)
+						}
+						return
+					}
+					if rec.Code != http.StatusOK {
+						t.Fatalf("GET /employeeList?%s status = %d, want %d", tt.query, rec.Code, http.StatusOK)
+					}
+
+					var got EmployeePage
+					if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+						t.Fatalf("Unable to decode employees: %v", err)
+					}
+					var ids []int
+					for _, emp := range got.Items {
+						ids = append(ids, emp.ID)
+					}
+					if !reflect.DeepEqual(ids, tt.wantIDs) {
+						t.Errorf("GET /employeeList?%s IDs = %v, want %v", tt.query, ids, tt.wantIDs)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestLikePatternEscapesWildcards(t *testing.T) {
+	if got := likePattern(`50%_Off\`); got != `%50\%\_off\\%` {
+		t.Errorf("likePattern() = %q", got)
+	}
+}
+
+// listPage fetches one page of the employee listing through the router
+func listPage(t *testing.T, cr *CustomRouter, query string) EmployeePage {
+	t.Helper()
+	rec := serveTestRequest(cr, httptest.NewRequest("GET", "/employees?"+query, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /employees?%s status = %d, want %d", query, rec.Code, http.StatusOK)
+	}
+	var page EmployeePage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("Unable to decode page: %v", err)
+	}
+	return page
+}
+
+func pageIDs(page EmployeePage) []int {
+	ids := []int{}
+	for _, emp := range page.Items {
+		ids = append(ids, emp.ID)
+	}
+	return ids
+}
+
+func TestEmployeeListCursorPagination(t *testing.T) {
+	stores := map[string]func(t *testing.T) EmployeeStore{
+		"memory": func(t *testing.T) EmployeeStore { return initTestStore(t) },
+		"sqlite": func(t *testing.T) EmployeeStore { return initTestSQLiteStore(t) },
+	}
+	for storeName, newStore := range stores {
+		t.Run(storeName, func(t *testing.T) {
+			store := newStore(t)
+			employees := []Employee{
+				{Name: "Dan", Designation: "Engineer", Salary: mustMoney("60000")},
+				{Name: "Ann", Designation: "Software Developer", Salary: mustMoney("50000")},
+				{Name: "Ben", Designation: "Engineer", Salary: mustMoney("80000")},
+				{Name: "Cid", Designation: "Software Developer", Salary: mustMoney("40000")},
+				{Name: "Eve", Designation: "Engineer", Salary: mustMoney("70000")},
+			}
+			if err := InsertTableEmployee(store, employees); err != nil {
+				t.Fatalf("Unable to insert employees: %v", err)
+			}
+			cr := initTestRouter(t, store)
+
+			// Forward through a sort with ties, counting the matches once
+			first := listPage(t, cr, "sort=-designation&limit=2&total=true")
+			if got := pageIDs(first); !reflect.DeepEqual(got, []int{4, 2}) || first.PrevCursor != "" || first.Total == nil || *first.Total != 5 {
+				t.Fatalf("first page = %v, prev %q, total %v", got, first.PrevCursor, first.Total)
+			}
+
+			// Rows inserted before the cursor do not shift the following pages
+			if err := InsertTableEmployee(store, []Employee{{Name: "Fay", Designation: "Software Developer", Salary: mustMoney("1")}}); err != nil {
+				t.Fatalf("Unable to insert employee: %v", err)
+			}
+
+			second := listPage(t, cr, "limit=2&cursor="+first.NextCursor)
+			if got := pageIDs(second); !reflect.DeepEqual(got, []int{5, 3}) || second.Total != nil {
+				t.Fatalf("second page = %v, total %v", got, second.Total)
+			}
+			third := listPage(t, cr, "limit=2&cursor="+second.NextCursor)
+			if got := pageIDs(third); !reflect.DeepEqual(got, []int{1}) || third.NextCursor != "" {
+				t.Fatalf("third page = %v, next %q", got, third.NextCursor)
+			}
+
+			// And back again
+			back := listPage(t, cr, "limit=2&cursor="+third.PrevCursor)
+			if got := pageIDs(back); !reflect.DeepEqual(got, []int{5, 3}) || back.NextCursor == "" || back.PrevCursor == "" {
+				t.Fatalf("page before the third = %v, next %q, prev %q", got, back.NextCursor, back.PrevCursor)
+			}
+			again := listPage(t, cr, "limit=2&cursor="+back.PrevCursor)
+			if got := pageIDs(again); !reflect.DeepEqual(got, []int{4, 2}) || again.PrevCursor == "" {
+				t.Fatalf("page before the second = %v, prev %q", got, again.PrevCursor)
+			}
+
+			// The row inserted earlier shows up at the new start
+			start := listPage(t, cr, "limit=2&cursor="+again.PrevCursor)
+			if got := pageIDs(start); !reflect.DeepEqual(got, []int{6}) || start.PrevCursor != "" || start.NextCursor == "" {
+				t.Fatalf("first page again = %v, next %q, prev %q", got, start.NextCursor, start.PrevCursor)
+			}
+
+			// Timestamps survive the round trip through a cursor
+			var walked []int
+			for cursor, pages := "", 0; pages == 0 || cursor != ""; pages++ {
+				page := listPage(t, cr, "sort=-createdAt&limit=4&cursor="+cursor)
+				walked = append(walked, pageIDs(page)...)
+				cursor = page.NextCursor
+			}
+			if !reflect.DeepEqual(walked, []int{6, 5, 4, 3, 2, 1}) {
+				t.Errorf("walk by -createdAt = %v", walked)
+			}
+
+			// An empty result is a page too
+			empty := listPage(t, cr, "designation=Pilot")
+			if empty.Items == nil || len(empty.Items) != 0 || empty.NextCursor != "" {
+				t.Errorf("empty page = %+v", empty)
+			}
+		})
+	}
+}
+
+func TestEmployeeListCursorErrors(t *testing.T) {
+	cr := initTestRouter(t, initTestStore(t))
+	next := (&EmployeeCursor{Sort: EmployeeSort{Field: "name"}, Last: Employee{ID: 1, Name: "Dan"}}).Encode()
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "Garbage cursor", query: "cursor=not-a-cursor"},
+		{name: "Cursor for another sort", query: "sort=-salary&cursor=" + next},
+		{name: "Limit out of range", query: "limit=1000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveTestRequest(cr, httptest.NewRequest("GET", "/employees?"+tt.query, nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("GET /employees?%s status = %d, want %d", tt.query, rec.Code, http.StatusBadRequest)
+			}
+			if problem := decodeProblem(t, rec); problem.Code != CodeInvalidQuery {
+				t.Errorf("problem code = %q, want %q", problem.Code, CodeInvalidQuery)
+			}
+		})
+	}
+}
+
+func TestEmployeeListLinks(t *testing.T) {
+	defer func(limits ListConfig) { listLimits = limits }(listLimits)
+	listLimits = ListConfig{DefaultLimit: 2, MaxLimit: 3}
+
+	store := initTestStore(t)
+	employees := []Employee{
+		{Name: "Dan", Designation: "Engineer", Salary: mustMoney("60000")},
+		{Name: "Ann", Designation: "Engineer", Salary: mustMoney("50000")},
+		{Name: "Ben", Designation: "Engineer", Salary: mustMoney("80000")},
+		{Name: "Cid", Designation: "Engineer", Salary: mustMoney("40000")},
+		{Name: "Eve", Designation: "Engineer", Salary: mustMoney("70000")},
+	}
+	if err := InsertTableEmployee(store, employees); err != nil {
+		t.Fatalf("Unable to insert employees: %v", err)
+	}
+	cr := initTestRouter(t, store)
+
+	links := func(rec *httptest.ResponseRecorder) map[string]string {
+		found := make(map[string]string)
+		for _, match := range regexp.MustCompile(`<([^>]*)>; rel="(\w+)"`).FindAllStringSubmatch(rec.Header().Get("Link"), -1) {
+			found[match[2]] = match[1]
+		}
+		return found
+	}
+
+	// The first page links forward and to both ends, and echoes the default limit
+	rec := serveTestRequest(cr, httptest.NewRequest("GET", "/employees?sort=-salary&designation=Engineer&total=estimate", nil))
+	var page EmployeePage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("Unable to decode page: %v", err)
+	}
+	if page.Limit != 2 || page.Total == nil || *page.Total != 5 || page.TotalEstimated {
+		t.Errorf("page = %+v, want limit 2 and an exact total of 5", page)
+	}
+	first := links(rec)
+	if _, ok := first["prev"]; ok || first["next"] == "" || first["first"] == "" || first["last"] == "" {
+		t.Fatalf("Link = %q", rec.Header().Get("Link"))
+	}
+	if !strings.Contains(first["next"], "designation=Engineer") || !strings.Contains(first["next"], "sort=-salary") {
+		t.Errorf("next link %q lost the request's parameters", first["next"])
+	}
+
+	// Following last lands on the final page, which links back but not forward
+	rec = serveTestRequest(cr, httptest.NewRequest("GET", first["last"], nil))
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("Unable to decode page: %v", err)
+	}
+	if got := pageIDs(page); !reflect.DeepEqual(got, []int{2, 4}) {
+		t.Errorf("last page = %v, want [2 4]", got)
+	}
+	last := links(rec)
+	if _, ok := last["next"]; ok || last["prev"] == "" {
+		t.Fatalf("Link on the last page = %q", rec.Header().Get("Link"))
+	}
+
+	// Which leads back to the middle
+	rec = serveTestRequest(cr, httptest.NewRequest("GET", last["prev"], nil))
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("Unable to decode page: %v", err)
+	}
+	if got := pageIDs(page); !reflect.DeepEqual(got, []int{5, 1}) {
+		t.Errorf("middle page = %v, want [5 1]", got)
+	}
+
+	// Limits above the configured maximum are refused
+	if rec := serveTestRequest(cr, httptest.NewRequest("GET", "/employees?limit=4", nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /employees?limit=4 status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
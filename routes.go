@@ -2,9 +2,40 @@ package main
 
 func (cr *CustomRouter) SetupRouter() {
 
-	cr.HandleFunc("/employees", CreateEmployeeHandler(cr.DB)).Methods("POST")
-	cr.HandleFunc("/employees/{id}", ReadEmployeeHandler(cr.DB)).Methods("GET")
-	cr.HandleFunc("/employeeList", ReadEmployeeListHandler(cr.DB)).Methods("GET")
-	cr.HandleFunc("/employees/{id}", UpdateEmployeeHandler(cr.DB)).Methods("PUT")
-	cr.HandleFunc("/employees/{id}", DeleteEmployeeHandler(cr.DB)).Methods("DELETE")
+	cr.Use(RequestIDMiddleware)
+	cr.Use(AuditMiddleware)
+	cr.NotFoundHandler = RequestIDMiddleware(NotFoundHandler())
+	cr.MethodNotAllowedHandler = RequestIDMiddleware(MethodNotAllowedHandler())
+
+	cr.HandleFunc("/employees", CreateEmployeeHandler(cr.Store)).Methods("POST")
+	cr.HandleFunc("/employees/salary-summary", SalarySummaryHandler(cr.Store)).Methods("GET")
+	cr.HandleFunc("/employees/{id}", ReadEmployeeHandler(cr.Store)).Methods("GET")
+	cr.HandleFunc("/employees", ReadEmployeeListHandler(cr.Store)).Methods("GET")
+	cr.HandleFunc("/employeeList", ReadEmployeeListHandler(cr.Store)).Methods("GET")
+	cr.HandleFunc("/employees/{id}", UpdateEmployeeHandler(cr.Store)).Methods("PUT")
+	cr.HandleFunc("/employees/{id}", PatchEmployeeHandler(cr.Store)).Methods("PATCH")
+	cr.HandleFunc("/employees/{id}", DeleteEmployeeHandler(cr.Store)).Methods("DELETE")
+	cr.HandleFunc("/employees/{id}/history", ReadEmployeeHistoryHandler(cr.Store)).Methods("GET")
+	cr.HandleFunc("/employees/{id}/reports", ReadReportsHandler(cr.Store)).Methods("GET")
+	cr.HandleFunc("/employees/{id}/subordinates", ReadSubordinatesHandler(cr.Store)).Methods("GET")
+	cr.HandleFunc("/employees/{id}/managers", ReadManagerChainHandler(cr.Store)).Methods("GET")
+
+	cr.HandleFunc("/orgchart", OrgChartHandler(cr.Store)).Methods("GET")
+
+	cr.HandleFunc("/departments", CreateDepartmentHandler(cr.Store)).Methods("POST")
+	cr.HandleFunc("/departments", ReadDepartmentListHandler(cr.Store)).Methods("GET")
+	cr.HandleFunc("/departments/{id}", ReadDepartmentHandler(cr.Store)).Methods("GET")
+	cr.HandleFunc("/departments/{id}", UpdateDepartmentHandler(cr.Store)).Methods("PUT")
+	cr.HandleFunc("/departments/{id}", DeleteDepartmentHandler(cr.Store)).Methods("DELETE")
+
+	cr.HandleFunc("/exchange-rates", ReadExchangeRatesHandler(cr.Store)).Methods("GET")
+	cr.HandleFunc("/exchange-rates/{from}/{to}", ReadEffectiveExchangeRateHandler(cr.Store)).Methods("GET")
+	cr.HandleFunc("/exchange-rates/{from}/{to}/{date}", PutExchangeRateHandler(cr.Store)).Methods("PUT")
+	cr.HandleFunc("/exchange-rates/{from}/{to}/{date}", DeleteExchangeRateHandler(cr.Store)).Methods("DELETE")
+
+	cr.Handle("/admin/audit", AdminMiddleware(ReadAuditLogHandler(cr.Store))).Methods("GET")
+	cr.Handle("/admin/audit/verify", AdminMiddleware(VerifyAuditLogHandler(cr.Store))).Methods("GET")
+
+	cr.HandleFunc("/healthz", HealthzHandler()).Methods("GET")
+	cr.HandleFunc("/readyz", ReadyzHandler(cr.Store, cr.Migrator, &cr.ShuttingDown)).Methods("GET")
 }
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// initSQLiteDB opens the single-file SQLite database at path
+func initSQLiteDB(path string) (*sql.DB, error) {
+	// Enforce foreign keys, wait on locks instead of failing immediately, and take the write
+	// lock when a transaction begins so other processes cannot write in between
+	connStr := fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000&_txlock=immediate", path)
+
+	db, err := sql.Open(SQLiteDialect.Name(), connStr)
+	if err != nil {
+		return nil, fmt.Errorf("opening the SQLite database: %w", err)
+	}
+
+	// SQLite allows a single writer, so serialise access through one connection
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("testing the SQLite database connection: %w", err)
+	}
+
+	fmt.Printf("Successfully opened SQLite database %s!\n", path)
+
+	return db, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Helper function to open a throwaway SQLite database
+func initTestSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := initSQLiteDB(filepath.Join(t.TempDir(), "employee.db"))
+	if err != nil {
+		t.Fatalf("initSQLiteDB() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// Helper function to open a throwaway, fully migrated SQLite-backed store
+func initTestSQLiteStore(t *testing.T) *SQLEmployeeStore {
+	t.Helper()
+	db := initTestSQLiteDB(t)
+	migrator, err := NewMigrator(db, SQLiteDialect)
+	if err != nil {
+		t.Fatalf("Unable to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 0); err != nil {
+		t.Fatalf("Unable to apply migrations: %v", err)
+	}
+	return NewSQLiteEmployeeStore(db)
+}
+
+func TestRunReportsDatabaseErrors(t *testing.T) {
+	// The directory of the database file does not exist, so it cannot be opened
+	path := filepath.Join(t.TempDir(), "missing", "employee.db")
+	if code := run([]string{"-store", "sqlite", "-sqlite-path", path}, testEnv(nil)); code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+}
+
+func TestSQLiteEmployeeStore(t *testing.T) {
+	store := initTestSQLiteStore(t)
+
+	// Create
+	employees := []Employee{
+		{Name: "Sen", Designation: "Account Manager", Salary: mustMoney("44566.00")},
+		{Name: "Dan", Designation: "Software Developer", Salary: mustMoney("23456.00")},
+	}
+	if err := InsertTableEmployee(store, employees); err != nil {
+		t.Fatalf("Unable to insert employees: %v", err)
+	}
+	if employees[0].ID != 1 || employees[1].ID != 2 {
+		t.Fatalf("CreateEmployee() IDs = %d, %d, want 1, 2", employees[0].ID, employees[1].ID)
+	}
+
+	// Read
+	got, err := ReadEmployeeAPI(context.Background(), store, employees[1].ID)
+	if err != nil {
+		t.Fatalf("ReadEmployeeAPI() error = %v", err)
+	}
+	if got.Name != "Dan" || got.Salary != mustMoney("23456.00") || got.CreatedAt.IsZero() {
+		t.Errorf("ReadEmployeeAPI() = %v", got)
+	}
+	if _, err := ReadEmployeeAPI(context.Background(), store, 3); err != ErrEmployeeNotFound {
+		t.Errorf("ReadEmployeeAPI() with invalid id error = %v, want %v", err, ErrEmployeeNotFound)
+	}
+
+	// List
+	first, err := ReadEmployeeListAPI(context.Background(), store, EmployeeListQuery{Limit: 1}, TotalExact)
+	if err != nil {
+		t.Fatalf("ReadEmployeeListAPI() error = %v", err)
+	}
+	if len(first.Items) != 1 || first.NextCursor == "" || first.PrevCursor != "" || *first.Total != 2 {
+		t.Fatalf("ReadEmployeeListAPI() = %+v", first)
+	}
+	cursor, err := decodeEmployeeCursor(first.NextCursor)
+	if err != nil {
+		t.Fatalf("decodeEmployeeCursor() error = %v", err)
+	}
+	list, err := ReadEmployeeListAPI(context.Background(), store, EmployeeListQuery{Limit: 1, Cursor: cursor}, TotalNone)
+	if err != nil {
+		t.Fatalf("ReadEmployeeListAPI() error = %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].ID != employees[1].ID || list.NextCursor != "" {
+		t.Errorf("ReadEmployeeListAPI() = %+v", list)
+	}
+
+	// Update
+	updated, err := UpdateEmployeeAPI(context.Background(), store, employees[0].ID, &Employee{Name: "Sen", Designation: "Lead", Salary: mustMoney("50000")}, 0, time.Time{})
+	if err != nil {
+		t.Fatalf("UpdateEmployeeAPI() error = %v", err)
+	}
+	if updated.Designation != "Lead" || updated.Salary != mustMoney("50000") {
+		t.Errorf("UpdateEmployeeAPI() = %v", updated)
+	}
+
+	// Patch, including a zero value
+	patched, err := PatchEmployeeAPI(context.Background(), store, employees[0].ID, &Employee{Salary: mustMoney("0")}, []string{"salary"}, 0, time.Time{})
+	if err != nil {
+		t.Fatalf("PatchEmployeeAPI() error = %v", err)
+	}
+	if patched.Name != "Sen" || patched.Designation != "Lead" || patched.Salary != (Money{}) {
+		t.Errorf("PatchEmployeeAPI() = %v", patched)
+	}
+
+	// Conditional writes
+	if _, err := UpdateEmployeeAPI(context.Background(), store, employees[0].ID, &Employee{Name: "Sen", Designation: "Lead"}, 1, time.Time{}); err != ErrVersionMismatch {
+		t.Errorf("UpdateEmployeeAPI() with a stale version error = %v, want %v", err, ErrVersionMismatch)
+	}
+	if err := DeleteEmployeeAPI(context.Background(), store, employees[0].ID, 1); err != ErrVersionMismatch {
+		t.Errorf("DeleteEmployeeAPI() with a stale version error = %v, want %v", err, ErrVersionMismatch)
+	}
+
+	// Delete
+	if err := DeleteEmployeeAPI(context.Background(), store, employees[0].ID, patched.Version); err != nil {
+		t.Fatalf("DeleteEmployeeAPI() error = %v", err)
+	}
+	if err := DeleteEmployeeAPI(context.Background(), store, employees[0].ID, 0); err != ErrEmployeeNotFound {
+		t.Errorf("DeleteEmployeeAPI() of deleted employee error = %v, want %v", err, ErrEmployeeNotFound)
+	}
+}
+
+func TestSQLiteUniqueViolation(t *testing.T) {
+	db := initTestSQLiteDB(t)
+	if _, err := db.Exec("CREATE TABLE t (ID INTEGER PRIMARY KEY, Code TEXT UNIQUE)"); err != nil {
+		t.Fatalf("Unable to create table: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO t (Code) VALUES ('a')"); err != nil {
+		t.Fatalf("Unable to insert: %v", err)
+	}
+
+	_, err := db.Exec("INSERT INTO t (Code) VALUES ('a')")
+	field, _, ok := SQLiteDialect.UniqueViolation(err)
+	if !ok || field != "code" {
+		t.Errorf("UniqueViolation(%v) = %q, %v, want code, true", err, field, ok)
+	}
+
+	_, err = db.Exec("INSERT INTO missing (Code) VALUES ('a')")
+	if _, _, ok := SQLiteDialect.UniqueViolation(err); ok {
+		t.Errorf("UniqueViolation(%v) reported a unique violation", err)
+	}
+}
+
+func TestSQLiteForeignKeyViolation(t *testing.T) {
+	db := initTestSQLiteDB(t)
+	if _, err := db.Exec("CREATE TABLE p (ID INTEGER PRIMARY KEY); CREATE TABLE c (ID INTEGER PRIMARY KEY, PID INTEGER REFERENCES p (ID))"); err != nil {
+		t.Fatalf("Unable to create tables: %v", err)
+	}
+
+	// Foreign keys are enforced on every connection
+	_, err := db.Exec("INSERT INTO c (PID) VALUES (1)")
+	if !SQLiteDialect.ForeignKeyViolation(err) {
+		t.Errorf("ForeignKeyViolation(%v) = false, want true", err)
+	}
+
+	_, err = db.Exec("INSERT INTO missing (PID) VALUES (1)")
+	if SQLiteDialect.ForeignKeyViolation(err) {
+		t.Errorf("ForeignKeyViolation(%v) reported a foreign key violation", err)
+	}
+}
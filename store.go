@@ -1,82 +1,452 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
-var db *sql.DB
+// initDB opens the PostgreSQL connection pool described by cfg and checks that it connects
+func initDB(cfg *Config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.Postgres.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the database: %w", err)
+	}
 
-func initDB() *sql.DB {
-	const (
-		host     = "localhost"
-		port     = 5432
-		user     = "postgres"
-		password = "9101996"
-		dbname   = "organisation"
-	)
+	// Size the connection pool
+	db.SetMaxOpenConns(cfg.Pool.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.Pool.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.Pool.ConnMaxLifetime)
 
-	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("testing the database connection: %w", err)
+	}
 
-	var err error
-	db, err = sql.Open("postgres", connStr)
-	if err != nil {
-		log.Fatal("Error connecting to the database:", err)
+	fmt.Println("Successfully connected to the database!")
+
+	return db, nil
+}
+
+// ErrEmployeeNotFound is returned by every EmployeeStore when no employee has the requested ID
+var ErrEmployeeNotFound = errors.New("employee not found")
+
+// ErrVersionMismatch is returned when a conditional write finds the employee at another version
+var ErrVersionMismatch = errors.New("employee has been modified since it was read")
+
+// ConflictError is returned when a write violates a unique constraint
+type ConflictError struct {
+	Resource string
+	Field    string
+	Value    string
+	Err      error
+}
+
+func (e *ConflictError) Error() string {
+	if e.Value != "" {
+		return fmt.Sprintf("%s with %s %s already exists", e.Resource, e.Field, e.Value)
 	}
+	return fmt.Sprintf("%s with this %s already exists", e.Resource, e.Field)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// EmployeeStore is the storage backend used by the API layer and CustomRouter. Every write of
+// an employee, department or exchange rate appends an AuditEntry to the audit log along with it,
+// made by the actor auditInfoFrom finds in the context.
+type EmployeeStore interface {
+	CreateEmployee(ctx context.Context, emp *Employee) error
+	ReadEmployee(ctx context.Context, id int) (*Employee, error)
+	// ReadEmployeeList returns up to q.Limit employees, in sort order, next to q.Cursor
+	ReadEmployeeList(ctx context.Context, q EmployeeListQuery) ([]Employee, error)
+	// CountEmployees counts the employees matching f. With estimate set the store may answer
+	// an unfiltered count from statistics instead, and reports when it did.
+	CountEmployees(ctx context.Context, f EmployeeFilter, estimate bool) (count int, estimated bool, err error)
+	// UpdateEmployee writes the named fields of emp (see employeeFields), bumps the version and
+	// returns the stored result. A non-zero version makes the write conditional on it.
+	// Changes to the Position are recorded in the history as taking effect on effective, and
+	// only reach the employee itself once they are in effect.
+	UpdateEmployee(ctx context.Context, id int, emp *Employee, fields []string, version int, effective time.Time) (*Employee, error)
+	// DeleteEmployee removes the employee; a non-zero version makes it conditional on it.
+	// It fails with ErrEmployeeHasReports while other employees report to it.
+	DeleteEmployee(ctx context.Context, id int, version int) error
+	// ReadHistory returns the history of the employee, in compareHistoryEntries order. It
+	// outlives the employee: deleting one keeps its history.
+	ReadHistory(ctx context.Context, id int) ([]HistoryEntry, error)
+	// ReadSnapshots returns every snapshot of the employee in the order they were taken. Like
+	// the history, they outlive the employee.
+	ReadSnapshots(ctx context.Context, id int) ([]EmployeeSnapshot, error)
+	// ApplyScheduledChanges brings the employees with history entries taking effect after
+	// since and up to until into the position in effect on until, and counts those it changed.
+	// Its audit entries are the scheduler's.
+	ApplyScheduledChanges(ctx context.Context, since, until time.Time) (int, error)
+	// ReadSubordinates returns the employees reporting to id directly or through others, up
+	// to maxDepth levels down, ordered by depth and then ID
+	ReadSubordinates(ctx context.Context, id int, maxDepth int) ([]OrgEntry, error)
+	// ReadManagerChain returns the managers above id, its own manager first, up to maxDepth
+	// levels up
+	ReadManagerChain(ctx context.Context, id int, maxDepth int) ([]OrgEntry, error)
+	// SalaryTotals sums up the salaries of the employees matching f per currency, ordered by
+	// currency code
+	SalaryTotals(ctx context.Context, f EmployeeFilter) ([]CurrencyTotal, error)
+	// PutExchangeRate records rate, replacing any rate of its pair on the same date
+	PutExchangeRate(ctx context.Context, rate *ExchangeRate) error
+	// ReadExchangeRates lists the rates matching f, ordered by pair and effective date
+	ReadExchangeRates(ctx context.Context, f ExchangeRateFilter) ([]ExchangeRate, error)
+	// ReadEffectiveExchangeRate returns the latest rate of the pair effective on or before date
+	ReadEffectiveExchangeRate(ctx context.Context, from, to string, date time.Time) (*ExchangeRate, error)
+	// DeleteExchangeRate removes the rate the pair took effect with on date
+	DeleteExchangeRate(ctx context.Context, from, to string, date time.Time) error
+	CreateDepartment(ctx context.Context, dept *Department) error
+	ReadDepartment(ctx context.Context, id int) (*Department, error)
+	// ReadDepartmentList returns every department, ordered by ID
+	ReadDepartmentList(ctx context.Context) ([]Department, error)
+	UpdateDepartment(ctx context.Context, id int, dept *Department) (*Department, error)
+	// DeleteDepartment removes a department, failing with ErrDepartmentNotEmpty while
+	// employees belong to it
+	DeleteDepartment(ctx context.Context, id int) error
+	// ReadAuditLog returns up to q.Limit audit entries matching q.Filter after q.AfterID, in ID order
+	ReadAuditLog(ctx context.Context, q AuditQuery) ([]AuditEntry, error)
+	// Ping reports whether the backend is currently usable
+	Ping(ctx context.Context) error
+}
+
+// employeeField describes an Employee field that can be written by an update
+type employeeField struct {
+	Column string
+	Value  func(emp *Employee) any
+	Copy   func(dst, src *Employee)
+}
+
+// employeeFields maps the JSON name of every updatable Employee field to its column
+var employeeFields = map[string]employeeField{
+	"name": {
+		Column: "Name",
+		Value:  func(emp *Employee) any { return emp.Name },
+		Copy:   func(dst, src *Employee) { dst.Name = src.Name },
+	},
+	"designation": {
+		Column: "Designation",
+		Value:  func(emp *Employee) any { return emp.Designation },
+		Copy:   func(dst, src *Employee) { dst.Designation = src.Designation },
+	},
+	"salary": {
+		Column: "Salary",
+		Value:  func(emp *Employee) any { return emp.Salary },
+		Copy:   func(dst, src *Employee) { dst.Salary = src.Salary },
+	},
+	"currency": {
+		Column: "Currency",
+		Value:  func(emp *Employee) any { return emp.Currency },
+		Copy:   func(dst, src *Employee) { dst.Currency = src.Currency },
+	},
+	"departmentId": {
+		Column: "DepartmentID",
+		Value:  func(emp *Employee) any { return emp.DepartmentID },
+		Copy:   func(dst, src *Employee) { dst.DepartmentID = src.DepartmentID },
+	},
+	"managerId": {
+		Column: "ManagerID",
+		Value:  func(emp *Employee) any { return emp.ManagerID },
+		Copy:   func(dst, src *Employee) { dst.ManagerID = src.ManagerID },
+	},
+}
+
+// replaceEmployeeFields lists the fields a full replacement writes
+var replaceEmployeeFields = []string{"name", "designation", "salary", "currency", "departmentId", "managerId"}
+
+// updatableEmployeeFields keeps the names in fields that an update can write
+func updatableEmployeeFields(fields []string) []string {
+	var updatable []string
+	for _, name := range fields {
+		if _, ok := employeeFields[name]; ok {
+			updatable = append(updatable, name)
+		}
+	}
+	return updatable
+}
+
+// employeeColumns is the column list every query reading a whole employee selects, in scanEmployee order
+const employeeColumns = "ID, Name, Designation, Salary, Currency, DepartmentID, ManagerID, CreatedAt, UpdatedAt, Version"
+
+// sqlConditions collects the parameterized conditions of a WHERE clause
+type sqlConditions struct {
+	where []string
+	args  []any
+}
+
+// add appends a condition whose format takes the parameter numbers of args in order
+func (c *sqlConditions) add(format string, args ...any) {
+	numbers := make([]any, len(args))
+	for i, arg := range args {
+		numbers[i] = c.param(arg)
+	}
+	c.where = append(c.where, fmt.Sprintf(format, numbers...))
+}
+
+// param adds a parameter used outside the WHERE clause and returns its number
+func (c *sqlConditions) param(arg any) int {
+	c.args = append(c.args, arg)
+	return len(c.args)
+}
+
+func (c *sqlConditions) String() string {
+	if len(c.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(c.where, " AND ")
+}
+
+// employeeFilterConditions turns the set fields of a filter into conditions
+func employeeFilterConditions(f EmployeeFilter) *sqlConditions {
+	c := &sqlConditions{}
+	if f.Designation != "" {
+		c.add("LOWER(Designation) = LOWER($%d)", f.Designation)
+	}
+	if f.MinSalary != nil {
+		c.add("Salary >= $%d", *f.MinSalary)
+	}
+	if f.MaxSalary != nil {
+		c.add("Salary <= $%d", *f.MaxSalary)
+	}
+	if f.Currency != "" {
+		c.add("Currency = $%d", f.Currency)
+	}
+	if f.DepartmentID != nil {
+		c.add("DepartmentID = $%d", *f.DepartmentID)
+	}
+	if f.ManagerID != nil {
+		c.add("ManagerID = $%d", *f.ManagerID)
+	}
+	if f.NoManager {
+		c.add("ManagerID IS NULL")
+	}
+	if !f.CreatedAfter.IsZero() {
+		c.add("CreatedAt >= $%d", f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		c.add("CreatedAt <= $%d", f.CreatedBefore)
+	}
+	if !f.UpdatedAfter.IsZero() {
+		c.add("UpdatedAt >= $%d", f.UpdatedAfter)
+	}
+	if !f.UpdatedBefore.IsZero() {
+		c.add("UpdatedAt <= $%d", f.UpdatedBefore)
+	}
+	if f.NameContains != "" {
+		c.add(`LOWER(Name) LIKE $%d ESCAPE '\'`, likePattern(f.NameContains))
+	}
+	if f.Search != "" {
+		c.add(`(LOWER(Name) LIKE $%[1]d ESCAPE '\' OR LOWER(Designation) LIKE $%[1]d ESCAPE '\')`, likePattern(f.Search))
+	}
+	return c
+}
+
+// latestHistorySQL is a subquery selecting column from the latest history entry of the
+// employee whose ID is employeeID that sets the set column and takes effect by parameter $n
+func latestHistorySQL(column, set, employeeID string, n int) string {
+	return fmt.Sprintf("(SELECT h.%s FROM employee_history h WHERE h.EmployeeID = %s AND h.%s IS NOT NULL AND h.EffectiveFrom <= $%d ORDER BY h.EffectiveFrom DESC, h.ID DESC LIMIT 1)", column, employeeID, set, n)
+}
 
-	err = db.Ping()
+// employeeTable returns what queries over the employees matching f select from: the employee
+// table, or when f.AsOf is set a derived table of the same columns holding the employees as
+// they were then, as employeeAsOf rebuilds them. Each position column comes from the latest
+// history entry setting it, the other columns from the snapshot in force at the end of the day.
+func employeeTable(f EmployeeFilter, c *sqlConditions) string {
+	if f.AsOf.IsZero() {
+		return "employee"
+	}
+	n := c.param(f.AsOf)
+	end := c.param(f.AsOf.AddDate(0, 0, 1))
+	latest := func(column, set string) string { return latestHistorySQL(column, set, "s.EmployeeID", n) }
+	snapshot := fmt.Sprintf("COALESCE((SELECT MAX(l.ID) FROM employee_snapshot l WHERE l.EmployeeID = s.EmployeeID AND l.RecordedAt < $%d), "+
+		"(SELECT MIN(l.ID) FROM employee_snapshot l WHERE l.EmployeeID = s.EmployeeID))", end)
+	// The cast keeps Salary comparing as a number in SQLite, as the column itself does
+	return "(SELECT s.EmployeeID AS ID, s.Name, " + latest("Designation", "Designation") + " AS Designation, " +
+		"CAST(" + latest("Salary", "Salary") + " AS NUMERIC(14, 2)) AS Salary, " +
+		latest("Currency", "Salary") + " AS Currency, " +
+		"s.DepartmentID, s.ManagerID, s.CreatedAt, s.UpdatedAt, s.Version FROM employee_snapshot s " +
+		"WHERE s.ID = " + snapshot + " AND NOT s.Deleted " +
+		fmt.Sprintf("AND EXISTS (SELECT 1 FROM employee_history h WHERE h.EmployeeID = s.EmployeeID AND h.EffectiveFrom <= $%d)) AS employee", n)
+}
+
+// employeeListSQL builds the parameterized query for a page of employees. Only the
+// whitelisted sort columns are spliced into the SQL; every value is a parameter.
+func employeeListSQL(q EmployeeListQuery) (string, []any) {
+	c := employeeFilterConditions(q.Filter)
+	key := q.Sort.key()
+
+	// Walk the sort order towards the cursor's side: backwards pages are read in reverse
+	desc := q.Sort.Desc
+	if q.Cursor != nil && q.Cursor.Backward {
+		desc = !desc
+	}
+	op, dir := ">", " ASC"
+	if desc {
+		op, dir = "<", " DESC"
+	}
+
+	// Keyset condition: only rows beyond the cursor row, with ID breaking ties
+	if cursor := q.Cursor; cursor != nil && !cursor.FromEnd {
+		if key.Column == "ID" {
+			c.add("ID "+op+" $%d", cursor.Last.ID)
+		} else {
+			c.add(fmt.Sprintf("(%[1]s %[2]s $%%[1]d OR (%[1]s = $%%[1]d AND ID %[2]s $%%[2]d))", key.Column, op),
+				key.Value(&cursor.Last), cursor.Last.ID)
+		}
+	}
+
+	listSQL := "SELECT " + employeeColumns + " FROM " + employeeTable(q.Filter, c) + c.String() + " ORDER BY " + key.Column + dir
+	if key.Column != "ID" {
+		listSQL += ", ID" + dir
+	}
+	c.args = append(c.args, q.Limit)
+	listSQL += fmt.Sprintf(" LIMIT $%d", len(c.args))
+	return listSQL, c.args
+}
+
+// likePattern matches s anywhere in a lower-cased column, with LIKE wildcards in s escaped
+func likePattern(s string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(s))
+	return "%" + escaped + "%"
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanEmployee scans employeeColumns, followed by any extra columns into extra
+func scanEmployee(row rowScanner, extra ...any) (*Employee, error) {
+	emp := &Employee{}
+	dest := []any{&emp.ID, &emp.Name, &emp.Designation, &emp.Salary, &emp.Currency, &emp.DepartmentID, &emp.ManagerID, &emp.CreatedAt, &emp.UpdatedAt, &emp.Version}
+	err := row.Scan(append(dest, extra...)...)
 	if err != nil {
-		log.Fatal("Error testing database connection:", err)
+		return nil, err
 	}
+	return emp, nil
+}
+
+// SQLEmployeeStore is an EmployeeStore backed by a SQL database speaking the given Dialect
+type SQLEmployeeStore struct {
+	DB      *sql.DB
+	Dialect Dialect
+}
+
+// NewPostgresEmployeeStore creates a new SQLEmployeeStore for a PostgreSQL database connection
+func NewPostgresEmployeeStore(db *sql.DB) *SQLEmployeeStore {
+	return &SQLEmployeeStore{DB: db, Dialect: PostgresDialect}
+}
+
+// NewSQLiteEmployeeStore creates a new SQLEmployeeStore for a SQLite database connection
+func NewSQLiteEmployeeStore(db *sql.DB) *SQLEmployeeStore {
+	return &SQLEmployeeStore{DB: db, Dialect: SQLiteDialect}
+}
 
-	createTableSQL := `
-	CREATE TABLE IF NOT EXISTS employee (
-		ID SERIAL PRIMARY KEY,
-		Name VARCHAR(100) NOT NULL,
-		Designation VARCHAR(100) NOT NULL,
-		Salary FLOAT8 NOT NULL,
-		CreatedAt TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-		UpdatedAt TIMESTAMPTZ NOT NULL DEFAULT NOW()
+// querier is the part of *sql.DB and *sql.Tx the store runs statements through
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
 
-	);
-	`
-	_, err = db.Exec(createTableSQL)
+// inTx runs fn in a transaction, committing it when fn succeeds
+func (s *SQLEmployeeStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
-		log.Fatalf("Unable to create table: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLEmployeeStore) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	return s.DB.QueryRowContext(ctx, s.Dialect.Rebind(query), args...)
+}
+
+func (s *SQLEmployeeStore) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return s.DB.QueryContext(ctx, s.Dialect.Rebind(query), args...)
+}
+
+func (s *SQLEmployeeStore) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return s.DB.ExecContext(ctx, s.Dialect.Rebind(query), args...)
+}
+
+// employeeWriteError explains the constraint violations of an employee insert or update
+func (s *SQLEmployeeStore) employeeWriteError(ctx context.Context, emp *Employee, err error) error {
+	if s.Dialect.ForeignKeyViolation(err) {
+		// SQLite does not name the violated constraint, so look up which reference is dangling
+		if emp.DepartmentID != nil {
+			if _, err := s.ReadDepartment(ctx, *emp.DepartmentID); errors.Is(err, ErrDepartmentNotFound) {
+				return ErrUnknownDepartment
+			}
+		}
+		return ErrUnknownManager
 	}
+	return s.conflictError("employee", err)
+}
 
-	fmt.Println("Successfully connected to the database and ensured employee table exists!")
+// conflictError turns unique-constraint violations into a ConflictError and passes other errors through
+func (s *SQLEmployeeStore) conflictError(resource string, err error) error {
+	if field, value, ok := s.Dialect.UniqueViolation(err); ok {
+		return &ConflictError{Resource: resource, Field: field, Value: value, Err: err}
+	}
+	return err
+}
 
-	return db
+func (s *SQLEmployeeStore) Ping(ctx context.Context) error {
+	return s.DB.PingContext(ctx)
 }
 
-func CreateEmployeeStore(db *sql.DB, emp *Employee) error {
+func (s *SQLEmployeeStore) CreateEmployee(ctx context.Context, emp *Employee) error {
+	// The ID always comes from the database sequence; any ID on emp is ignored
 	insertEmployeeSQL := `
-        INSERT INTO employee (ID, Name, Designation, Salary, CreatedAt, UpdatedAt)
-        VALUES ($1, $2, $3, $4, $5, $6)
-        RETURNING ID;
+        INSERT INTO employee (Name, Designation, Salary, Currency, DepartmentID, ManagerID, CreatedAt, UpdatedAt)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING ID, CreatedAt, UpdatedAt, Version;
     `
 
-	var empID int
-	err := db.QueryRow(insertEmployeeSQL, emp.ID, emp.Name, emp.Designation, emp.Salary, time.Now(), time.Now()).Scan(&empID)
+	// A new employee has no reports yet, so no manager can close a cycle through it. Its
+	// history starts with the whole position, in effect from the day it was created.
+	now := time.Now().UTC()
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, s.Dialect.Rebind(insertEmployeeSQL), emp.Name, emp.Designation, emp.Salary, emp.Currency, emp.DepartmentID, emp.ManagerID, now, now).
+			Scan(&emp.ID, &emp.CreatedAt, &emp.UpdatedAt, &emp.Version)
+		if err != nil {
+			return err
+		}
+		if err := s.insertHistoryEntry(ctx, tx, initialHistoryEntry(emp, now)); err != nil {
+			return err
+		}
+		if err := s.insertSnapshot(ctx, tx, emp, false); err != nil {
+			return err
+		}
+		return s.audit(ctx, tx, AuditEmployee, AuditCreate, strconv.Itoa(emp.ID), nil, emp)
+	})
 	if err != nil {
-		return err
+		return s.employeeWriteError(ctx, emp, err)
 	}
 
-	// Update the Employee ID in the passed struct
-	emp.ID = empID
 	return nil
 }
 
-func ReadEmployeeStore(db *sql.DB, id int) (*Employee, error) {
-
-	emp := &Employee{}
-
-	err := db.QueryRow("SELECT ID, Name, Designation, Salary, CreatedAt,UpdatedAt FROM employee WHERE ID = $1", id).
-		Scan(&emp.ID, &emp.Name, &emp.Designation, &emp.Salary, &emp.CreatedAt, &emp.UpdatedAt)
+func (s *SQLEmployeeStore) ReadEmployee(ctx context.Context, id int) (*Employee, error) {
+	emp, err := scanEmployee(s.queryRow(ctx, "SELECT "+employeeColumns+" FROM employee WHERE ID = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEmployeeNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -84,9 +454,10 @@ func ReadEmployeeStore(db *sql.DB, id int) (*Employee, error) {
 	return emp, nil
 }
 
-func ReadEmployeeListStore(db *sql.DB, limit, offset int) ([]Employee, error) {
-	// Execute the query to fetch paginated employees
-	rows, err := db.Query("SELECT ID, Name, Designation, Salary, CreatedAt, UpdatedAt FROM employee ORDER BY ID LIMIT $1 OFFSET $2", limit, offset)
+func (s *SQLEmployeeStore) ReadEmployeeList(ctx context.Context, q EmployeeListQuery) ([]Employee, error) {
+	// Execute the query to fetch the filtered, sorted page of employees
+	listSQL, args := employeeListSQL(q)
+	rows, err := s.query(ctx, listSQL, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -95,12 +466,11 @@ func ReadEmployeeListStore(db *sql.DB, limit, offset int) ([]Employee, error) {
 	// Iterate through the result set and populate the employees slice
 	var employees []Employee
 	for rows.Next() {
-		var emp Employee
-		err := rows.Scan(&emp.ID, &emp.Name, &emp.Designation, &emp.Salary, &emp.CreatedAt, &emp.UpdatedAt)
+		emp, err := scanEmployee(rows)
 		if err != nil {
 			return nil, err
 		}
-		employees = append(employees, emp)
+		employees = append(employees, *emp)
 	}
 
 	// Check for any errors during rows iteration
@@ -108,66 +478,767 @@ func ReadEmployeeListStore(db *sql.DB, limit, offset int) ([]Employee, error) {
 		return nil, err
 	}
 
-	if len(employees) == 0 {
-		return nil, errors.New("no records available")
+	// Backward pages were read in reverse sort order
+	if q.Cursor != nil && q.Cursor.Backward {
+		slices.Reverse(employees)
 	}
 
 	return employees, nil
 }
 
-func UpdateEmployeeStore(db *sql.DB, id int, updatedEmp *Employee) (*Employee, error) {
-	// If updatedEmp is not provided, perform only read operation
-	emp := &Employee{}
-	err := db.QueryRow("SELECT ID, Name, Designation, Salary, CreatedAt, UpdatedAt FROM employee WHERE ID = $1", id).
-		Scan(&emp.ID, &emp.Name, &emp.Designation, &emp.Salary, &emp.CreatedAt, &emp.UpdatedAt)
+func (s *SQLEmployeeStore) CountEmployees(ctx context.Context, f EmployeeFilter, estimate bool) (int, bool, error) {
+	var count int
+
+	// Statistics describe the whole table, so they only stand in for unfiltered counts
+	if estimateSQL := s.Dialect.EstimateRowsSQL(); estimate && estimateSQL != "" && f == (EmployeeFilter{}) {
+		err := s.queryRow(ctx, estimateSQL, "employee").Scan(&count)
+		if err != nil {
+			return 0, false, err
+		}
+		if count >= 0 {
+			return count, true, nil
+		}
+	}
+
+	c := employeeFilterConditions(f)
+	countSQL := "SELECT COUNT(*) FROM " + employeeTable(f, c) + c.String()
+	err := s.queryRow(ctx, countSQL, c.args...).Scan(&count)
 	if err != nil {
-		return nil, err
+		return 0, false, err
+	}
+	return count, false, nil
+}
+
+func (s *SQLEmployeeStore) UpdateEmployee(ctx context.Context, id int, emp *Employee, fields []string, version int, effective time.Time) (*Employee, error) {
+	var updated *Employee
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		// Lock the employee so history entries are added one after the other and the audit
+		// entry holds the version this update replaces
+		current, err := s.readEmployeeForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && current.Version != version {
+			return ErrVersionMismatch
+		}
+
+		if slices.Contains(fields, "managerId") && emp.ManagerID != nil {
+			if err := s.checkManager(ctx, tx, id, *emp.ManagerID); err != nil {
+				return err
+			}
+		}
+		written, writtenFields := emp, fields
+		if tracksPosition(fields) {
+			if written, writtenFields, err = s.recordPosition(ctx, tx, id, emp, fields, effective); err != nil {
+				return err
+			}
+		}
+
+		if updated, err = s.updateEmployee(ctx, tx, id, written, writtenFields, version); err != nil {
+			return err
+		}
+		if err := s.insertSnapshot(ctx, tx, updated, false); err != nil {
+			return err
+		}
+		return s.audit(ctx, tx, AuditEmployee, AuditUpdate, strconv.Itoa(id), current, updated)
+	})
+	if err != nil {
+		return nil, s.employeeWriteError(ctx, emp, err)
 	}
+	return updated, nil
+}
 
-	if updatedEmp != nil {
-		if updatedEmp.Name == "" {
-			updatedEmp.Name = emp.Name
+// checkManager checks that managerID exists and is neither employee id nor one of its reports
+func (s *SQLEmployeeStore) checkManager(ctx context.Context, tx *sql.Tx, id int, managerID int) error {
+	// Two concurrent moves could each pass the check and close a cycle together
+	if lockSQL := s.Dialect.HierarchyLockSQL(); lockSQL != "" {
+		if _, err := tx.ExecContext(ctx, lockSQL); err != nil {
+			return err
 		}
-		if updatedEmp.Salary == 0 {
-			updatedEmp.Salary = emp.Salary
+	}
+
+	// Walk up from the new manager; UNION drops repeats, so the walk ends even on bad data
+	checkManagerSQL := `
+        WITH RECURSIVE chain (EmployeeID) AS (
+            SELECT ID FROM employee WHERE ID = $1
+            UNION
+            SELECT e.ManagerID FROM employee e JOIN chain c ON e.ID = c.EmployeeID WHERE e.ManagerID IS NOT NULL
+        )
+        SELECT COUNT(*), COUNT(CASE WHEN EmployeeID = $2 THEN 1 END) FROM chain;
+    `
+	var managers, cycles int
+	if err := tx.QueryRowContext(ctx, s.Dialect.Rebind(checkManagerSQL), managerID, id).Scan(&managers, &cycles); err != nil {
+		return err
+	}
+	if managers == 0 {
+		return ErrUnknownManager
+	}
+	if cycles > 0 {
+		return ErrManagerCycle
+	}
+	return nil
+}
+
+// recordPosition adds the history entry of an update changing the position and returns the
+// employee and fields to write instead: the position in effect today, whatever was asked for
+func (s *SQLEmployeeStore) recordPosition(ctx context.Context, tx *sql.Tx, id int, emp *Employee, fields []string, effective time.Time) (*Employee, []string, error) {
+	entries, err := s.readHistory(ctx, tx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry, position := recordPosition(entries, emp, fields, effective)
+	if entry != nil {
+		entry.EmployeeID = id
+		entry.RecordedAt = time.Now().UTC()
+		if err := s.insertHistoryEntry(ctx, tx, entry); err != nil {
+			return nil, nil, err
 		}
-		if updatedEmp.Designation == "" {
-			updatedEmp.Designation = emp.Designation
+	}
+
+	written := *emp
+	position.applyTo(&written)
+	writtenFields := slices.Clone(fields)
+	for _, name := range positionFields {
+		if !slices.Contains(writtenFields, name) {
+			writtenFields = append(writtenFields, name)
 		}
-		if updatedEmp.ID == 0 {
-			updatedEmp.ID = emp.ID
+	}
+	return &written, writtenFields, nil
+}
+
+// readEmployeeForUpdate reads an employee in tx, locking it until the transaction ends
+func (s *SQLEmployeeStore) readEmployeeForUpdate(ctx context.Context, tx *sql.Tx, id int) (*Employee, error) {
+	readSQL := "SELECT " + employeeColumns + " FROM employee WHERE ID = $1" + s.Dialect.ForUpdateSQL()
+	emp, err := scanEmployee(tx.QueryRowContext(ctx, s.Dialect.Rebind(readSQL), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEmployeeNotFound
+	}
+	return emp, err
+}
+
+// updateEmployee sets only the named columns and reads the row back in the same statement
+func (s *SQLEmployeeStore) updateEmployee(ctx context.Context, q querier, id int, emp *Employee, fields []string, version int) (*Employee, error) {
+	var set []string
+	var args []any
+	for _, name := range fields {
+		field, ok := employeeFields[name]
+		if !ok {
+			return nil, fmt.Errorf("employee field %q cannot be updated", name)
 		}
+		args = append(args, field.Value(emp))
+		set = append(set, fmt.Sprintf("%s = $%d", field.Column, len(args)))
+	}
+	args = append(args, time.Now().UTC())
+	set = append(set, fmt.Sprintf("UpdatedAt = $%d", len(args)), "Version = Version + 1")
+	args = append(args, id)
+	where := fmt.Sprintf("ID = $%d", len(args))
+	if version != 0 {
+		args = append(args, version)
+		where += fmt.Sprintf(" AND Version = $%d", len(args))
 	}
 
-	// If updatedEmp is provided, perform update operation
-	_, err = db.Exec("UPDATE employee SET Name = $1, Designation = $2, Salary = $3, UpdatedAt = $4 WHERE ID = $5",
-		updatedEmp.Name, updatedEmp.Designation, updatedEmp.Salary, time.Now(), id)
+	updateEmployeeSQL := fmt.Sprintf("UPDATE employee SET %s WHERE %s RETURNING %s",
+		strings.Join(set, ", "), where, employeeColumns)
+
+	updated, err := scanEmployee(q.QueryRowContext(ctx, s.Dialect.Rebind(updateEmployeeSQL), args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, s.missingOrModified(ctx, q, id)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// Return the updated employee data
-	return updatedEmp, nil
+	return updated, nil
 }
 
-func DeleteEmployeeStore(db *sql.DB, id int) error {
+func (s *SQLEmployeeStore) DeleteEmployee(ctx context.Context, id int, version int) error {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		current, err := s.readEmployeeForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && current.Version != version {
+			return ErrVersionMismatch
+		}
 
-	emp := &Employee{}
-	err := db.QueryRow("SELECT ID, Name, Designation, Salary, CreatedAt, UpdatedAt FROM employee WHERE ID = $1", id).
-		Scan(&emp.ID, &emp.Name, &emp.Designation, &emp.Salary, &emp.CreatedAt, &emp.UpdatedAt)
+		if _, err := tx.ExecContext(ctx, s.Dialect.Rebind("DELETE FROM employee WHERE ID = $1"), id); err != nil {
+			return err
+		}
+		if err := s.insertSnapshot(ctx, tx, current, true); err != nil {
+			return err
+		}
+		return s.audit(ctx, tx, AuditEmployee, AuditDelete, strconv.Itoa(id), current, nil)
+	})
+	if s.Dialect.ForeignKeyViolation(err) {
+		return ErrEmployeeHasReports
+	}
+	return err
+}
+
+// missingOrModified explains why a conditional write matched no row
+func (s *SQLEmployeeStore) missingOrModified(ctx context.Context, q querier, id int) error {
+	var exists int
+	err := q.QueryRowContext(ctx, s.Dialect.Rebind("SELECT 1 FROM employee WHERE ID = $1"), id).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrEmployeeNotFound
+	}
 	if err != nil {
 		return err
 	}
-	if emp != nil {
-		_, err := db.Exec("DELETE FROM employee WHERE ID = $1", id)
+	return ErrVersionMismatch
+}
+
+// historyColumns is the column list every query reading a history entry selects, in
+// scanHistoryEntry order
+const historyColumns = "ID, EmployeeID, EffectiveFrom, Designation, Salary, Currency, RecordedAt"
+
+func scanHistoryEntry(row rowScanner) (*HistoryEntry, error) {
+	entry := &HistoryEntry{}
+	var currency sql.NullString
+	if err := row.Scan(&entry.ID, &entry.EmployeeID, &entry.EffectiveFrom, &entry.Designation, &entry.Salary, &currency, &entry.RecordedAt); err != nil {
+		return nil, err
+	}
+	entry.EffectiveFrom = entry.EffectiveFrom.UTC()
+	entry.Currency = currency.String
+	return entry, nil
+}
+
+func (s *SQLEmployeeStore) insertHistoryEntry(ctx context.Context, q querier, entry *HistoryEntry) error {
+	insertHistorySQL := `
+        INSERT INTO employee_history (EmployeeID, EffectiveFrom, Designation, Salary, Currency, RecordedAt)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING ID;
+    `
+	var currency any
+	if entry.Salary != nil {
+		currency = entry.Currency
+	}
+	return q.QueryRowContext(ctx, s.Dialect.Rebind(insertHistorySQL), entry.EmployeeID, entry.EffectiveFrom,
+		entry.Designation, entry.Salary, currency, entry.RecordedAt).Scan(&entry.ID)
+}
+
+func (s *SQLEmployeeStore) readHistory(ctx context.Context, q querier, id int) ([]HistoryEntry, error) {
+	readHistorySQL := "SELECT " + historyColumns + " FROM employee_history WHERE EmployeeID = $1 ORDER BY EffectiveFrom, ID"
+	rows, err := q.QueryContext(ctx, s.Dialect.Rebind(readHistorySQL), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		entry, err := scanHistoryEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *SQLEmployeeStore) ReadHistory(ctx context.Context, id int) ([]HistoryEntry, error) {
+	return s.readHistory(ctx, s.DB, id)
+}
+
+// snapshotColumns is the column list every query reading a snapshot selects, in
+// scanSnapshot order
+const snapshotColumns = "EmployeeID, Name, Designation, Salary, Currency, DepartmentID, ManagerID, CreatedAt, UpdatedAt, Version, Deleted, RecordedAt"
+
+func scanSnapshot(row rowScanner) (*EmployeeSnapshot, error) {
+	snapshot := &EmployeeSnapshot{}
+	emp, err := scanEmployee(row, &snapshot.Deleted, &snapshot.RecordedAt)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Employee = *emp
+	snapshot.RecordedAt = snapshot.RecordedAt.UTC()
+	return snapshot, nil
+}
+
+// insertSnapshot records emp as a write in tx left it; deleted marks the snapshot of a deletion
+func (s *SQLEmployeeStore) insertSnapshot(ctx context.Context, tx *sql.Tx, emp *Employee, deleted bool) error {
+	insertSnapshotSQL := "INSERT INTO employee_snapshot (" + snapshotColumns + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)"
+	_, err := tx.ExecContext(ctx, s.Dialect.Rebind(insertSnapshotSQL), emp.ID, emp.Name, emp.Designation, emp.Salary, emp.Currency,
+		emp.DepartmentID, emp.ManagerID, emp.CreatedAt, emp.UpdatedAt, emp.Version, deleted, time.Now().UTC())
+	return err
+}
+
+func (s *SQLEmployeeStore) ReadSnapshots(ctx context.Context, id int) ([]EmployeeSnapshot, error) {
+	rows, err := s.query(ctx, "SELECT "+snapshotColumns+" FROM employee_snapshot WHERE EmployeeID = $1 ORDER BY ID", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []EmployeeSnapshot
+	for rows.Next() {
+		snapshot, err := scanSnapshot(rows)
 		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, *snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// dueEmployees returns the IDs of the employees with history entries taking effect after
+// since and up to until whose position in effect on until differs from their own. A pass
+// costs this one query however many employees have a due entry, as all do after a restart.
+func (s *SQLEmployeeStore) dueEmployees(ctx context.Context, since, until time.Time) ([]int, error) {
+	latest := func(column, set string) string { return latestHistorySQL(column, set, "e.ID", 2) }
+	dueSQL := "SELECT e.ID FROM employee e WHERE EXISTS (SELECT 1 FROM employee_history h WHERE h.EmployeeID = e.ID AND h.EffectiveFrom > $1 AND h.EffectiveFrom <= $2) AND (" +
+		latest("Designation", "Designation") + " <> e.Designation OR " +
+		"CAST(" + latest("Salary", "Salary") + " AS NUMERIC(14, 2)) <> CAST(e.Salary AS NUMERIC(14, 2)) OR " +
+		latest("Currency", "Salary") + " <> e.Currency) ORDER BY e.ID"
+	rows, err := s.query(ctx, dueSQL, since, until)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (s *SQLEmployeeStore) ApplyScheduledChanges(ctx context.Context, since, until time.Time) (int, error) {
+	ctx = withSchedulerActor(ctx)
+
+	ids, err := s.dueEmployees(ctx, since, until)
+	if err != nil {
+		return 0, err
+	}
+
+	// One transaction per employee, so a failure leaves the others applied
+	applied := 0
+	for _, id := range ids {
+		changed := false
+		err := s.inTx(ctx, func(tx *sql.Tx) error {
+			current, err := s.readEmployeeForUpdate(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			entries, err := s.readHistory(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			position, ok := positionAt(entries, until)
+			if !ok || position == positionOf(current) {
+				return nil
+			}
+
+			applyPositionSQL := "UPDATE employee SET Designation = $1, Salary = $2, Currency = $3, UpdatedAt = $4, Version = Version + 1 WHERE ID = $5 RETURNING " + employeeColumns
+			updated, err := scanEmployee(tx.QueryRowContext(ctx, s.Dialect.Rebind(applyPositionSQL), position.Designation, position.Salary, position.Currency, time.Now().UTC(), id))
+			if err != nil {
+				return err
+			}
+			changed = true
+			if err := s.insertSnapshot(ctx, tx, updated, false); err != nil {
+				return err
+			}
+			return s.audit(ctx, tx, AuditEmployee, AuditUpdate, strconv.Itoa(id), current, updated)
+		})
+		if err != nil && !errors.Is(err, ErrEmployeeNotFound) {
+			return applied, err
+		}
+		if err == nil && changed {
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+func (s *SQLEmployeeStore) ReadSubordinates(ctx context.Context, id int, maxDepth int) ([]OrgEntry, error) {
+	subordinatesSQL := `
+        WITH RECURSIVE subtree (EmployeeID, Depth) AS (
+            SELECT ID, 1 FROM employee WHERE ManagerID = $1
+            UNION ALL
+            SELECT e.ID, t.Depth + 1 FROM employee e JOIN subtree t ON e.ManagerID = t.EmployeeID WHERE t.Depth < $2
+        )
+        SELECT ` + employeeColumns + `, Depth FROM subtree JOIN employee ON ID = EmployeeID ORDER BY Depth, ID;
+    `
+	return s.readOrgEntries(ctx, subordinatesSQL, id, maxDepth)
+}
+
+func (s *SQLEmployeeStore) ReadManagerChain(ctx context.Context, id int, maxDepth int) ([]OrgEntry, error) {
+	managerChainSQL := `
+        WITH RECURSIVE chain (EmployeeID, Depth) AS (
+            SELECT ManagerID, 1 FROM employee WHERE ID = $1 AND ManagerID IS NOT NULL
+            UNION ALL
+            SELECT e.ManagerID, c.Depth + 1 FROM employee e JOIN chain c ON e.ID = c.EmployeeID
+            WHERE e.ManagerID IS NOT NULL AND c.Depth < $2
+        )
+        SELECT ` + employeeColumns + `, Depth FROM chain JOIN employee ON ID = EmployeeID ORDER BY Depth;
+    `
+	return s.readOrgEntries(ctx, managerChainSQL, id, maxDepth)
+}
+
+// readOrgEntries runs a hierarchy query selecting employeeColumns followed by the depth
+func (s *SQLEmployeeStore) readOrgEntries(ctx context.Context, query string, args ...any) ([]OrgEntry, error) {
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []OrgEntry
+	for rows.Next() {
+		var depth int
+		emp, err := scanEmployee(rows, &depth)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, OrgEntry{Employee: *emp, Depth: depth})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *SQLEmployeeStore) SalaryTotals(ctx context.Context, f EmployeeFilter) ([]CurrencyTotal, error) {
+	// Sum whole cents so the total is exact even where NUMERIC is kept as a float
+	c := employeeFilterConditions(f)
+	totalsSQL := "SELECT Currency, COUNT(*), SUM(CAST(ROUND(Salary * 100) AS BIGINT)), MIN(Salary), MAX(Salary) FROM " +
+		employeeTable(f, c) + c.String() + " GROUP BY Currency ORDER BY Currency"
+	rows, err := s.query(ctx, totalsSQL, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []CurrencyTotal
+	for rows.Next() {
+		var total CurrencyTotal
+		var minor int64
+		if err := rows.Scan(&total.Currency, &total.Count, &minor, &total.Min, &total.Max); err != nil {
+			return nil, err
+		}
+		total.Total = MoneyFromMinor(minor)
+		totals = append(totals, total)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+func (s *SQLEmployeeStore) PutExchangeRate(ctx context.Context, rate *ExchangeRate) error {
+	putExchangeRateSQL := `
+        INSERT INTO exchange_rate (FromCurrency, ToCurrency, EffectiveDate, Rate)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (FromCurrency, ToCurrency, EffectiveDate) DO UPDATE SET Rate = excluded.Rate;
+    `
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		current, err := s.readExchangeRate(ctx, tx, rate.From, rate.To, rate.EffectiveDate)
+		if err != nil && !errors.Is(err, ErrExchangeRateNotFound) {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, s.Dialect.Rebind(putExchangeRateSQL), rate.From, rate.To, rate.EffectiveDate, formatRate(rate.Rate)); err != nil {
 			return err
 		}
-	} else {
-		return errors.New("no result received before timeout")
+		action := AuditUpdate
+		if current == nil {
+			action = AuditCreate
+		}
+		return s.audit(ctx, tx, AuditExchangeRate, action, rate.auditID(), current, rate)
+	})
+}
+
+// readExchangeRate reads the rate the pair took effect with on date, locking it until the
+// transaction ends
+func (s *SQLEmployeeStore) readExchangeRate(ctx context.Context, tx *sql.Tx, from, to string, date time.Time) (*ExchangeRate, error) {
+	readSQL := "SELECT " + exchangeRateColumns + " FROM exchange_rate WHERE FromCurrency = $1 AND ToCurrency = $2 AND EffectiveDate = $3" +
+		s.Dialect.ForUpdateSQL()
+	rate, err := scanExchangeRate(tx.QueryRowContext(ctx, s.Dialect.Rebind(readSQL), from, to, date))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrExchangeRateNotFound
+	}
+	return rate, err
+}
+
+// exchangeRateColumns is the column list every query reading a rate selects, in
+// scanExchangeRate order. The rate is read as text to keep every digit.
+const exchangeRateColumns = "FromCurrency, ToCurrency, EffectiveDate, CAST(Rate AS TEXT)"
+
+func scanExchangeRate(row rowScanner) (*ExchangeRate, error) {
+	rate := &ExchangeRate{}
+	var value string
+	if err := row.Scan(&rate.From, &rate.To, &rate.EffectiveDate, &value); err != nil {
+		return nil, err
+	}
+	rate.EffectiveDate = rate.EffectiveDate.UTC()
+	var ok bool
+	if rate.Rate, ok = new(big.Rat).SetString(value); !ok {
+		return nil, fmt.Errorf("scanning exchange rate %q", value)
+	}
+	return rate, nil
+}
+
+func (s *SQLEmployeeStore) ReadExchangeRates(ctx context.Context, f ExchangeRateFilter) ([]ExchangeRate, error) {
+	c := &sqlConditions{}
+	if f.From != "" {
+		c.add("FromCurrency = $%d", f.From)
+	}
+	if f.To != "" {
+		c.add("ToCurrency = $%d", f.To)
+	}
+	rows, err := s.query(ctx, "SELECT "+exchangeRateColumns+" FROM exchange_rate"+c.String()+
+		" ORDER BY FromCurrency, ToCurrency, EffectiveDate", c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []ExchangeRate
+	for rows.Next() {
+		rate, err := scanExchangeRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, *rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
 
+func (s *SQLEmployeeStore) ReadEffectiveExchangeRate(ctx context.Context, from, to string, date time.Time) (*ExchangeRate, error) {
+	effectiveRateSQL := "SELECT " + exchangeRateColumns + " FROM exchange_rate" +
+		" WHERE FromCurrency = $1 AND ToCurrency = $2 AND EffectiveDate <= $3 ORDER BY EffectiveDate DESC LIMIT 1"
+	rate, err := scanExchangeRate(s.queryRow(ctx, effectiveRateSQL, from, to, date))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrExchangeRateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+func (s *SQLEmployeeStore) DeleteExchangeRate(ctx context.Context, from, to string, date time.Time) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		current, err := s.readExchangeRate(ctx, tx, from, to, date)
+		if err != nil {
+			return err
+		}
+		deleteSQL := "DELETE FROM exchange_rate WHERE FromCurrency = $1 AND ToCurrency = $2 AND EffectiveDate = $3"
+		if _, err := tx.ExecContext(ctx, s.Dialect.Rebind(deleteSQL), from, to, date); err != nil {
+			return err
+		}
+		return s.audit(ctx, tx, AuditExchangeRate, AuditDelete, current.auditID(), current, nil)
+	})
+}
+
+// departmentColumns is the column list every query reading a whole department selects, in scanDepartment order
+const departmentColumns = "ID, Name, CreatedAt, UpdatedAt"
+
+func scanDepartment(row rowScanner) (*Department, error) {
+	dept := &Department{}
+	if err := row.Scan(&dept.ID, &dept.Name, &dept.CreatedAt, &dept.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return dept, nil
+}
+
+func (s *SQLEmployeeStore) CreateDepartment(ctx context.Context, dept *Department) error {
+	insertDepartmentSQL := `
+        INSERT INTO department (Name, CreatedAt, UpdatedAt)
+        VALUES ($1, $2, $3)
+        RETURNING ID, CreatedAt, UpdatedAt;
+    `
+
+	now := time.Now().UTC()
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, s.Dialect.Rebind(insertDepartmentSQL), dept.Name, now, now).Scan(&dept.ID, &dept.CreatedAt, &dept.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		return s.audit(ctx, tx, AuditDepartment, AuditCreate, strconv.Itoa(dept.ID), nil, dept)
+	})
+	if err != nil {
+		return s.conflictError("department", err)
 	}
-	// No need to fetch employee data after deletion
 	return nil
+}
+
+func (s *SQLEmployeeStore) ReadDepartment(ctx context.Context, id int) (*Department, error) {
+	dept, err := scanDepartment(s.queryRow(ctx, "SELECT "+departmentColumns+" FROM department WHERE ID = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDepartmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return dept, nil
+}
+
+func (s *SQLEmployeeStore) ReadDepartmentList(ctx context.Context) ([]Department, error) {
+	rows, err := s.query(ctx, "SELECT "+departmentColumns+" FROM department ORDER BY ID")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var depts []Department
+	for rows.Next() {
+		dept, err := scanDepartment(rows)
+		if err != nil {
+			return nil, err
+		}
+		depts = append(depts, *dept)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return depts, nil
+}
+
+func (s *SQLEmployeeStore) UpdateDepartment(ctx context.Context, id int, dept *Department) (*Department, error) {
+	updateDepartmentSQL := "UPDATE department SET Name = $1, UpdatedAt = $2 WHERE ID = $3 RETURNING " + departmentColumns
+	var updated *Department
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		current, err := s.readDepartmentForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if updated, err = scanDepartment(tx.QueryRowContext(ctx, s.Dialect.Rebind(updateDepartmentSQL), dept.Name, time.Now().UTC(), id)); err != nil {
+			return err
+		}
+		return s.audit(ctx, tx, AuditDepartment, AuditUpdate, strconv.Itoa(id), current, updated)
+	})
+	if err != nil {
+		return nil, s.conflictError("department", err)
+	}
+	return updated, nil
+}
+
+// readDepartmentForUpdate reads a department in tx, locking it until the transaction ends
+func (s *SQLEmployeeStore) readDepartmentForUpdate(ctx context.Context, tx *sql.Tx, id int) (*Department, error) {
+	readSQL := "SELECT " + departmentColumns + " FROM department WHERE ID = $1" + s.Dialect.ForUpdateSQL()
+	dept, err := scanDepartment(tx.QueryRowContext(ctx, s.Dialect.Rebind(readSQL), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDepartmentNotFound
+	}
+	return dept, err
+}
+
+func (s *SQLEmployeeStore) DeleteDepartment(ctx context.Context, id int) error {
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		current, err := s.readDepartmentForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, s.Dialect.Rebind("DELETE FROM department WHERE ID = $1"), id); err != nil {
+			return err
+		}
+		return s.audit(ctx, tx, AuditDepartment, AuditDelete, strconv.Itoa(id), current, nil)
+	})
+	if s.Dialect.ForeignKeyViolation(err) {
+		return ErrDepartmentNotEmpty
+	}
+	return err
+}
+
+// auditColumns is the column list every query reading an audit entry selects, in
+// scanAuditEntry order
+const auditColumns = "ID, RecordedAt, Actor, Action, Resource, TargetID, Changes, RequestID, ClientIP, PrevHash, Hash"
+
+func scanAuditEntry(row rowScanner) (*AuditEntry, error) {
+	entry := &AuditEntry{}
+	var changes string
+	err := row.Scan(&entry.ID, &entry.RecordedAt, &entry.Actor, &entry.Action, &entry.Resource, &entry.TargetID,
+		&changes, &entry.RequestID, &entry.ClientIP, &entry.PrevHash, &entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+	entry.RecordedAt = entry.RecordedAt.UTC()
+	entry.Changes = json.RawMessage(changes)
+	return entry, nil
+}
+
+// audit appends the entry of a write made in tx to the audit log; see newAuditEntry
+func (s *SQLEmployeeStore) audit(ctx context.Context, tx *sql.Tx, resource, action, targetID string, before, after any) error {
+	entry, err := newAuditEntry(ctx, resource, action, targetID, before, after)
+	if err != nil {
+		return err
+	}
+
+	// Each entry is chained to the last one, so appends take turns
+	if lockSQL := s.Dialect.AuditLockSQL(); lockSQL != "" {
+		if _, err := tx.ExecContext(ctx, lockSQL); err != nil {
+			return err
+		}
+	}
+	last, err := scanAuditEntry(tx.QueryRowContext(ctx, "SELECT "+auditColumns+" FROM audit_log ORDER BY ID DESC LIMIT 1"))
+	if errors.Is(err, sql.ErrNoRows) {
+		last, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	entry.chain(last)
+
+	insertAuditSQL := "INSERT INTO audit_log (" + auditColumns + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)"
+	_, err = tx.ExecContext(ctx, s.Dialect.Rebind(insertAuditSQL), entry.ID, entry.RecordedAt, entry.Actor, entry.Action,
+		entry.Resource, entry.TargetID, string(entry.Changes), entry.RequestID, entry.ClientIP, entry.PrevHash, entry.Hash)
+	return err
+}
+
+func (s *SQLEmployeeStore) ReadAuditLog(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	c := &sqlConditions{}
+	c.add("ID > $%d", q.AfterID)
+	for _, match := range []struct{ column, value string }{
+		{"Actor", q.Filter.Actor},
+		{"Action", q.Filter.Action},
+		{"Resource", q.Filter.Resource},
+		{"TargetID", q.Filter.TargetID},
+		{"RequestID", q.Filter.RequestID},
+	} {
+		if match.value != "" {
+			c.add(match.column+" = $%d", match.value)
+		}
+	}
+	if !q.Filter.From.IsZero() {
+		c.add("RecordedAt >= $%d", q.Filter.From)
+	}
+	if !q.Filter.To.IsZero() {
+		c.add("RecordedAt <= $%d", q.Filter.To)
+	}
+	readAuditSQL := "SELECT " + auditColumns + " FROM audit_log" + c.String() + fmt.Sprintf(" ORDER BY ID LIMIT $%d", c.param(q.Limit))
 
+	rows, err := s.query(ctx, readAuditSQL, c.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
 }
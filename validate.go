@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validation rules are declared on struct fields with a `validate` tag holding a
+// comma-separated list of rules, e.g. `validate:"required,max=100"`:
+//
+//	required     the field must not be the zero value
+//	readonly     the field is assigned by the server and must not be sent
+//	max=N        strings: at most N characters; numbers: at most N
+//	min=N        strings: at least N characters; numbers: at least N
+//	designation  the value must be one of the allowed designations, when any are configured
+//	currency     the value must be an ISO 4217 currency code such as EUR
+//
+// Field names in errors are taken from the `json` tag.
+
+// allowedDesignations restricts Employee.Designation; empty allows any. main replaces it
+// with the configured list.
+var allowedDesignations []string
+
+type validationRule func(v reflect.Value, param string) string
+
+var validationRules = map[string]validationRule{
+	"required": func(v reflect.Value, _ string) string {
+		if v.IsZero() {
+			return "is required"
+		}
+		return ""
+	},
+	"readonly": func(v reflect.Value, _ string) string {
+		if !v.IsZero() {
+			return "is assigned by the server and must not be set"
+		}
+		return ""
+	},
+	"max": func(v reflect.Value, param string) string {
+		if v.Kind() == reflect.String {
+			limit, _ := strconv.Atoi(param)
+			if utf8.RuneCountInString(v.String()) > limit {
+				return fmt.Sprintf("must be at most %s characters", param)
+			}
+		} else if n, ok := numericValue(v); ok && n.Cmp(ruleLimit(param)) > 0 {
+			return fmt.Sprintf("must be at most %s", param)
+		}
+		return ""
+	},
+	"min": func(v reflect.Value, param string) string {
+		if v.Kind() == reflect.String {
+			limit, _ := strconv.Atoi(param)
+			if utf8.RuneCountInString(v.String()) < limit {
+				return fmt.Sprintf("must be at least %s characters", param)
+			}
+		} else if n, ok := numericValue(v); ok && n.Cmp(ruleLimit(param)) < 0 {
+			return fmt.Sprintf("must be at least %s", param)
+		}
+		return ""
+	},
+	"designation": func(v reflect.Value, _ string) string {
+		if len(allowedDesignations) == 0 {
+			return ""
+		}
+		for _, d := range allowedDesignations {
+			if strings.EqualFold(d, v.String()) {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(allowedDesignations, ", ")
+	},
+	"currency": func(v reflect.Value, _ string) string {
+		if !validCurrency(v.String()) {
+			return "must be an ISO 4217 currency code such as EUR"
+		}
+		return ""
+	},
+}
+
+// numericValue returns the exact value of a number field, so Money is compared to the cent
+func numericValue(v reflect.Value) (*big.Rat, bool) {
+	if m, ok := v.Interface().(Money); ok {
+		return m.Rat(), true
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(v.Int()), true
+	case reflect.Float32, reflect.Float64:
+		if r := new(big.Rat).SetFloat64(v.Float()); r != nil {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// ruleLimit reads the decimal parameter of a min or max rule
+func ruleLimit(param string) *big.Rat {
+	limit, ok := new(big.Rat).SetString(param)
+	if !ok {
+		return new(big.Rat)
+	}
+	return limit
+}
+
+// jsonFieldName returns the name a struct field has in JSON, or "" if it is not serialised
+func jsonFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" || !f.IsExported() {
+		return ""
+	}
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// ValidateStruct applies the `validate` rules of the fields of the struct v points to.
+// When only is non-nil, just the JSON field names it lists are checked, as for a patch.
+// skip lists JSON field names that already have an error and should not be reported twice.
+func ValidateStruct(v any, only []string, skip map[string]bool) []FieldError {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rt := rv.Type()
+
+	var checked map[string]bool
+	if only != nil {
+		checked = make(map[string]bool, len(only))
+		for _, name := range only {
+			checked[name] = true
+		}
+	}
+
+	var errs []FieldError
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		name := jsonFieldName(f)
+		tag := f.Tag.Get("validate")
+		if name == "" || tag == "" || skip[name] || (checked != nil && !checked[name]) {
+			continue
+		}
+
+		value := rv.Field(i)
+
+		for _, rule := range strings.Split(tag, ",") {
+			ruleName, param, _ := strings.Cut(rule, "=")
+			check, ok := validationRules[ruleName]
+			if !ok {
+				panic(fmt.Sprintf("unknown validation rule %q on %s.%s", ruleName, rt.Name(), f.Name))
+			}
+			if msg := check(value, param); msg != "" {
+				errs = append(errs, FieldError{Field: name, Message: msg})
+				// Report only the first failing rule of each field
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// NewValidationError reports every invalid field of a request at once
+func NewValidationError(errs []FieldError) *APIError {
+	return &APIError{
+		Status: http.StatusUnprocessableEntity,
+		Code:   CodeValidation,
+		Title:  "Validation failed",
+		Detail: fmt.Sprintf("%d invalid fields", len(errs)),
+		Errors: errs,
+	}
+}
+
+// decodeAndValidate decodes a JSON object into the struct dst points to and validates it,
+// returning the JSON names of the members present in the body in sorted order.
+// Unknown fields, values of the wrong type and rule violations are all collected and
+// returned together as a validation error; a body that is not a JSON object is a bad request.
+//
+// With patch set the body is a JSON merge patch (RFC 7396): only the members present are
+// validated, and null is accepted only for fields that can hold it (pointers).
+func decodeAndValidate(body io.Reader, dst any, patch bool) ([]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return nil, NewBadRequestError(CodeMalformedRequest, "Request body is not a valid JSON object", err)
+	}
+	if raw == nil {
+		return nil, NewBadRequestError(CodeMalformedRequest, "Request body is not a valid JSON object", errEmptyBody)
+	}
+
+	rv := reflect.ValueOf(dst).Elem()
+	rt := rv.Type()
+	known := make(map[string]int, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		if name := jsonFieldName(rt.Field(i)); name != "" {
+			known[name] = i
+		}
+	}
+
+	// Decode field by field so every bad field is reported, in a stable order
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []FieldError
+	failed := make(map[string]bool)
+	for _, key := range keys {
+		i, ok := known[key]
+		if !ok {
+			errs = append(errs, FieldError{Field: key, Message: "is not a known field"})
+			continue
+		}
+		field := rv.Field(i)
+		if patch && string(raw[key]) == "null" && field.Kind() != reflect.Pointer {
+			errs = append(errs, FieldError{Field: key, Message: "cannot be null"})
+			failed[key] = true
+			continue
+		}
+		if err := json.Unmarshal(raw[key], field.Addr().Interface()); err != nil {
+			errs = append(errs, FieldError{Field: key, Message: "has an invalid value: " + jsonErrorMessage(err)})
+			failed[key] = true
+		}
+	}
+
+	var only []string
+	if patch {
+		only = keys
+	}
+	errs = append(errs, ValidateStruct(dst, only, failed)...)
+	if len(errs) > 0 {
+		return nil, NewValidationError(errs)
+	}
+	return keys, nil
+}
+
+// jsonErrorMessage describes a decoding error without Go type names
+func jsonErrorMessage(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return "expected " + jsonTypeName(typeErr.Type) + " but got " + typeErr.Value
+	}
+	return err.Error()
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
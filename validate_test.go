@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeAndValidateEmployee(t *testing.T) {
+	defer func(designations []string) { allowedDesignations = designations }(allowedDesignations)
+	allowedDesignations = []string{"Engineer", "Software Developer"}
+
+	tests := []struct {
+		name       string
+		body       string
+		patch      bool
+		wantFields []string
+		wantStatus int
+	}{
+		{
+			name: "Valid employee",
+			body: `{"name": "John Doe", "designation": "engineer", "salary": 50000}`,
+		},
+		{
+			name:       "Every problem reported at once",
+			body:       `{"name": "` + strings.Repeat("x", 101) + `", "designation": "Pilot", "salary": -1, "bonus": 10}`,
+			wantFields: []string{"bonus", "name", "designation", "salary"},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Missing required fields",
+			body:       `{"salary": 100}`,
+			wantFields: []string{"name", "designation"},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Wrong types are reported once per field",
+			body:       `{"name": 7, "designation": "Engineer", "salary": "lots"}`,
+			wantFields: []string{"name", "salary"},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "Salary as a decimal string",
+			body: `{"name": "John Doe", "designation": "Engineer", "salary": "50000.50"}`,
+		},
+		{
+			name:       "Salary finer than a cent",
+			body:       `{"name": "John Doe", "designation": "Engineer", "salary": 50000.505}`,
+			wantFields: []string{"salary"},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "Salary at the maximum",
+			body: `{"name": "John Doe", "designation": "Engineer", "salary": "1000000000.00"}`,
+		},
+		{
+			name:       "Salary a cent over the maximum",
+			body:       `{"name": "John Doe", "designation": "Engineer", "salary": "1000000000.01"}`,
+			wantFields: []string{"salary"},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Currency must be an ISO 4217 code",
+			body:       `{"name": "John Doe", "designation": "Engineer", "salary": 50000, "currency": "euro"}`,
+			wantFields: []string{"currency"},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:  "Patch skips absent fields",
+			body:  `{"salary": 60000}`,
+			patch: true,
+		},
+		{
+			name:  "Patch accepts zero values",
+			body:  `{"salary": 0}`,
+			patch: true,
+		},
+		{
+			name:       "Patch still checks present fields",
+			body:       `{"name": "", "salary": 2000000000}`,
+			patch:      true,
+			wantFields: []string{"name", "salary"},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Patch rejects null for fields that cannot hold it",
+			body:       `{"designation": null}`,
+			patch:      true,
+			wantFields: []string{"designation"},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Not a JSON object",
+			body:       `["John Doe"]`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeAndValidate(strings.NewReader(tt.body), newEmployee(), tt.patch)
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("decodeAndValidate() error = %v", err)
+				}
+				return
+			}
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.Status != tt.wantStatus {
+				t.Fatalf("decodeAndValidate() error = %v, want status %d", err, tt.wantStatus)
+			}
+			var fields []string
+			for _, fe := range apiErr.Errors {
+				fields = append(fields, fe.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("decodeAndValidate() fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestCreateEmployeeHandlerValidation(t *testing.T) {
+	store := initTestStore(t)
+	cr := initTestRouter(t, store)
+
+	body := `{"name": "", "designation": "Engineer", "salary": -5}`
+	rec := serveTestRequest(cr, httptest.NewRequest("POST", "/employees", strings.NewReader(body)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("POST /employees status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	if problem := decodeProblem(t, rec); problem.Code != CodeValidation || len(problem.Errors) != 2 {
+		t.Errorf("problem = %+v, want 2 validation errors", problem)
+	}
+
+	// Nothing reached the store
+	if _, err := store.ReadEmployee(context.Background(), 1); !errors.Is(err, ErrEmployeeNotFound) {
+		t.Errorf("invalid employee was stored")
+	}
+}